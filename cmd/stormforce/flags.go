@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"stormforce/internal/config"
+)
+
+type commonFlags struct {
+	envFile        string
+	planFile       string
+	nonInteractive bool
+}
+
+func newFlagSet(name string, cfg *config.Config, common *commonFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet("stormforce "+name, flag.ContinueOnError)
+	fs.StringVar(&common.envFile, "env-file", ".env", "file with environment variable defaults")
+	fs.StringVar(&common.planFile, "plan", "", "YAML or JSON test plan file")
+	fs.BoolVar(&common.nonInteractive, "non-interactive", !stdinIsTerminal(), "do not prompt for settings (default when stdin is not a terminal)")
+	config.BindFlags(fs, cfg)
+	return fs
+}
+
+// loadConfig builds the configuration for a command. Settings are resolved
+// with flags taking precedence over a test plan, then environment variables,
+// then the env file. Flags may follow positional arguments. When
+// planFromArgs is set the first positional argument is used as the plan
+// unless --plan is given, and any other positional argument is an error.
+// It returns the remaining positional arguments and whether the user may be
+// prompted.
+func loadConfig(name string, args []string, planFromArgs bool) (config.Config, []string, bool, error) {
+	// The first pass only finds --env-file; the second binds the flags on
+	// top of the configuration loaded from it.
+	var scratch config.Config
+	var common commonFlags
+	first := newFlagSet(name, &scratch, &common)
+	first.SetOutput(io.Discard)
+	positional, err := parseFlags(first, args)
+	if err != nil {
+		// Parse again with output enabled so the error and usage are shown.
+		parseFlags(newFlagSet(name, &scratch, &commonFlags{}), args)
+		return config.Config{}, nil, false, withExitCode(exitUsage, err)
+	}
+
+	envFileRequired := false
+	first.Visit(func(f *flag.Flag) {
+		if f.Name == "env-file" {
+			envFileRequired = true
+		}
+	})
+
+	cfg, err := config.Load(common.envFile, envFileRequired)
+	if err != nil {
+		return config.Config{}, nil, false, withExitCode(exitUsage, err)
+	}
+
+	planFile := common.planFile
+	if planFromArgs {
+		if planFile == "" && len(positional) > 0 {
+			planFile, positional = positional[0], positional[1:]
+		}
+		if len(positional) > 0 {
+			return config.Config{}, nil, false, withExitCode(exitUsage, fmt.Errorf("unexpected arguments: %s", strings.Join(positional, " ")))
+		}
+	}
+	if planFile != "" {
+		plan, err := config.LoadPlan(planFile)
+		if err != nil {
+			return config.Config{}, nil, false, withExitCode(exitUsage, err)
+		}
+		if err := plan.Apply(&cfg); err != nil {
+			return config.Config{}, nil, false, withExitCode(exitUsage, err)
+		}
+	}
+
+	second := newFlagSet(name, &cfg, &common)
+	if _, err := parseFlags(second, args); err != nil {
+		return config.Config{}, nil, false, withExitCode(exitUsage, err)
+	}
+	return cfg, positional, !common.nonInteractive, nil
+}
+
+// parseFlags parses args with fs and returns the positional arguments.
+// Unlike fs.Parse it does not stop at the first positional argument, so
+// "run plan.yaml -threads 5" sets -threads too. Everything after "--" is
+// positional.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// stdinIsTerminal reports whether stdin is a terminal. Character devices
+// such as /dev/null, which cron and CI jobs pass, are not.
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"stormforce/internal/config"
+)
+
+func TestParseFlagsAfterPositionalArguments(t *testing.T) {
+	tests := []struct {
+		args       []string
+		threads    int
+		positional []string
+	}{
+		{args: []string{"plan.yaml"}, threads: 10, positional: []string{"plan.yaml"}},
+		{args: []string{"-threads", "5", "plan.yaml"}, threads: 5, positional: []string{"plan.yaml"}},
+		{args: []string{"plan.yaml", "-threads", "5"}, threads: 5, positional: []string{"plan.yaml"}},
+		{args: []string{"a", "-threads=5", "b"}, threads: 5, positional: []string{"a", "b"}},
+		{args: []string{"a", "--", "-threads", "5"}, threads: 10, positional: []string{"a", "-threads", "5"}},
+	}
+	for _, tt := range tests {
+		var common commonFlags
+		cfg := config.Config{Threads: 10}
+		fs := newFlagSet("run", &cfg, &common)
+		positional, err := parseFlags(fs, tt.args)
+		if err != nil {
+			t.Errorf("parseFlags(%q): %v", tt.args, err)
+			continue
+		}
+		if cfg.Threads != tt.threads {
+			t.Errorf("parseFlags(%q) set threads %d, want %d", tt.args, cfg.Threads, tt.threads)
+		}
+		if !reflect.DeepEqual(positional, tt.positional) {
+			t.Errorf("parseFlags(%q) = %q, want %q", tt.args, positional, tt.positional)
+		}
+	}
+}
+
+func TestLoadConfigFlagsOverridePlanAfterIt(t *testing.T) {
+	plan := filepath.Join(t.TempDir(), "plan.yaml")
+	if err := os.WriteFile(plan, []byte("version: 1\ntarget:\n  url: https://example.com/\nload:\n  threads: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, rest, _, err := loadConfig("run", []string{plan, "-threads", "5", "-non-interactive"}, true)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.Threads != 5 {
+		t.Errorf("threads = %d, want the flag's 5 over the plan's 2", cfg.Threads)
+	}
+	if cfg.URL != "https://example.com/" {
+		t.Errorf("URL = %q, want the plan's", cfg.URL)
+	}
+	if len(rest) != 0 {
+		t.Errorf("remaining arguments %q, want none", rest)
+	}
+
+	if _, _, _, err := loadConfig("run", []string{plan, "extra", "-threads", "5"}, true); err == nil {
+		t.Errorf("an unexpected positional argument was accepted")
+	}
+	if _, _, _, err := loadConfig("run", []string{"-plan", plan, "extra"}, true); err == nil {
+		t.Errorf("a positional argument next to -plan was accepted")
+	}
+}
+
+// stdinFromDevNull redirects stdin from /dev/null for the rest of the test,
+// as cron and CI jobs run the command.
+func stdinFromDevNull(t *testing.T) {
+	t.Helper()
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = devNull
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		devNull.Close()
+	})
+}
+
+func TestNonInteractiveWithoutTerminal(t *testing.T) {
+	stdinFromDevNull(t)
+	var common commonFlags
+	var cfg config.Config
+	fs := newFlagSet("run", &cfg, &common)
+	if _, err := parseFlags(fs, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !common.nonInteractive {
+		t.Errorf("stdin from %s would be prompted for settings", os.DevNull)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"stormforce/internal/config"
+	"stormforce/internal/importer"
+)
+
+const importUsage = `Usage: stormforce import <format> [flags] <file>
+
+Formats:
+  har        browser session recorded as HAR, imported as a scenario
+  openapi    OpenAPI 3 document (YAML or JSON), imported as a traffic mix
+  curl       curl command lines, one per line, imported as a scenario
+  postman    Postman v2.1 collection, imported as a scenario
+
+The plan is written to stdout unless -o is given. Run
+"stormforce import <format> -h" to list the flags of a format.
+`
+
+func importCommand(args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		fmt.Print(importUsage)
+		return flag.ErrHelp
+	}
+
+	format, args := args[0], args[1:]
+	switch format {
+	case "har":
+		return importHAR(args)
+	case "openapi":
+		return importOpenAPI(args)
+	case "curl":
+		return importCurl(args)
+	case "postman":
+		return importPostman(args)
+	default:
+		fmt.Fprint(os.Stderr, importUsage)
+		return withExitCode(exitUsage, fmt.Errorf("unknown import format %q", format))
+	}
+}
+
+func importHAR(args []string) error {
+	fs := flag.NewFlagSet("stormforce import har", flag.ContinueOnError)
+	output := fs.String("o", "", "write the plan to this file instead of stdout")
+	var opts importer.HAROptions
+	fs.Func("domain", "keep requests to this domain and its subdomains (repeatable, default: the domain of the first request)", func(value string) error {
+		opts.Domains = append(opts.Domains, value)
+		return nil
+	})
+	fs.BoolVar(&opts.AllDomains, "all-domains", false, "keep requests to third-party domains")
+	fs.BoolVar(&opts.KeepStatic, "keep-static", false, "keep static assets such as images, scripts, stylesheets and fonts")
+	fs.DurationVar(&opts.MinThinkTime, "min-think-time", 100*time.Millisecond, "ignore shorter pauses between requests")
+	if err := fs.Parse(args); err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	if fs.NArg() != 1 {
+		return withExitCode(exitUsage, errors.New("expected one HAR file"))
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error opening HAR file: %v", err)
+	}
+	defer file.Close()
+
+	plan, err := importer.FromHAR(file, opts)
+	if err != nil {
+		return err
+	}
+	return writePlan(plan, fs.Arg(0), *output)
+}
+
+func importOpenAPI(args []string) error {
+	fs := flag.NewFlagSet("stormforce import openapi", flag.ContinueOnError)
+	output := fs.String("o", "", "write the plan to this file instead of stdout")
+	var opts importer.OpenAPIOptions
+	fs.StringVar(&opts.Server, "server", "", "base URL of the API (default: the first server of the document)")
+	fs.Func("operation", "import the operation with this operationId (repeatable, default: all operations)", func(value string) error {
+		opts.Operations = append(opts.Operations, value)
+		return nil
+	})
+	fs.Func("tag", "import the operations with this tag (repeatable)", func(value string) error {
+		opts.Tags = append(opts.Tags, value)
+		return nil
+	})
+	fs.BoolVar(&opts.ValidateResponses, "validate-responses", false, "fail responses that do not match the documented 2xx JSON schema")
+	if err := fs.Parse(args); err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	if fs.NArg() != 1 {
+		return withExitCode(exitUsage, errors.New("expected one OpenAPI document"))
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error opening OpenAPI document: %v", err)
+	}
+	defer file.Close()
+
+	plan, err := importer.FromOpenAPI(file, opts)
+	if err != nil {
+		return err
+	}
+	return writePlan(plan, fs.Arg(0), *output)
+}
+
+func importCurl(args []string) error {
+	fs := flag.NewFlagSet("stormforce import curl", flag.ContinueOnError)
+	output := fs.String("o", "", "write the plan to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: stormforce import curl [flags] [file]\n\nReads the commands from file, or from stdin when file is missing or -.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	if fs.NArg() > 1 {
+		return withExitCode(exitUsage, errors.New("expected at most one file"))
+	}
+
+	source, input := "stdin", io.Reader(os.Stdin)
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("error opening curl file: %v", err)
+		}
+		defer file.Close()
+		source, input = fs.Arg(0), file
+	}
+	commands, err := io.ReadAll(input)
+	if err != nil {
+		return fmt.Errorf("error reading curl commands: %v", err)
+	}
+
+	plan, err := importer.FromCurl(string(commands))
+	if err != nil {
+		return err
+	}
+	return writePlan(plan, source, *output)
+}
+
+func importPostman(args []string) error {
+	fs := flag.NewFlagSet("stormforce import postman", flag.ContinueOnError)
+	output := fs.String("o", "", "write the plan to this file instead of stdout")
+	envFile := fs.String("env", "", "Postman environment file whose variables are filled in")
+	if err := fs.Parse(args); err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	if fs.NArg() != 1 {
+		return withExitCode(exitUsage, errors.New("expected one Postman collection"))
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error opening Postman collection: %v", err)
+	}
+	defer file.Close()
+
+	var environment io.Reader
+	if *envFile != "" {
+		env, err := os.Open(*envFile)
+		if err != nil {
+			return fmt.Errorf("error opening Postman environment: %v", err)
+		}
+		defer env.Close()
+		environment = env
+	}
+
+	plan, err := importer.FromPostman(file, environment)
+	if err != nil {
+		return err
+	}
+	return writePlan(plan, fs.Arg(0), *output)
+}
+
+// writePlan writes an imported plan to output, or to stdout when output is
+// empty.
+func writePlan(plan *config.Plan, source, output string) error {
+	if output == "" {
+		return importer.WritePlan(os.Stdout, plan, source)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("error creating plan file: %v", err)
+	}
+	if err := importer.WritePlan(file, plan, source); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing plan file: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote a plan with %d requests to %s\n", len(plan.Scenario)+len(plan.Requests), output)
+	return nil
+}
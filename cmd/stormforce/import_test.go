@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestImportArgumentErrorsAreUsageErrors(t *testing.T) {
+	tests := [][]string{
+		{"xml", "requests.xml"},
+		{"har"},
+		{"har", "a.har", "b.har"},
+		{"har", "-min-think-time", "soon", "a.har"},
+		{"openapi"},
+		{"openapi", "a.yaml", "b.yaml"},
+		{"openapi", "-validate-responses=maybe", "a.yaml"},
+		{"curl", "a.txt", "b.txt"},
+		{"curl", "-x"},
+		{"postman"},
+		{"postman", "a.json", "b.json"},
+		{"postman", "-env"},
+	}
+	for _, args := range tests {
+		err := importCommand(args)
+		var exit *exitCodeError
+		if !errors.As(err, &exit) || exit.code != exitUsage {
+			t.Errorf("import %q: error = %v, want exit code %d", args, err, exitUsage)
+		}
+	}
+}
@@ -1,43 +1,90 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const usage = `Usage: stormforce <command> [flags] [plan file]
+
+Commands:
+  run        run a load test (default)
+  validate   check the configuration or a test plan without sending requests
+  report     print and chart a previously saved results.json
+  import     convert recorded or documented traffic into a test plan
+
+Settings are taken from flags, then the test plan (YAML or JSON), then
+environment variables, then the .env file. Run "stormforce <command> -h" to
+list the flags of a command.
+
+Exit codes:
+  0  success
+  1  error
+  2  invalid flags or configuration
+  3  one or more thresholds failed, or one aborted the run
+  4  run interrupted, the results are partial
+`
 
-	"stormforce/internal/config"
-	"stormforce/internal/loadtest"
-	"stormforce/internal/ui"
+// Exit codes, documented in usage.
+const (
+	exitError       = 1
+	exitUsage       = 2
+	exitThresholds  = 3
+	exitInterrupted = 4
 )
 
-func main() {
-	ui.PrintLogo()
+// exitCodeError makes the process exit with code after printing err.
+type exitCodeError struct {
+	code int
+	err  error
+}
 
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Error loading configuration: %v", err)
-	}
+func (e *exitCodeError) Error() string { return e.err.Error() }
 
-	err = config.SetupLogging(cfg)
-	if err != nil {
-		log.Fatalf("Error setting up logging: %v", err)
-	}
+func (e *exitCodeError) Unwrap() error { return e.err }
 
-	results, err := loadtest.Run(cfg)
-	if err != nil {
-		log.Fatalf("Error running load test: %v", err)
+func withExitCode(code int, err error) error {
+	return &exitCodeError{code: code, err: err}
+}
+
+func main() {
+	args := os.Args[1:]
+	command := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	ui.DisplayResults(results, cfg)
-	err = ui.GenerateCharts(results, cfg)
-	if err != nil {
-		log.Printf("Error generating charts: %v", err)
+	var err error
+	switch command {
+	case "run":
+		err = runCommand(args)
+	case "validate":
+		err = validateCommand(args)
+	case "report":
+		err = reportCommand(args)
+	case "import":
+		err = importCommand(args)
+	case "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		os.Exit(exitUsage)
 	}
 
-	if cfg.JSONOutput {
-		err = results.OutputJSON()
-		if err != nil {
-			log.Printf("Error outputting JSON: %v", err)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		code := exitError
+		var exit *exitCodeError
+		if errors.As(err, &exit) {
+			code = exit.code
 		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(code)
 	}
-
-	config.Cleanup()
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"stormforce/internal/config"
+	"stormforce/internal/results"
+	"stormforce/internal/threshold"
+	"stormforce/internal/ui"
+)
+
+func reportCommand(args []string) error {
+	cfg, rest, _, err := loadConfig("report", args, false)
+	if err != nil {
+		return err
+	}
+
+	if err := config.SetupLogging(cfg); err != nil {
+		return fmt.Errorf("error setting up logging: %v", err)
+	}
+
+	if len(rest) > 1 {
+		return withExitCode(exitUsage, fmt.Errorf("expected at most one results file, got %s", strings.Join(rest, " ")))
+	}
+	path := "results.json"
+	if len(rest) > 0 {
+		path = rest[0]
+	}
+
+	res, err := results.ReadJSON(path)
+	if err != nil {
+		return err
+	}
+
+	// Thresholds are judged again so a saved run can be checked against
+	// the current ones.
+	threshold.Evaluate(cfg.AllThresholds(), &res)
+	ui.DisplayResults(res, cfg)
+	if err := ui.GenerateCharts(res, cfg); err != nil {
+		return fmt.Errorf("error generating charts: %v", err)
+	}
+	fmt.Println("Charts saved to load_test_results.html")
+	return runOutcome(res)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"stormforce/internal/config"
+	"stormforce/internal/dashboard"
+	"stormforce/internal/loadtest"
+	"stormforce/internal/results"
+	"stormforce/internal/ui"
+)
+
+func runCommand(args []string) error {
+	cfg, _, interactive, err := loadConfig("run", args, true)
+	if err != nil {
+		return err
+	}
+
+	ui.PrintLogo()
+
+	if interactive {
+		cfg, err = config.PromptForOverrides(cfg)
+		if err != nil {
+			return fmt.Errorf("error reading configuration: %v", err)
+		}
+	}
+	if err := config.Validate(cfg); err != nil {
+		return withExitCode(exitUsage, fmt.Errorf("invalid configuration:\n%v", err))
+	}
+	if err := loadtest.Validate(cfg); err != nil {
+		return withExitCode(exitUsage, fmt.Errorf("invalid configuration:\n%v", err))
+	}
+
+	err = config.SetupLogging(cfg)
+	if err != nil {
+		return fmt.Errorf("error setting up logging: %v", err)
+	}
+
+	// The first SIGINT/SIGTERM stops the run and still reports partial
+	// results; once Run returns a second signal terminates immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	var live *results.Live
+	if cfg.Live || cfg.Dashboard != "" {
+		live = results.NewLive()
+	}
+	var board *dashboard.Server
+	if cfg.Dashboard != "" {
+		board, err = dashboard.Start(cfg.Dashboard, live)
+		if err != nil {
+			stop()
+			return err
+		}
+		defer board.Close()
+		fmt.Printf("Live dashboard: %s\n", board.URL())
+	}
+	var display *ui.LiveDisplay
+	resumeLog := func() {}
+	if cfg.Live {
+		display = ui.StartLive(live)
+		resumeLog = config.PauseConsoleLog()
+	}
+	res, err := loadtest.Run(ctx, cfg, live)
+	if display != nil {
+		display.Stop()
+	}
+	resumeLog()
+	stop()
+	if err != nil {
+		return fmt.Errorf("error running load test: %v", err)
+	}
+
+	ui.DisplayResults(res, cfg)
+	err = ui.GenerateCharts(res, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating charts: %v\n", err)
+	}
+
+	if cfg.JSONOutput {
+		err = res.OutputJSON(cfg.JSONSamples)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error outputting JSON: %v\n", err)
+		}
+	}
+
+	if board != nil {
+		serveReport(board, res, cfg)
+	}
+
+	config.Cleanup()
+	return runOutcome(res)
+}
+
+// serveReport replaces the live dashboard with the final report and keeps
+// serving it for cfg.DashboardLinger. Without a linger it is served until
+// interrupted when stdin is a terminal; otherwise, as in CI jobs, the run
+// exits right away.
+func serveReport(board *dashboard.Server, res results.Results, cfg config.Config) {
+	var report bytes.Buffer
+	if err := ui.RenderCharts(&report, res, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating charts: %v\n", err)
+		report.Reset()
+		fmt.Fprintf(&report, "Error generating charts: %v", err)
+	}
+	board.Finish(report.Bytes())
+
+	if cfg.DashboardLinger == 0 && !stdinIsTerminal() {
+		return
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.DashboardLinger > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.DashboardLinger)
+		defer cancel()
+		fmt.Printf("Final report served at %s for %s\n", board.URL(), cfg.DashboardLinger)
+	} else {
+		fmt.Printf("Final report served at %s, press Ctrl+C to exit\n", board.URL())
+	}
+	<-ctx.Done()
+}
+
+// runOutcome turns failed thresholds and interrupted runs into their exit
+// codes. Failed thresholds, including one that aborted the run, take
+// precedence.
+func runOutcome(res results.Results) error {
+	if res.Aborted {
+		return withExitCode(exitThresholds, fmt.Errorf("run aborted: %s", res.StopReason))
+	}
+	if failed := res.FailedThresholds(); failed > 0 {
+		return withExitCode(exitThresholds, fmt.Errorf("%d of %d thresholds failed", failed, len(res.Thresholds)))
+	}
+	if res.Interrupted {
+		return withExitCode(exitInterrupted, fmt.Errorf("run was interrupted, the results are partial"))
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"stormforce/internal/config"
+	"stormforce/internal/dashboard"
+	"stormforce/internal/results"
+)
+
+func TestServeReportReturnsWithoutTerminal(t *testing.T) {
+	stdinFromDevNull(t)
+	board, err := dashboard.Start("127.0.0.1:0", results.NewLive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer board.Close()
+
+	res := results.New(nil, 0, time.Second)
+	res.Summarize()
+	done := make(chan struct{})
+	go func() {
+		serveReport(board, res, config.Config{})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveReport waits for an interrupt without a terminal and no linger")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"stormforce/internal/config"
+	"stormforce/internal/loadtest"
+)
+
+func validateCommand(args []string) error {
+	cfg, _, _, err := loadConfig("validate", args, true)
+	if err != nil {
+		return err
+	}
+
+	if err := config.Validate(cfg); err != nil {
+		return withExitCode(exitUsage, fmt.Errorf("invalid configuration:\n%v", err))
+	}
+	if err := loadtest.Validate(cfg); err != nil {
+		return withExitCode(exitUsage, fmt.Errorf("invalid configuration:\n%v", err))
+	}
+
+	fmt.Println("Configuration is valid ✔️")
+	if len(cfg.Scenario) > 0 {
+		fmt.Printf("- Scenario: %d steps\n", len(cfg.Scenario))
+		for i, step := range cfg.Scenario {
+			fmt.Printf("    %d. %s: %s %s%s\n", i+1, step.Name, step.Method, step.URL, formatTags(step.Tags))
+			printChecks(step.Checks)
+		}
+	} else if len(cfg.Requests) > 0 {
+		fmt.Printf("- Traffic mix: %d requests\n", len(cfg.Requests))
+		for _, def := range cfg.Requests {
+			fmt.Printf("    %s (weight %g): %s %s%s\n", def.Name, def.Weight, def.Method, def.URL, formatTags(def.Tags))
+			printChecks(def.Checks)
+		}
+	} else {
+		fmt.Printf("- URL: %s %s\n", cfg.Method, cfg.URL)
+	}
+	if len(cfg.Checks) > 0 {
+		fmt.Printf("- Checks on every request: %d\n", len(cfg.Checks))
+		printChecks(cfg.Checks)
+	}
+	if thresholds := cfg.AllThresholds(); len(thresholds) > 0 {
+		fmt.Printf("- Thresholds: %d\n", len(thresholds))
+		for _, t := range thresholds {
+			fmt.Printf("    %s\n", t.Expr)
+		}
+		if cfg.AbortOnFail {
+			fmt.Printf("    aborting when one fails over a %s window, after a %s grace period\n", cfg.ThresholdWindow, cfg.ThresholdGrace)
+		}
+	}
+	if cfg.Insecure {
+		fmt.Println("- TLS certificate verification: disabled")
+	}
+	for _, feeder := range cfg.Feeders {
+		fmt.Printf("- Data: %s (%s, %s when exhausted)\n", feeder.File, feeder.Strategy, feeder.OnExhausted)
+	}
+	switch {
+	case len(cfg.Stages) > 0:
+		fmt.Printf("- Load: stages %s\n", config.FormatStages(cfg.Stages))
+	case cfg.Rate > 0:
+		fmt.Printf("- Load: %.2f requests/second, max %d in flight\n", cfg.Rate, cfg.MaxInFlight)
+	default:
+		fmt.Printf("- Load: %d threads\n", cfg.Threads)
+	}
+	if cfg.Duration > 0 {
+		fmt.Printf("- Duration: %s\n", cfg.Duration)
+	} else if len(cfg.Stages) == 0 {
+		if len(cfg.Scenario) > 0 {
+			fmt.Printf("- Iterations: %d\n", cfg.N)
+		} else {
+			fmt.Printf("- Requests: %d\n", cfg.N)
+		}
+	}
+	return nil
+}
+
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(tags, ", ") + "]"
+}
+
+func printChecks(checks []config.Check) {
+	for _, check := range checks {
+		fmt.Printf("       check: %s\n", check.Name)
+	}
+}
@@ -5,4 +5,11 @@ go 1.22.1
 require (
 	github.com/go-echarts/go-echarts/v2 v2.4.1
 	github.com/joho/godotenv v1.5.1
+	golang.org/x/term v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/kr/text v0.2.0 // indirect
+	golang.org/x/sys v0.26.0 // indirect
 )
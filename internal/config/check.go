@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"stormforce/internal/schema"
+)
+
+// Check kinds.
+const (
+	CheckStatus   = "status"    // status code in one of Statuses
+	CheckHeader   = "header"    // Op applied to the header named Target
+	CheckJSON     = "json"      // Op applied to the value at JSON path Target
+	CheckBody     = "body"      // Op applied to the response body
+	CheckBodySize = "body_size" // body size between MinSize and MaxSize bytes
+	CheckLatency  = "latency"   // response time at most MaxLatency
+	CheckSchema   = "schema"    // JSON body matches Schema
+)
+
+// Check operators for header, JSON and body checks.
+const (
+	CheckEquals   = "equals"
+	CheckContains = "contains"
+	CheckMatches  = "matches" // Value is a regex
+	CheckExists   = "exists"
+	CheckAbsent   = "absent"
+	CheckType     = "type" // Value is a JSON type, JSON checks only
+)
+
+// JSONTypes are the type names a JSON type check accepts.
+var JSONTypes = []string{"string", "number", "integer", "boolean", "object", "array", "null"}
+
+// StatusRange is an inclusive range of HTTP status codes.
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// Check is a named assertion on every response to a request. A request
+// fails when one of its checks fails. Status checks replace the default
+// rule that status codes of 400 and up are failures.
+type Check struct {
+	Name       string
+	Kind       string
+	Target     string // header name or JSON path
+	Op         string
+	Value      string
+	Statuses   []StatusRange
+	MinSize    int64
+	MaxSize    int64 // 0 means no upper bound
+	MaxLatency time.Duration
+	Schema     interface{}
+}
+
+// ParseStatuses parses status codes and ranges such as "200,204,3xx,400-404".
+func ParseStatuses(input string) ([]StatusRange, error) {
+	var ranges []StatusRange
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		var r StatusRange
+		var err error
+		if low, high, found := strings.Cut(part, "-"); found {
+			r.Min, err = strconv.Atoi(strings.TrimSpace(low))
+			if err == nil {
+				r.Max, err = strconv.Atoi(strings.TrimSpace(high))
+			}
+		} else if len(part) == 3 && strings.HasSuffix(strings.ToLower(part), "xx") {
+			r.Min, err = strconv.Atoi(part[:1])
+			r.Min *= 100
+			r.Max = r.Min + 99
+		} else {
+			r.Min, err = strconv.Atoi(part)
+			r.Max = r.Min
+		}
+		if err != nil || r.Min < 100 || r.Max > 599 || r.Min > r.Max {
+			return nil, fmt.Errorf("invalid status %q: expected a code such as 200, a class such as 2xx or a range such as 200-299", part)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// FormatStatuses is the inverse of ParseStatuses.
+func FormatStatuses(ranges []StatusRange) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		switch {
+		case r.Min == r.Max:
+			parts[i] = strconv.Itoa(r.Min)
+		case r.Min%100 == 0 && r.Max == r.Min+99:
+			parts[i] = fmt.Sprintf("%dxx", r.Min/100)
+		default:
+			parts[i] = fmt.Sprintf("%d-%d", r.Min, r.Max)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// DefaultName describes the check, for checks configured without a name.
+func (c Check) DefaultName() string {
+	switch c.Kind {
+	case CheckStatus:
+		return "status " + FormatStatuses(c.Statuses)
+	case CheckBodySize:
+		if c.MaxSize > 0 {
+			return fmt.Sprintf("body size %d-%d bytes", c.MinSize, c.MaxSize)
+		}
+		return fmt.Sprintf("body size at least %d bytes", c.MinSize)
+	case CheckLatency:
+		return "latency at most " + c.MaxLatency.String()
+	case CheckSchema:
+		return "schema"
+	}
+	name := c.Kind
+	if c.Target != "" {
+		name += " " + c.Target
+	}
+	name += " " + c.Op
+	if c.Op != CheckExists && c.Op != CheckAbsent {
+		name += " " + c.Value
+	}
+	return name
+}
+
+func (c Check) check() error {
+	switch c.Kind {
+	case CheckStatus:
+		if len(c.Statuses) == 0 {
+			return fmt.Errorf("check %s: no status codes", c.Name)
+		}
+	case CheckHeader, CheckJSON, CheckBody:
+		if c.Kind != CheckBody && c.Target == "" {
+			return fmt.Errorf("check %s: %s is required", c.Name, c.Kind)
+		}
+		switch c.Op {
+		case CheckEquals, CheckContains:
+		case CheckExists, CheckAbsent:
+			if c.Kind == CheckBody {
+				return fmt.Errorf("check %s: %s does not apply to the body", c.Name, c.Op)
+			}
+		case CheckMatches:
+			if _, err := regexp.Compile(c.Value); err != nil {
+				return fmt.Errorf("check %s: invalid regex: %v", c.Name, err)
+			}
+		case CheckType:
+			if c.Kind != CheckJSON {
+				return fmt.Errorf("check %s: type only applies to JSON checks", c.Name)
+			}
+			if !contains(JSONTypes, c.Value) {
+				return fmt.Errorf("check %s: unknown JSON type %q (%s)", c.Name, c.Value, strings.Join(JSONTypes, ", "))
+			}
+		default:
+			return fmt.Errorf("check %s: unknown operator %q", c.Name, c.Op)
+		}
+	case CheckBodySize:
+		if c.MinSize < 0 || c.MaxSize < 0 || (c.MaxSize > 0 && c.MinSize > c.MaxSize) {
+			return fmt.Errorf("check %s: invalid body size bounds %d-%d", c.Name, c.MinSize, c.MaxSize)
+		}
+	case CheckLatency:
+		if c.MaxLatency <= 0 {
+			return fmt.Errorf("check %s: latency must be greater than 0", c.Name)
+		}
+	case CheckSchema:
+		if _, err := schema.Compile(c.Schema); err != nil {
+			return fmt.Errorf("check %s: invalid schema: %v", c.Name, err)
+		}
+	default:
+		return fmt.Errorf("check %s: unknown kind %q", c.Name, c.Kind)
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,19 +2,91 @@ package config
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"stormforce/internal/threshold"
 
 	"github.com/joho/godotenv"
 )
 
+type Stage struct {
+	Duration time.Duration
+	Target   int
+}
+
+// Request defines a named request: a scenario step or an endpoint of a
+// weighted traffic mix. URL, headers and body are templates: {{name}} refers
+// to a variable extracted by an earlier request and functions such as
+// {{uuid}} are evaluated for every request. Checks run after the global
+// ones, and thresholds that are 0 fall back to the global ones.
+type Request struct {
+	Name             string
+	Method           string
+	URL              string
+	Headers          map[string]string
+	Body             string
+	Extract          []Extractor
+	Weight           float64       // share of the traffic mix, unused in a scenario
+	ThinkTime        time.Duration // pause before the request is sent
+	ResponseSchema   interface{}   // JSON Schema successful responses must match
+	Checks           []Check
+	Tags             []string // groups requests for statistics and thresholds
+	ThresholdTime    float64
+	ThresholdSuccess float64
+
+	thresholds []threshold.Threshold // scoped to the request, set by plans
+}
+
+// Extraction sources for an Extractor.
+const (
+	ExtractJSON   = "json"
+	ExtractRegex  = "regex"
+	ExtractHeader = "header"
+)
+
+// Extractor stores a value from a step's response in the variable Var.
+// Expr is a JSON path such as $.data.items[0].id, a regex whose first group
+// (or whole match) is used, or a header name, depending on Source.
+type Extractor struct {
+	Var    string
+	Source string
+	Expr   string
+}
+
+// Data feeder strategies and exhaustion policies.
+const (
+	FeedSequential = "sequential" // rows in file order, a new row per iteration
+	FeedRandom     = "random"     // a random row per iteration, never exhausted
+	FeedUnique     = "unique"     // each VU gets its own row and keeps it
+
+	FeedRecycle = "recycle" // start over from the first row
+	FeedStop    = "stop"    // stop the run when the rows run out
+)
+
+// Feeder supplies rows of a CSV file (with a header row) or a JSON-lines
+// file as template variables, one variable per column.
+type Feeder struct {
+	File        string
+	Strategy    string
+	OnExhausted string
+}
+
 type Config struct {
 	N                int
+	Duration         time.Duration
 	Threads          int
+	Stages           []Stage
+	Rate             float64
+	MaxInFlight      int
 	URL              string
 	Method           string
 	BearerToken      string
@@ -22,45 +94,93 @@ type Config struct {
 	RetryLimit       int
 	ThresholdTime    float64
 	ThresholdSuccess float64
-	EmailEnabled     bool
-	EmailTo          string
-	CurlMaxTime      int
-	LogFile          string
-	DisableLogging   bool
-	RequestBody      string
-	ResponsePattern  string
-	JSONOutput       bool
-}
-
-func LoadConfig() (Config, error) {
-	err := godotenv.Load()
-	if err != nil {
-		return Config{}, fmt.Errorf("error loading .env file: %w", err)
+	// ThresholdTimeSet and ThresholdSuccessSet record that ThresholdTime and
+	// ThresholdSuccess were configured rather than defaulted. Only then does
+	// the run have to meet them; the defaults are just reported.
+	ThresholdTimeSet    bool
+	ThresholdSuccessSet bool
+	EmailEnabled        bool
+	EmailTo             string
+	CurlMaxTime         int
+	Insecure            bool
+	LogFile             string
+	DisableLogging      bool
+	Live                bool          // show progress while the run is going
+	Dashboard           string        // address of the live web dashboard, e.g. :8080
+	DashboardLinger     time.Duration // how long the final report is served, 0 until interrupted from a terminal
+	RequestBody         string
+	ResponsePattern     string
+	JSONOutput          bool
+	JSONSamples         bool // include the raw samples in results.json
+	Percentiles         []float64
+	MaxSamples          int
+	TimelineBucket      time.Duration // width of the buckets of the reported timeline
+	ExpectedInterval    time.Duration
+	Scenario            []Request
+	Requests            []Request
+	Feeders             []Feeder
+	Checks              []Check // run on the responses to every request
+	Thresholds          []threshold.Threshold
+	AbortOnFail         bool          // stop the run when a threshold fails over ThresholdWindow
+	ThresholdWindow     time.Duration // sliding window thresholds are watched over
+	ThresholdGrace      time.Duration // time after the first response before a run can be aborted
+}
+
+// Load builds a Config from the environment after reading envFile into it.
+// Variables that are already set in the environment take precedence over the
+// file. A missing env file is only an error when required is set.
+func Load(envFile string, required bool) (Config, error) {
+	err := godotenv.Load(envFile)
+	if err != nil && (required || !errors.Is(err, fs.ErrNotExist)) {
+		return Config{}, fmt.Errorf("error loading %s file: %w", envFile, err)
 	}
 
 	config := Config{
-		N:                getEnvAsInt("REQUESTS", 1000),
-		Threads:          getEnvAsInt("THREADS", 10),
-		URL:              os.Getenv("URL"),
-		Method:           os.Getenv("METHOD"),
-		Headers:          make(map[string]string),
-		RetryLimit:       getEnvAsInt("RETRY_LIMIT", 3),
-		ThresholdTime:    getEnvAsFloat("THRESHOLD_TIME", 1.0),
-		ThresholdSuccess: getEnvAsFloat("THRESHOLD_SUCCESS", 95.0),
-		EmailEnabled:     getEnvAsBool("EMAIL_ENABLED", false),
-		EmailTo:          os.Getenv("EMAIL_TO"),
-		CurlMaxTime:      getEnvAsInt("CURL_MAX_TIME", 10),
-		LogFile:          os.Getenv("LOG_FILE"),
-		DisableLogging:   getEnvAsBool("DISABLE_LOGGING", false),
-		RequestBody:      os.Getenv("REQUEST_BODY"),
-		ResponsePattern:  os.Getenv("RESPONSE_PATTERN"),
-		JSONOutput:       getEnvAsBool("JSON_OUTPUT", false),
-	}
-
-	return promptForOverrides(config)
-}
-
-func promptForOverrides(config Config) (Config, error) {
+		N:                   getEnvAsInt("REQUESTS", 1000),
+		Duration:            getEnvAsDuration("DURATION", 0),
+		Threads:             getEnvAsInt("THREADS", 10),
+		Stages:              getEnvAsStages("STAGES"),
+		Rate:                getEnvAsRate("RATE", 0),
+		MaxInFlight:         getEnvAsInt("MAX_IN_FLIGHT", 100),
+		URL:                 os.Getenv("URL"),
+		Method:              os.Getenv("METHOD"),
+		BearerToken:         os.Getenv("BEARER_TOKEN"),
+		Headers:             ParseHeaders(os.Getenv("CUSTOM_HEADERS")),
+		RetryLimit:          getEnvAsInt("RETRY_LIMIT", 3),
+		ThresholdTime:       getEnvAsFloat("THRESHOLD_TIME", 1.0),
+		ThresholdSuccess:    getEnvAsFloat("THRESHOLD_SUCCESS", 95.0),
+		ThresholdTimeSet:    os.Getenv("THRESHOLD_TIME") != "",
+		ThresholdSuccessSet: os.Getenv("THRESHOLD_SUCCESS") != "",
+		EmailEnabled:        getEnvAsBool("EMAIL_ENABLED", false),
+		EmailTo:             os.Getenv("EMAIL_TO"),
+		CurlMaxTime:         getEnvAsInt("CURL_MAX_TIME", 10),
+		Insecure:            getEnvAsBool("INSECURE", false),
+		LogFile:             os.Getenv("LOG_FILE"),
+		DisableLogging:      getEnvAsBool("DISABLE_LOGGING", false),
+		Live:                getEnvAsBool("LIVE", true),
+		Dashboard:           os.Getenv("DASHBOARD"),
+		DashboardLinger:     getEnvAsDuration("DASHBOARD_LINGER", 0),
+		RequestBody:         os.Getenv("REQUEST_BODY"),
+		ResponsePattern:     os.Getenv("RESPONSE_PATTERN"),
+		JSONOutput:          getEnvAsBool("JSON_OUTPUT", false),
+		JSONSamples:         getEnvAsBool("JSON_SAMPLES", false),
+		Percentiles:         getEnvAsPercentiles("PERCENTILES"),
+		MaxSamples:          getEnvAsInt("MAX_SAMPLES", 100000),
+		TimelineBucket:      getEnvAsDuration("TIMELINE_BUCKET", time.Second),
+		ExpectedInterval:    getEnvAsDuration("EXPECTED_INTERVAL", 0),
+		Feeders:             getEnvAsFeeders("DATA"),
+		Thresholds:          getEnvAsThresholds("THRESHOLDS"),
+		AbortOnFail:         getEnvAsBool("ABORT_ON_FAIL", false),
+		ThresholdWindow:     getEnvAsDuration("THRESHOLD_WINDOW", 30*time.Second),
+		ThresholdGrace:      getEnvAsDuration("THRESHOLD_GRACE", 10*time.Second),
+	}
+
+	return config, nil
+}
+
+// PromptForOverrides asks for every setting on stdin, keeping the current
+// value when the answer is empty.
+func PromptForOverrides(config Config) (Config, error) {
 	fmt.Println("\n🔧 Enter configuration values. Press Enter to keep default.")
 
 	config.URL = promptString("URL to test", config.URL)
@@ -69,11 +189,23 @@ func promptForOverrides(config Config) (Config, error) {
 		config.RequestBody = promptString("Request body (leave empty if not needed)", config.RequestBody)
 	}
 
-	config.N = promptInt("Number of requests", config.N)
+	config.Duration = promptDuration("Test duration (e.g. 30m, 0 to use the number of requests)", config.Duration)
+	if config.Duration == 0 {
+		config.N = promptInt("Number of requests", config.N)
+	}
 	config.Threads = promptInt("Number of concurrent threads", config.Threads)
+	config.Stages = promptStages("Load stages (duration:threads,... e.g. 30s:10,2m:50,30s:0, leave empty to start all threads at once)", config.Stages)
+	config.Rate = promptRate("Arrival rate (e.g. 200/s, 0 to keep a fixed number of threads busy)", config.Rate)
+	if config.Rate > 0 {
+		config.MaxInFlight = promptInt("Maximum in-flight requests", config.MaxInFlight)
+	}
 	config.RetryLimit = promptInt("Retry limit for failed requests", config.RetryLimit)
-	config.ThresholdTime = promptFloat("Response time threshold in seconds", config.ThresholdTime)
-	config.ThresholdSuccess = promptFloat("Success rate threshold in percentage", config.ThresholdSuccess)
+	if value := promptFloat("Response time threshold in seconds", config.ThresholdTime); value != config.ThresholdTime {
+		config.ThresholdTime, config.ThresholdTimeSet = value, true
+	}
+	if value := promptFloat("Success rate threshold in percentage", config.ThresholdSuccess); value != config.ThresholdSuccess {
+		config.ThresholdSuccess, config.ThresholdSuccessSet = value, true
+	}
 	config.CurlMaxTime = promptInt("Curl max-time in seconds", config.CurlMaxTime)
 
 	config.ResponsePattern = promptString("Response validation pattern (regex, leave empty if not needed)", config.ResponsePattern)
@@ -81,12 +213,11 @@ func promptForOverrides(config Config) (Config, error) {
 	config.BearerToken = promptString("Bearer Token (leave empty if not needed)", config.BearerToken)
 	headersInput := promptString("Custom headers (key1:value1,key2:value2)", "")
 	if headersInput != "" {
-		headerPairs := strings.Split(headersInput, ",")
-		for _, pair := range headerPairs {
-			kv := strings.SplitN(pair, ":", 2)
-			if len(kv) == 2 {
-				config.Headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
-			}
+		if config.Headers == nil {
+			config.Headers = make(map[string]string)
+		}
+		for key, value := range ParseHeaders(headersInput) {
+			config.Headers[key] = value
 		}
 	}
 
@@ -102,13 +233,79 @@ func promptForOverrides(config Config) (Config, error) {
 	return config, nil
 }
 
+// EndpointThresholds returns the response time and success rate thresholds
+// for the named scenario step or traffic mix endpoint.
+func (c Config) EndpointThresholds(name string) (time float64, success float64) {
+	time, success = c.ThresholdTime, c.ThresholdSuccess
+	for _, defs := range [][]Request{c.Scenario, c.Requests} {
+		for _, def := range defs {
+			if def.Name != name {
+				continue
+			}
+			if def.ThresholdTime > 0 {
+				time = def.ThresholdTime
+			}
+			if def.ThresholdSuccess > 0 {
+				success = def.ThresholdSuccess
+			}
+		}
+	}
+	return time, success
+}
+
+// AllThresholds returns the thresholds a run is judged by: the average
+// response time and success rate thresholds, for the whole run and for
+// every named request, followed by the configured threshold expressions.
+// The run-wide average response time and success rate only count when they
+// were set explicitly, and named requests inherit them only then.
+func (c Config) AllThresholds() []threshold.Threshold {
+	var thresholds []threshold.Threshold
+	add := func(expr, endpoint string) {
+		t, err := threshold.Parse(expr)
+		if err == nil && endpoint != "" {
+			t, err = t.ForEndpoint(endpoint)
+		}
+		if err == nil {
+			thresholds = append(thresholds, t)
+		}
+	}
+	legacy := func(seconds, success float64, endpoint string) {
+		if seconds > 0 {
+			add(fmt.Sprintf("avg <= %s", secondsDuration(seconds)), endpoint)
+		}
+		if success > 0 {
+			add(fmt.Sprintf("success_rate >= %g%%", success), endpoint)
+		}
+	}
+
+	explicit := c
+	if !c.ThresholdTimeSet {
+		explicit.ThresholdTime = 0
+	}
+	if !c.ThresholdSuccessSet {
+		explicit.ThresholdSuccess = 0
+	}
+	legacy(explicit.ThresholdTime, explicit.ThresholdSuccess, "")
+	for _, defs := range [][]Request{c.Scenario, c.Requests} {
+		for _, def := range defs {
+			seconds, success := explicit.EndpointThresholds(def.Name)
+			legacy(seconds, success, def.Name)
+		}
+	}
+	return append(thresholds, c.Thresholds...)
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Microsecond)
+}
+
 func SetupLogging(config Config) error {
 	if config.DisableLogging {
 		log.SetOutput(io.Discard)
 		return nil
 	}
 
-	writers := []io.Writer{os.Stdout}
+	writers := []io.Writer{console}
 
 	if config.LogFile != "" {
 		file, err := os.OpenFile(config.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
@@ -123,12 +320,59 @@ func SetupLogging(config Config) error {
 	return nil
 }
 
+// console is the terminal part of the log output. Live displays pause it
+// while they draw on the terminal; the log file still gets every line.
+var console = &consoleWriter{w: os.Stdout}
+
+type consoleWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	paused bool
+}
+
+func (c *consoleWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		return len(p), nil
+	}
+	return c.w.Write(p)
+}
+
+// PauseConsoleLog stops log lines from reaching the terminal until the
+// returned function is called.
+func PauseConsoleLog() (resume func()) {
+	console.mu.Lock()
+	console.paused = true
+	console.mu.Unlock()
+	return func() {
+		console.mu.Lock()
+		console.paused = false
+		console.mu.Unlock()
+	}
+}
+
 func Cleanup() {
 	log.Println("Performing cleanup...")
 	// Add cleanup logic here
 	log.Println("Cleanup completed.")
 }
 
+// ParseHeaders parses headers in the form "key1:value1,key2:value2".
+func ParseHeaders(input string) map[string]string {
+	headers := make(map[string]string)
+	if strings.TrimSpace(input) == "" {
+		return headers
+	}
+	for _, pair := range strings.Split(input, ",") {
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) == 2 {
+			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return headers
+}
+
 func getEnvAsInt(name string, defaultVal int) int {
 	valueStr := os.Getenv(name)
 	if value, err := strconv.Atoi(valueStr); err == nil {
@@ -145,6 +389,184 @@ func getEnvAsFloat(name string, defaultVal float64) float64 {
 	return defaultVal
 }
 
+func getEnvAsDuration(name string, defaultVal time.Duration) time.Duration {
+	valueStr := os.Getenv(name)
+	if value, err := time.ParseDuration(valueStr); err == nil {
+		return value
+	}
+	return defaultVal
+}
+
+func getEnvAsRate(name string, defaultVal float64) float64 {
+	valueStr := os.Getenv(name)
+	if value, err := ParseRate(valueStr); err == nil {
+		return value
+	}
+	return defaultVal
+}
+
+// ParseRate parses an arrival rate such as "200", "200/s", "1200/m" or
+// "5000/h" and returns it in requests per second.
+func ParseRate(input string) (float64, error) {
+	input = strings.TrimSpace(input)
+	amount, unit, found := strings.Cut(input, "/")
+	value, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %v", input, err)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("invalid rate %q: must not be negative", input)
+	}
+	if !found {
+		return value, nil
+	}
+
+	switch strings.TrimSpace(unit) {
+	case "s", "1s":
+		return value, nil
+	case "m", "1m":
+		return value / 60, nil
+	case "h", "1h":
+		return value / 3600, nil
+	default:
+		return 0, fmt.Errorf("invalid rate %q: unknown unit %q", input, unit)
+	}
+}
+
+func getEnvAsStages(name string) []Stage {
+	stages, err := ParseStages(os.Getenv(name))
+	if err != nil {
+		log.Printf("Ignoring %s: %v", name, err)
+		return nil
+	}
+	return stages
+}
+
+// ParseStages parses a load profile such as "30s:10,2m:50,30s:0". Each stage
+// moves the number of threads linearly towards its target over its duration.
+func ParseStages(input string) ([]Stage, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
+	}
+
+	var stages []Stage
+	for _, part := range strings.Split(input, ",") {
+		durationStr, targetStr, found := strings.Cut(strings.TrimSpace(part), ":")
+		if !found {
+			return nil, fmt.Errorf("invalid stage %q: expected duration:threads", part)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(durationStr))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid stage duration %q", durationStr)
+		}
+		target, err := strconv.Atoi(strings.TrimSpace(targetStr))
+		if err != nil || target < 0 {
+			return nil, fmt.Errorf("invalid stage target %q", targetStr)
+		}
+		stages = append(stages, Stage{Duration: duration, Target: target})
+	}
+	return stages, nil
+}
+
+// FormatStages is the inverse of ParseStages.
+func FormatStages(stages []Stage) string {
+	parts := make([]string, len(stages))
+	for i, stage := range stages {
+		parts[i] = fmt.Sprintf("%s:%d", stage.Duration, stage.Target)
+	}
+	return strings.Join(parts, ",")
+}
+
+func getEnvAsPercentiles(name string) []float64 {
+	percentiles, err := ParsePercentiles(os.Getenv(name))
+	if err != nil {
+		log.Printf("Ignoring %s: %v", name, err)
+		return nil
+	}
+	return percentiles
+}
+
+// ParsePercentiles parses a comma separated list such as "50,90,99,99.9".
+func ParsePercentiles(input string) ([]float64, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
+	}
+
+	var percentiles []float64
+	for _, part := range strings.Split(input, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || value <= 0 || value > 100 {
+			return nil, fmt.Errorf("invalid percentile %q: must be a number in (0, 100]", part)
+		}
+		percentiles = append(percentiles, value)
+	}
+	return percentiles, nil
+}
+
+func getEnvAsFeeders(name string) []Feeder {
+	feeders, err := ParseFeeders(os.Getenv(name))
+	if err != nil {
+		log.Printf("Ignoring %s: %v", name, err)
+		return nil
+	}
+	return feeders
+}
+
+// ParseFeeders parses a comma separated list of data files such as
+// "users.csv:unique:stop,products.jsonl:random". Strategy and policy are
+// optional and default to sequential and recycle.
+func ParseFeeders(input string) ([]Feeder, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
+	}
+
+	var feeders []Feeder
+	for _, part := range strings.Split(input, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ":")
+		if len(fields) > 3 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid data file %q: expected file[:strategy[:policy]]", part)
+		}
+		feeder := Feeder{File: fields[0], Strategy: FeedSequential, OnExhausted: FeedRecycle}
+		if len(fields) > 1 {
+			feeder.Strategy = fields[1]
+		}
+		if len(fields) > 2 {
+			feeder.OnExhausted = fields[2]
+		}
+		if err := feeder.check(); err != nil {
+			return nil, err
+		}
+		feeders = append(feeders, feeder)
+	}
+	return feeders, nil
+}
+
+func (f Feeder) check() error {
+	switch f.Strategy {
+	case FeedSequential, FeedRandom, FeedUnique:
+	default:
+		return fmt.Errorf("data file %s: unknown strategy %q (sequential, random or unique)", f.File, f.Strategy)
+	}
+	switch f.OnExhausted {
+	case FeedRecycle, FeedStop:
+	default:
+		return fmt.Errorf("data file %s: unknown exhaustion policy %q (recycle or stop)", f.File, f.OnExhausted)
+	}
+	return nil
+}
+
+func getEnvAsThresholds(name string) []threshold.Threshold {
+	thresholds, err := threshold.ParseList(os.Getenv(name))
+	if err != nil {
+		log.Printf("Ignoring %s: %v", name, err)
+		return nil
+	}
+	return thresholds
+}
+
 func getEnvAsBool(name string, defaultVal bool) bool {
 	valueStr := os.Getenv(name)
 	if value, err := strconv.ParseBool(valueStr); err == nil {
@@ -182,6 +604,34 @@ func promptFloat(prompt string, defaultValue float64) float64 {
 	return value
 }
 
+func promptDuration(prompt string, defaultValue time.Duration) time.Duration {
+	input := promptString(prompt, defaultValue.String())
+	value, err := time.ParseDuration(input)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func promptRate(prompt string, defaultValue float64) float64 {
+	input := promptString(prompt, fmt.Sprintf("%g/s", defaultValue))
+	value, err := ParseRate(input)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func promptStages(prompt string, defaultValue []Stage) []Stage {
+	input := promptString(prompt, FormatStages(defaultValue))
+	value, err := ParseStages(input)
+	if err != nil {
+		fmt.Printf("Invalid stages, keeping default: %v\n", err)
+		return defaultValue
+	}
+	return value
+}
+
 func promptBool(prompt string, defaultValue bool) bool {
 	input := promptString(prompt, fmt.Sprintf("%v", defaultValue))
 	value, err := strconv.ParseBool(input)
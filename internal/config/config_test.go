@@ -0,0 +1,69 @@
+package config
+
+import (
+	"flag"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func thresholdExprs(cfg Config) []string {
+	var exprs []string
+	for _, t := range cfg.AllThresholds() {
+		exprs = append(exprs, t.Expr)
+	}
+	return exprs
+}
+
+func TestAllThresholdsOnlyJudgeExplicitLegacyValues(t *testing.T) {
+	noEnvFile := filepath.Join(t.TempDir(), ".env")
+	t.Setenv("THRESHOLD_TIME", "")
+	t.Setenv("THRESHOLD_SUCCESS", "")
+	t.Setenv("THRESHOLDS", "")
+
+	defaults, err := Load(noEnvFile, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults.Requests = []Request{{Name: "search"}, {Name: "login", ThresholdTime: 0.2}}
+	if defaults.ThresholdTime != 1 || defaults.ThresholdSuccess != 95 {
+		t.Errorf("defaults = %gs, %g%%, want 1s, 95%%", defaults.ThresholdTime, defaults.ThresholdSuccess)
+	}
+	if got, want := thresholdExprs(defaults), []string{`avg{endpoint="login"} <= 200ms`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with the defaults: %q, want only the request's own %q", got, want)
+	}
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("THRESHOLD_SUCCESS", "99")
+		cfg, err := Load(noEnvFile, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := thresholdExprs(cfg), []string{"success_rate >= 99%"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%q, want %q", got, want)
+		}
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		cfg := defaults
+		fs := flag.NewFlagSet("run", flag.ContinueOnError)
+		BindFlags(fs, &cfg)
+		if err := fs.Parse([]string{"-threshold-time", "0.5"}); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"avg <= 500ms", `avg{endpoint="search"} <= 500ms`, `avg{endpoint="login"} <= 200ms`}
+		if got := thresholdExprs(cfg); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q, want %q", got, want)
+		}
+	})
+
+	t.Run("plan", func(t *testing.T) {
+		_, cfg, err := loadAndApply(t, "plan.yaml", "version: 1\nthresholds:\n  response_time: 2s\n  success_rate: 90\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := thresholdExprs(cfg), []string{"avg <= 2s", "success_rate >= 90%"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"stormforce/internal/threshold"
+)
+
+// BindFlags registers a command line flag for every Config field on fs.
+// Flags default to the current values in config, so binding after Load gives
+// flags precedence over the environment and the .env file.
+func BindFlags(fs *flag.FlagSet, config *Config) {
+	fs.StringVar(&config.URL, "url", config.URL, "URL to test")
+	fs.StringVar(&config.Method, "method", config.Method, "HTTP method")
+	fs.StringVar(&config.RequestBody, "body", config.RequestBody, "request body for POST requests")
+	fs.StringVar(&config.BearerToken, "bearer-token", config.BearerToken, "bearer token sent in the Authorization header")
+	fs.Func("header", "custom header as 'Key: Value' (repeatable)", func(value string) error {
+		key, val, found := strings.Cut(value, ":")
+		if !found {
+			return fmt.Errorf("expected 'Key: Value', got %q", value)
+		}
+		if config.Headers == nil {
+			config.Headers = make(map[string]string)
+		}
+		config.Headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		return nil
+	})
+
+	fs.IntVar(&config.N, "requests", config.N, "number of requests (ignored when a duration or stages are set)")
+	fs.DurationVar(&config.Duration, "duration", config.Duration, "run for a fixed time instead of a number of requests, e.g. 30m")
+	fs.IntVar(&config.Threads, "threads", config.Threads, "number of concurrent threads")
+	fs.Func("stages", "ramp profile as duration:threads,... e.g. 30s:10,2m:50,30s:0", func(value string) error {
+		stages, err := ParseStages(value)
+		config.Stages = stages
+		return err
+	})
+	fs.Func("rate", fmt.Sprintf("open model arrival rate, e.g. 200/s (default %g/s)", config.Rate), func(value string) error {
+		rate, err := ParseRate(value)
+		config.Rate = rate
+		return err
+	})
+	fs.IntVar(&config.MaxInFlight, "max-in-flight", config.MaxInFlight, "maximum concurrent requests for an arrival rate")
+
+	fs.Func("data", "data files as file[:strategy[:policy]],... e.g. users.csv:unique:stop", func(value string) error {
+		feeders, err := ParseFeeders(value)
+		config.Feeders = feeders
+		return err
+	})
+
+	fs.IntVar(&config.RetryLimit, "retry-limit", config.RetryLimit, "attempts per request on network errors")
+	fs.IntVar(&config.CurlMaxTime, "max-time", config.CurlMaxTime, "request timeout in seconds")
+	fs.BoolVar(&config.Insecure, "insecure", config.Insecure, "skip TLS certificate verification")
+	fs.StringVar(&config.ResponsePattern, "response-pattern", config.ResponsePattern, "regex the response body must match")
+	fs.Var(explicitFloat{&config.ThresholdTime, &config.ThresholdTimeSet}, "threshold-time", "average response time threshold in seconds")
+	fs.Var(explicitFloat{&config.ThresholdSuccess, &config.ThresholdSuccessSet}, "threshold-success", "success rate threshold in percent")
+	fs.Func("threshold", "threshold the run must meet, e.g. 'p95 < 300ms' or 'error_rate{endpoint=search} < 1%' (repeatable)", func(value string) error {
+		t, err := threshold.Parse(value)
+		config.Thresholds = append(config.Thresholds, t)
+		return err
+	})
+
+	fs.BoolVar(&config.AbortOnFail, "abort-on-fail", config.AbortOnFail, "stop the run as soon as a threshold fails over the threshold window")
+	fs.DurationVar(&config.ThresholdWindow, "threshold-window", config.ThresholdWindow, "sliding window thresholds are watched over with -abort-on-fail")
+	fs.DurationVar(&config.ThresholdGrace, "threshold-grace", config.ThresholdGrace, "time after the first response before -abort-on-fail can stop the run")
+
+	fs.Func("percentiles", "response time percentiles to report, e.g. 50,90,99,99.9", func(value string) error {
+		percentiles, err := ParsePercentiles(value)
+		config.Percentiles = percentiles
+		return err
+	})
+	fs.IntVar(&config.MaxSamples, "max-samples", config.MaxSamples, "raw samples kept for charts and JSON (0 keeps all)")
+	fs.DurationVar(&config.TimelineBucket, "timeline-bucket", config.TimelineBucket, "width of the time buckets of the timeline in charts and JSON")
+	fs.DurationVar(&config.ExpectedInterval, "expected-interval", config.ExpectedInterval, "expected interval for coordinated omission correction (0 uses the median)")
+
+	fs.BoolVar(&config.EmailEnabled, "email", config.EmailEnabled, "enable email notifications")
+	fs.StringVar(&config.EmailTo, "email-to", config.EmailTo, "email address for notifications")
+	fs.StringVar(&config.LogFile, "log-file", config.LogFile, "log file path")
+	fs.BoolVar(&config.DisableLogging, "disable-logging", config.DisableLogging, "disable logging")
+	fs.BoolVar(&config.Live, "live", config.Live, "show live progress during the run; log lines then only go to the log file")
+	fs.StringVar(&config.Dashboard, "dashboard", config.Dashboard, "serve a live web dashboard on this address, e.g. :8080")
+	fs.DurationVar(&config.DashboardLinger, "dashboard-linger", config.DashboardLinger, "how long the dashboard serves the final report after the run (0 until interrupted from a terminal)")
+	fs.BoolVar(&config.JSONOutput, "json", config.JSONOutput, "write results.json")
+	fs.BoolVar(&config.JSONSamples, "json-samples", config.JSONSamples, "include the raw samples in results.json, so the report command can chart them")
+}
+
+// explicitFloat is a float flag that records when it is set.
+type explicitFloat struct {
+	value *float64
+	set   *bool
+}
+
+func (f explicitFloat) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f.value, 'g', -1, 64)
+}
+
+func (f explicitFloat) Set(s string) error {
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*f.value, *f.set = value, true
+	return nil
+}
@@ -0,0 +1,718 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"stormforce/internal/schema"
+	"stormforce/internal/threshold"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PlanVersion is the test plan format version this build understands.
+const PlanVersion = 1
+
+// Plan is a declarative test plan loaded from a YAML or JSON file. Every
+// field is optional; fields that are set override the environment and are
+// in turn overridden by command line flags.
+type Plan struct {
+	Version    int            `yaml:"version,omitempty"`
+	Name       string         `yaml:"name,omitempty"`
+	Target     PlanTarget     `yaml:"target,omitempty"`
+	Scenario   []PlanRequest  `yaml:"scenario,omitempty"`
+	Requests   []PlanRequest  `yaml:"requests,omitempty"`
+	Data       []PlanFeeder   `yaml:"data,omitempty"`
+	Checks     []PlanCheck    `yaml:"checks,omitempty"`
+	Load       PlanLoad       `yaml:"load,omitempty"`
+	Thresholds PlanThresholds `yaml:"thresholds,omitempty"`
+	Outputs    PlanOutputs    `yaml:"outputs,omitempty"`
+
+	file string
+	root *yaml.Node
+}
+
+type PlanTarget struct {
+	URL             string            `yaml:"url,omitempty"`
+	Method          string            `yaml:"method,omitempty"`
+	Headers         map[string]string `yaml:"headers,omitempty"`
+	Body            string            `yaml:"body,omitempty"`
+	BearerToken     string            `yaml:"bearer_token,omitempty"`
+	ResponsePattern string            `yaml:"response_pattern,omitempty"`
+	Insecure        *bool             `yaml:"insecure,omitempty"`
+}
+
+// PlanRequest is a scenario step or an entry of the traffic mix.
+type PlanRequest struct {
+	Name           string            `yaml:"name,omitempty"`
+	Method         string            `yaml:"method,omitempty"`
+	URL            string            `yaml:"url,omitempty"`
+	Headers        map[string]string `yaml:"headers,omitempty"`
+	Body           string            `yaml:"body,omitempty"`
+	Extract        []PlanExtractor   `yaml:"extract,omitempty"`
+	Weight         *float64          `yaml:"weight,omitempty"`
+	ThinkTime      string            `yaml:"think_time,omitempty"`
+	ResponseSchema interface{}       `yaml:"response_schema,omitempty"`
+	Checks         []PlanCheck       `yaml:"checks,omitempty"`
+	Tags           []string          `yaml:"tags,omitempty"`
+	Thresholds     PlanThresholds    `yaml:"thresholds,omitempty"`
+}
+
+// PlanExtractor sets exactly one of JSON, Regex or Header.
+type PlanExtractor struct {
+	Var    string `yaml:"var,omitempty"`
+	JSON   string `yaml:"json,omitempty"`
+	Regex  string `yaml:"regex,omitempty"`
+	Header string `yaml:"header,omitempty"`
+}
+
+// PlanCheck is a named check on responses. It sets exactly one of status,
+// header, json, body_size, latency or schema. Header and JSON checks take
+// one operator: equals, contains, matches, exists or, for JSON, type. A
+// check with an operator only applies it to the body.
+type PlanCheck struct {
+	Name     string        `yaml:"name,omitempty"`
+	Status   interface{}   `yaml:"status,omitempty"` // 200, "2xx", "200-299" or a list of them
+	Header   string        `yaml:"header,omitempty"`
+	JSON     string        `yaml:"json,omitempty"`
+	BodySize *PlanBodySize `yaml:"body_size,omitempty"`
+	Latency  string        `yaml:"latency,omitempty"`
+	Schema   interface{}   `yaml:"schema,omitempty"`
+	Equals   *string       `yaml:"equals,omitempty"`
+	Contains *string       `yaml:"contains,omitempty"`
+	Matches  string        `yaml:"matches,omitempty"`
+	Exists   *bool         `yaml:"exists,omitempty"`
+	Type     string        `yaml:"type,omitempty"`
+}
+
+// PlanBodySize bounds the response body size in bytes.
+type PlanBodySize struct {
+	Min int64 `yaml:"min,omitempty"`
+	Max int64 `yaml:"max,omitempty"`
+}
+
+// PlanFeeder is a data file. A relative path is resolved against the
+// directory of the plan.
+type PlanFeeder struct {
+	File        string `yaml:"file,omitempty"`
+	Strategy    string `yaml:"strategy,omitempty"`
+	OnExhausted string `yaml:"on_exhausted,omitempty"`
+}
+
+type PlanLoad struct {
+	Requests         *int        `yaml:"requests,omitempty"`
+	Duration         string      `yaml:"duration,omitempty"`
+	Threads          *int        `yaml:"threads,omitempty"`
+	Stages           []PlanStage `yaml:"stages,omitempty"`
+	Rate             string      `yaml:"rate,omitempty"`
+	MaxInFlight      *int        `yaml:"max_in_flight,omitempty"`
+	RetryLimit       *int        `yaml:"retry_limit,omitempty"`
+	Timeout          string      `yaml:"timeout,omitempty"`
+	ExpectedInterval string      `yaml:"expected_interval,omitempty"`
+}
+
+type PlanStage struct {
+	Duration string `yaml:"duration,omitempty"`
+	Target   int    `yaml:"target,omitempty"`
+}
+
+// PlanThresholds sets the average response time and success rate
+// thresholds and threshold expressions such as "p95 < 300ms". The
+// expressions of a request apply to that request. AbortOnFail, Window and
+// GracePeriod only apply to the whole plan.
+type PlanThresholds struct {
+	ResponseTime string   `yaml:"response_time,omitempty"`
+	SuccessRate  *float64 `yaml:"success_rate,omitempty"`
+	Rules        []string `yaml:"rules,omitempty"`
+	AbortOnFail  *bool    `yaml:"abort_on_fail,omitempty"`
+	Window       string   `yaml:"window,omitempty"`
+	GracePeriod  string   `yaml:"grace_period,omitempty"`
+}
+
+type PlanOutputs struct {
+	JSON           *bool     `yaml:"json,omitempty"`
+	JSONSamples    *bool     `yaml:"json_samples,omitempty"`
+	LogFile        *string   `yaml:"log_file,omitempty"`
+	DisableLogging *bool     `yaml:"disable_logging,omitempty"`
+	Live           *bool     `yaml:"live,omitempty"`
+	Dashboard      *string   `yaml:"dashboard,omitempty"`
+	Percentiles    []float64 `yaml:"percentiles,omitempty"`
+	MaxSamples     *int      `yaml:"max_samples,omitempty"`
+	TimelineBucket string    `yaml:"timeline_bucket,omitempty"`
+}
+
+// PlanError is a problem at a specific place in a plan file.
+type PlanError struct {
+	File string
+	Line int
+	Path string
+	Msg  string
+}
+
+func (e *PlanError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s", location, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, e.Path, e.Msg)
+}
+
+var yamlLinePrefix = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// LoadPlan reads and strictly decodes a plan file. JSON plans are parsed by
+// the same decoder since JSON is valid YAML. Unknown fields, wrong types and
+// an unsupported version are reported with their line numbers.
+func LoadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan: %v", err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, yamlErrors(path, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, &PlanError{File: path, Msg: "plan is empty"}
+	}
+
+	var plan Plan
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&plan); err != nil {
+		return nil, yamlErrors(path, err)
+	}
+	plan.file = path
+	plan.root = root.Content[0]
+
+	if plan.Version != PlanVersion {
+		return nil, plan.errorAt(fmt.Sprintf("unsupported version %d, expected %d", plan.Version, PlanVersion), "version")
+	}
+	return &plan, nil
+}
+
+// yamlErrors rewrites decoder errors as file:line messages.
+func yamlErrors(path string, err error) error {
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	var problems []error
+	for _, message := range messages {
+		problem := &PlanError{File: path, Msg: message}
+		if m := yamlLinePrefix.FindStringSubmatch(message); m != nil {
+			problem.Line, _ = strconv.Atoi(m[1])
+			problem.Msg = m[2]
+		}
+		problems = append(problems, problem)
+	}
+	return errors.Join(problems...)
+}
+
+// Apply copies every setting present in the plan onto config. All invalid
+// values are reported together with the line they appear on.
+func (p *Plan) Apply(config *Config) error {
+	var problems []error
+	fail := func(msg string, path ...string) {
+		problems = append(problems, p.errorAt(msg, path...))
+	}
+	duration := func(value string, path ...string) (time.Duration, bool) {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			fail(fmt.Sprintf("invalid duration %q", value), path...)
+			return 0, false
+		}
+		return d, true
+	}
+
+	t := p.Target
+	if t.URL != "" {
+		config.URL = t.URL
+	}
+	if t.Method != "" {
+		config.Method = strings.ToUpper(t.Method)
+	}
+	if t.Body != "" {
+		config.RequestBody = t.Body
+	}
+	if t.BearerToken != "" {
+		config.BearerToken = t.BearerToken
+	}
+	if t.ResponsePattern != "" {
+		if _, err := regexp.Compile(t.ResponsePattern); err != nil {
+			fail(fmt.Sprintf("invalid regex: %v", err), "target", "response_pattern")
+		}
+		config.ResponsePattern = t.ResponsePattern
+	}
+	if t.Insecure != nil {
+		config.Insecure = *t.Insecure
+	}
+	for key, value := range t.Headers {
+		if config.Headers == nil {
+			config.Headers = make(map[string]string)
+		}
+		config.Headers[key] = value
+	}
+
+	if len(p.Scenario) > 0 {
+		config.Scenario = p.requests("scenario", p.Scenario, false, fail)
+	}
+	if len(p.Requests) > 0 {
+		config.Requests = p.requests("requests", p.Requests, true, fail)
+	}
+	if len(p.Scenario) > 0 && len(p.Requests) > 0 {
+		fail("a scenario cannot be combined with a traffic mix", "requests")
+	}
+
+	if len(p.Checks) > 0 {
+		config.Checks = p.checks(p.Checks, nil, fail)
+	}
+
+	if len(p.Data) > 0 {
+		config.Feeders = nil
+		for i, pf := range p.Data {
+			index := strconv.Itoa(i)
+			feeder := Feeder{File: pf.File, Strategy: pf.Strategy, OnExhausted: pf.OnExhausted}
+			if feeder.File == "" {
+				fail("file is required", "data", index)
+			} else if !filepath.IsAbs(feeder.File) {
+				feeder.File = filepath.Join(filepath.Dir(p.file), feeder.File)
+			}
+			if feeder.Strategy == "" {
+				feeder.Strategy = FeedSequential
+			}
+			if feeder.OnExhausted == "" {
+				feeder.OnExhausted = FeedRecycle
+			}
+			if err := feeder.check(); err != nil {
+				fail(err.Error(), "data", index)
+			}
+			config.Feeders = append(config.Feeders, feeder)
+		}
+	}
+
+	l := p.Load
+	if l.Requests != nil {
+		if *l.Requests < 1 {
+			fail("must be at least 1", "load", "requests")
+		}
+		config.N = *l.Requests
+	}
+	if l.Duration != "" {
+		if d, ok := duration(l.Duration, "load", "duration"); ok {
+			config.Duration = d
+		}
+	}
+	if l.Threads != nil {
+		if *l.Threads < 1 {
+			fail("must be at least 1", "load", "threads")
+		}
+		config.Threads = *l.Threads
+	}
+	if len(l.Stages) > 0 {
+		stages := make([]Stage, 0, len(l.Stages))
+		for i, stage := range l.Stages {
+			index := strconv.Itoa(i)
+			d, ok := duration(stage.Duration, "load", "stages", index, "duration")
+			if ok && d == 0 {
+				fail("must be greater than 0", "load", "stages", index, "duration")
+			}
+			if stage.Target < 0 {
+				fail("must not be negative", "load", "stages", index, "target")
+			}
+			stages = append(stages, Stage{Duration: d, Target: stage.Target})
+		}
+		config.Stages = stages
+	}
+	if l.Rate != "" {
+		rate, err := ParseRate(l.Rate)
+		if err != nil {
+			fail(err.Error(), "load", "rate")
+		}
+		config.Rate = rate
+	}
+	if l.MaxInFlight != nil {
+		if *l.MaxInFlight < 1 {
+			fail("must be at least 1", "load", "max_in_flight")
+		}
+		config.MaxInFlight = *l.MaxInFlight
+	}
+	if l.RetryLimit != nil {
+		if *l.RetryLimit < 0 {
+			fail("must not be negative", "load", "retry_limit")
+		}
+		config.RetryLimit = *l.RetryLimit
+	}
+	if l.Timeout != "" {
+		if d, ok := duration(l.Timeout, "load", "timeout"); ok {
+			config.CurlMaxTime = int(math.Ceil(d.Seconds()))
+		}
+	}
+	if l.ExpectedInterval != "" {
+		if d, ok := duration(l.ExpectedInterval, "load", "expected_interval"); ok {
+			config.ExpectedInterval = d
+		}
+	}
+	if l.Stages != nil && l.Rate != "" {
+		fail("stages cannot be combined with a rate", "load", "stages")
+	}
+
+	th := p.Thresholds
+	if th.ResponseTime != "" {
+		if d, ok := duration(th.ResponseTime, "thresholds", "response_time"); ok {
+			config.ThresholdTime, config.ThresholdTimeSet = d.Seconds(), true
+		}
+	}
+	if th.SuccessRate != nil {
+		if *th.SuccessRate < 0 || *th.SuccessRate > 100 {
+			fail("must be between 0 and 100", "thresholds", "success_rate")
+		}
+		config.ThresholdSuccess, config.ThresholdSuccessSet = *th.SuccessRate, true
+	}
+	if th.AbortOnFail != nil {
+		config.AbortOnFail = *th.AbortOnFail
+	}
+	if th.Window != "" {
+		if d, ok := duration(th.Window, "thresholds", "window"); ok {
+			config.ThresholdWindow = d
+		}
+	}
+	if th.GracePeriod != "" {
+		if d, ok := duration(th.GracePeriod, "thresholds", "grace_period"); ok {
+			config.ThresholdGrace = d
+		}
+	}
+	if len(th.Rules) > 0 {
+		config.Thresholds = nil
+	}
+	for i, expr := range th.Rules {
+		rule, err := threshold.Parse(expr)
+		if err != nil {
+			fail(err.Error(), "thresholds", "rules", strconv.Itoa(i))
+			continue
+		}
+		config.Thresholds = append(config.Thresholds, rule)
+	}
+	for _, defs := range [][]Request{config.Scenario, config.Requests} {
+		for _, def := range defs {
+			config.Thresholds = append(config.Thresholds, def.thresholds...)
+		}
+	}
+
+	o := p.Outputs
+	if o.JSON != nil {
+		config.JSONOutput = *o.JSON
+	}
+	if o.JSONSamples != nil {
+		config.JSONSamples = *o.JSONSamples
+	}
+	if o.LogFile != nil {
+		config.LogFile = *o.LogFile
+	}
+	if o.DisableLogging != nil {
+		config.DisableLogging = *o.DisableLogging
+	}
+	if o.Live != nil {
+		config.Live = *o.Live
+	}
+	if o.Dashboard != nil {
+		config.Dashboard = *o.Dashboard
+	}
+	if len(o.Percentiles) > 0 {
+		for i, percentile := range o.Percentiles {
+			if percentile <= 0 || percentile > 100 {
+				fail("must be in (0, 100]", "outputs", "percentiles", strconv.Itoa(i))
+			}
+		}
+		config.Percentiles = o.Percentiles
+	}
+	if o.MaxSamples != nil {
+		if *o.MaxSamples < 0 {
+			fail("must not be negative", "outputs", "max_samples")
+		}
+		config.MaxSamples = *o.MaxSamples
+	}
+	if o.TimelineBucket != "" {
+		if d, ok := duration(o.TimelineBucket, "outputs", "timeline_bucket"); ok {
+			config.TimelineBucket = d
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// requests converts the plan's scenario steps or traffic mix entries found
+// under key, reporting problems through fail. Only a traffic mix takes
+// weights, which default to 1.
+func (p *Plan) requests(key string, list []PlanRequest, weighted bool, fail func(msg string, path ...string)) []Request {
+	requests := make([]Request, 0, len(list))
+	names := make(map[string]bool)
+	for i, pr := range list {
+		index := strconv.Itoa(i)
+		request := Request{
+			Name:    pr.Name,
+			Method:  strings.ToUpper(pr.Method),
+			URL:     pr.URL,
+			Headers: pr.Headers,
+			Body:    pr.Body,
+			Weight:  1,
+		}
+		if request.Method == "" {
+			request.Method = "GET"
+		}
+		if request.URL == "" {
+			fail("url is required", key, index)
+		}
+		if request.Name == "" {
+			request.Name = fmt.Sprintf("%s %s", request.Method, request.URL)
+		}
+		if names[request.Name] {
+			fail(fmt.Sprintf("duplicate name %q", request.Name), key, index, "name")
+		}
+		names[request.Name] = true
+
+		if pr.Weight != nil {
+			if !weighted {
+				fail("weight only applies to a traffic mix", key, index, "weight")
+			} else if *pr.Weight <= 0 {
+				fail("must be greater than 0", key, index, "weight")
+			}
+			request.Weight = *pr.Weight
+		}
+		if pr.ThinkTime != "" {
+			d, err := time.ParseDuration(pr.ThinkTime)
+			if err != nil || d < 0 {
+				fail(fmt.Sprintf("invalid duration %q", pr.ThinkTime), key, index, "think_time")
+			}
+			request.ThinkTime = d
+		}
+		if pr.ResponseSchema != nil {
+			if _, err := schema.Compile(pr.ResponseSchema); err != nil {
+				fail(err.Error(), key, index, "response_schema")
+			}
+			request.ResponseSchema = pr.ResponseSchema
+		}
+		if pr.Thresholds.ResponseTime != "" {
+			d, err := time.ParseDuration(pr.Thresholds.ResponseTime)
+			if err != nil || d <= 0 {
+				fail(fmt.Sprintf("invalid duration %q", pr.Thresholds.ResponseTime), key, index, "thresholds", "response_time")
+			}
+			request.ThresholdTime = d.Seconds()
+		}
+		if rate := pr.Thresholds.SuccessRate; rate != nil {
+			if *rate < 0 || *rate > 100 {
+				fail("must be between 0 and 100", key, index, "thresholds", "success_rate")
+			}
+			request.ThresholdSuccess = *rate
+		}
+		for j, expr := range pr.Thresholds.Rules {
+			rule, err := threshold.Parse(expr)
+			if err == nil {
+				rule, err = rule.ForEndpoint(request.Name)
+			}
+			if err != nil {
+				fail(err.Error(), key, index, "thresholds", "rules", strconv.Itoa(j))
+				continue
+			}
+			request.thresholds = append(request.thresholds, rule)
+		}
+		if pr.Thresholds.AbortOnFail != nil || pr.Thresholds.Window != "" || pr.Thresholds.GracePeriod != "" {
+			fail("abort_on_fail, window and grace_period only apply to the whole plan", key, index, "thresholds")
+		}
+		request.Tags = pr.Tags
+
+		request.Checks = p.checks(pr.Checks, []string{key, index}, fail)
+
+		for j, pe := range pr.Extract {
+			path := []string{key, index, "extract", strconv.Itoa(j)}
+			if pe.Var == "" {
+				fail("var is required", path...)
+			}
+			var sources []Extractor
+			if pe.JSON != "" {
+				sources = append(sources, Extractor{Var: pe.Var, Source: ExtractJSON, Expr: pe.JSON})
+			}
+			if pe.Regex != "" {
+				if _, err := regexp.Compile(pe.Regex); err != nil {
+					fail(fmt.Sprintf("invalid regex: %v", err), append(path, "regex")...)
+				}
+				sources = append(sources, Extractor{Var: pe.Var, Source: ExtractRegex, Expr: pe.Regex})
+			}
+			if pe.Header != "" {
+				sources = append(sources, Extractor{Var: pe.Var, Source: ExtractHeader, Expr: pe.Header})
+			}
+			if len(sources) != 1 {
+				fail("exactly one of json, regex or header is required", path...)
+				continue
+			}
+			request.Extract = append(request.Extract, sources[0])
+		}
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+// checks converts the checks listed under path, reporting problems through
+// fail.
+func (p *Plan) checks(list []PlanCheck, path []string, fail func(msg string, path ...string)) []Check {
+	var checks []Check
+	names := make(map[string]bool)
+	for i, pc := range list {
+		at := append(append([]string{}, path...), "checks", strconv.Itoa(i))
+		field := func(name string) []string { return append(append([]string{}, at...), name) }
+
+		var kinds []Check
+		if pc.Status != nil {
+			statuses, err := ParseStatuses(statusList(pc.Status))
+			if err != nil {
+				fail(err.Error(), field("status")...)
+			}
+			kinds = append(kinds, Check{Kind: CheckStatus, Statuses: statuses})
+		}
+		if pc.Header != "" {
+			kinds = append(kinds, Check{Kind: CheckHeader, Target: pc.Header})
+		}
+		if pc.JSON != "" {
+			kinds = append(kinds, Check{Kind: CheckJSON, Target: pc.JSON})
+		}
+		if pc.BodySize != nil {
+			kinds = append(kinds, Check{Kind: CheckBodySize, MinSize: pc.BodySize.Min, MaxSize: pc.BodySize.Max})
+		}
+		if pc.Latency != "" {
+			d, err := time.ParseDuration(pc.Latency)
+			if err != nil || d <= 0 {
+				fail(fmt.Sprintf("invalid duration %q", pc.Latency), field("latency")...)
+			}
+			kinds = append(kinds, Check{Kind: CheckLatency, MaxLatency: d})
+		}
+		if pc.Schema != nil {
+			kinds = append(kinds, Check{Kind: CheckSchema, Schema: pc.Schema})
+		}
+
+		var ops []Check
+		if pc.Equals != nil {
+			ops = append(ops, Check{Op: CheckEquals, Value: *pc.Equals})
+		}
+		if pc.Contains != nil {
+			ops = append(ops, Check{Op: CheckContains, Value: *pc.Contains})
+		}
+		if pc.Matches != "" {
+			ops = append(ops, Check{Op: CheckMatches, Value: pc.Matches})
+		}
+		if pc.Exists != nil {
+			op := CheckExists
+			if !*pc.Exists {
+				op = CheckAbsent
+			}
+			ops = append(ops, Check{Op: op})
+		}
+		if pc.Type != "" {
+			ops = append(ops, Check{Op: CheckType, Value: pc.Type})
+		}
+		if len(kinds) == 0 && len(ops) > 0 {
+			kinds = append(kinds, Check{Kind: CheckBody})
+		}
+
+		if len(kinds) != 1 {
+			fail("exactly one of status, header, json, body_size, latency or schema is required", at...)
+			continue
+		}
+		check := kinds[0]
+		switch check.Kind {
+		case CheckHeader, CheckJSON, CheckBody:
+			if len(ops) != 1 {
+				fail("exactly one of equals, contains, matches, exists or type is required", at...)
+				continue
+			}
+			check.Op, check.Value = ops[0].Op, ops[0].Value
+		default:
+			if len(ops) > 0 {
+				fail(fmt.Sprintf("%s checks take no operator", check.Kind), at...)
+				continue
+			}
+		}
+
+		check.Name = pc.Name
+		if check.Name == "" {
+			check.Name = check.DefaultName()
+		}
+		if names[check.Name] {
+			fail(fmt.Sprintf("duplicate check name %q", check.Name), at...)
+		}
+		names[check.Name] = true
+		if err := check.check(); err != nil {
+			fail(err.Error(), at...)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// statusList joins a status check given as a number, a string or a list of
+// them.
+func statusList(value interface{}) string {
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	parts := make([]string, len(list))
+	for i, item := range list {
+		parts[i] = fmt.Sprint(item)
+	}
+	return strings.Join(parts, ",")
+}
+
+// errorAt builds a PlanError for the node at path, such as
+// ("load", "stages", "1", "duration").
+func (p *Plan) errorAt(msg string, path ...string) *PlanError {
+	return &PlanError{File: p.file, Line: p.line(path...), Path: strings.Join(path, "."), Msg: msg}
+}
+
+// line returns the line of the deepest node along path that exists.
+func (p *Plan) line(path ...string) int {
+	node := p.root
+	if node == nil {
+		return 0
+	}
+	line := node.Line
+	for _, key := range path {
+		next := childNode(node, key)
+		if next == nil {
+			break
+		}
+		node = next
+		line = node.Line
+	}
+	return line
+}
+
+func childNode(node *yaml.Node, key string) *yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(node.Content) {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadAndApply loads content as a plan file called name and applies it to
+// an empty configuration.
+func loadAndApply(t *testing.T, name, content string) (string, Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	plan, err := LoadPlan(path)
+	if err == nil {
+		err = plan.Apply(&cfg)
+	}
+	return path, cfg, err
+}
+
+func TestPlanErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string // file:line: prefixed by the test
+	}{
+		{
+			name: "unknown key",
+			file: "plan.yaml",
+			content: `version: 1
+target:
+  url: https://example.com/
+  timeout: 5s
+`,
+			want: []string{"4: field timeout not found in type config.PlanTarget"},
+		},
+		{
+			name: "unknown key",
+			file: "plan.json",
+			content: `{
+  "version": 1,
+  "target": {
+    "url": "https://example.com/",
+    "timeout": "5s"
+  }
+}
+`,
+			want: []string{"5: field timeout not found in type config.PlanTarget"},
+		},
+		{
+			name: "wrong type",
+			file: "plan.yaml",
+			content: `version: 1
+load:
+  threads: many
+  max_in_flight: [1]
+`,
+			want: []string{
+				"3: cannot unmarshal !!str `many` into int",
+				"4: cannot unmarshal !!seq into int",
+			},
+		},
+		{
+			name: "wrong type",
+			file: "plan.json",
+			content: `{
+  "version": 1,
+  "load": {
+    "threads": "many"
+  }
+}
+`,
+			want: []string{"4: cannot unmarshal !!str `many` into int"},
+		},
+		{
+			name: "bad nested values",
+			file: "plan.yaml",
+			content: `version: 1
+scenario:
+  - name: login
+    url: https://example.com/login
+  - name: search
+    url: https://example.com/search
+    think_time: soon
+load:
+  stages:
+    - duration: 30s
+      target: 10
+    - duration: -1m
+      target: 5
+`,
+			want: []string{
+				`7: scenario.1.think_time: invalid duration "soon"`,
+				`12: load.stages.1.duration: invalid duration "-1m"`,
+			},
+		},
+		{
+			name: "bad nested values",
+			file: "plan.json",
+			content: `{
+  "version": 1,
+  "scenario": [
+    {"name": "login", "url": "https://example.com/login"},
+    {
+      "name": "search",
+      "url": "https://example.com/search",
+      "think_time": "soon"
+    }
+  ],
+  "load": {
+    "stages": [
+      {"duration": "30s", "target": 10},
+      {"duration": "-1m", "target": 5}
+    ]
+  }
+}
+`,
+			want: []string{
+				`8: scenario.1.think_time: invalid duration "soon"`,
+				`14: load.stages.1.duration: invalid duration "-1m"`,
+			},
+		},
+		{
+			name: "missing nested value",
+			file: "plan.yaml",
+			content: `version: 1
+requests:
+  - name: home
+    url: https://example.com/
+  - name: search
+    weight: 0
+`,
+			want: []string{
+				"5: requests.1: url is required",
+				"6: requests.1.weight: must be greater than 0",
+			},
+		},
+		{
+			name: "unsupported version",
+			file: "plan.yaml",
+			content: `name: old
+version: 2
+`,
+			want: []string{"2: version: unsupported version 2, expected 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" "+filepath.Ext(tt.file), func(t *testing.T) {
+			path, _, err := loadAndApply(t, tt.file, tt.content)
+			if err == nil {
+				t.Fatal("plan was accepted")
+			}
+			want := make([]string, len(tt.want))
+			for i, line := range tt.want {
+				want[i] = path + ":" + line
+			}
+			if got := err.Error(); got != strings.Join(want, "\n") {
+				t.Errorf("error:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	_, cfg, err := loadAndApply(t, "plan.yaml", `version: 1
+target:
+  url: https://example.com/
+  method: post
+load:
+  threads: 4
+  duration: 1m
+thresholds:
+  rules:
+    - p95 < 300ms
+`)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if cfg.URL != "https://example.com/" || cfg.Method != "POST" {
+		t.Errorf("target = %s %s", cfg.Method, cfg.URL)
+	}
+	if cfg.Threads != 4 || cfg.Duration.String() != "1m0s" {
+		t.Errorf("load = %d threads for %s", cfg.Threads, cfg.Duration)
+	}
+	if len(cfg.Thresholds) != 1 || cfg.Thresholds[0].Expr != "p95 < 300ms" {
+		t.Errorf("thresholds = %+v", cfg.Thresholds)
+	}
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"stormforce/internal/schema"
+)
+
+// Validate reports every problem with config that would stop a run from
+// doing what was asked, joined into a single error.
+func Validate(config Config) error {
+	var problems []error
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if len(config.Scenario) > 0 && len(config.Requests) > 0 {
+		add("a scenario cannot be combined with a traffic mix")
+	}
+	// URLs built from templates can only be checked once they are expanded.
+	if len(config.Scenario) == 0 && len(config.Requests) == 0 {
+		if config.URL == "" {
+			add("URL is required")
+		} else if !strings.Contains(config.URL, "{{") && !validURL(config.URL) {
+			add("URL %q must be an absolute http or https URL", config.URL)
+		}
+	}
+	for _, check := range config.Checks {
+		if err := check.check(); err != nil {
+			add("%v", err)
+		}
+	}
+	for i, step := range config.Scenario {
+		validateRequest(fmt.Sprintf("scenario step %s", requestName(step, i)), step, config.Checks, add)
+	}
+	for i, request := range config.Requests {
+		kind := fmt.Sprintf("request %s", requestName(request, i))
+		validateRequest(kind, request, config.Checks, add)
+		if request.Weight <= 0 {
+			add("%s: weight must be greater than 0", kind)
+		}
+	}
+
+	if config.Duration < 0 {
+		add("duration must not be negative")
+	}
+	if config.Duration == 0 && len(config.Stages) == 0 && config.N < 1 {
+		add("number of requests must be at least 1 when no duration or stages are set")
+	}
+	if config.Rate > 0 {
+		if len(config.Stages) > 0 {
+			add("load stages cannot be combined with an arrival rate")
+		}
+		if config.MaxInFlight < 1 {
+			add("max in-flight requests must be at least 1")
+		}
+	} else if len(config.Stages) == 0 && config.Threads < 1 {
+		add("number of threads must be at least 1")
+	}
+	if config.Rate < 0 {
+		add("arrival rate must not be negative")
+	}
+
+	for _, feeder := range config.Feeders {
+		if err := feeder.check(); err != nil {
+			add("%v", err)
+		} else if _, err := os.Stat(feeder.File); err != nil {
+			add("data file: %v", err)
+		}
+	}
+
+	if config.RetryLimit < 0 {
+		add("retry limit must not be negative")
+	}
+	if config.CurlMaxTime < 0 {
+		add("max time must not be negative")
+	}
+	if config.ThresholdSuccess < 0 || config.ThresholdSuccess > 100 {
+		add("success rate threshold must be between 0 and 100")
+	}
+	// Statistics are only broken down by request name and tag, so scoped
+	// thresholds must refer to one of them.
+	endpoints, tags := make(map[string]bool), make(map[string]bool)
+	for _, defs := range [][]Request{config.Scenario, config.Requests} {
+		for _, def := range defs {
+			endpoints[def.Name] = true
+			for _, tag := range def.Tags {
+				tags[tag] = true
+			}
+		}
+	}
+	for _, t := range config.Thresholds {
+		if t.Endpoint != "" && !endpoints[t.Endpoint] {
+			add("threshold %s: no request named %q", t.Expr, t.Endpoint)
+		}
+		if t.Tag != "" && !tags[t.Tag] {
+			add("threshold %s: no request is tagged %q", t.Expr, t.Tag)
+		}
+	}
+	if config.AbortOnFail {
+		if config.ThresholdWindow < time.Second {
+			add("threshold window must be at least 1s")
+		}
+		if config.ThresholdGrace < 0 {
+			add("threshold grace period must not be negative")
+		}
+	}
+	if config.ResponsePattern != "" {
+		if _, err := regexp.Compile(config.ResponsePattern); err != nil {
+			add("invalid response pattern: %v", err)
+		}
+	}
+	if config.DashboardLinger < 0 {
+		add("dashboard linger must not be negative")
+	}
+	if config.MaxSamples < 0 {
+		add("max samples must not be negative")
+	}
+	if config.TimelineBucket <= 0 {
+		add("timeline bucket must be positive")
+	}
+	if config.EmailEnabled && config.EmailTo == "" {
+		add("email address is required when email notifications are enabled")
+	}
+
+	return errors.Join(problems...)
+}
+
+func requestName(request Request, i int) string {
+	if request.Name == "" {
+		return fmt.Sprintf("#%d", i+1)
+	}
+	return request.Name
+}
+
+func validateRequest(kind string, request Request, global []Check, add func(format string, args ...interface{})) {
+	// URLs built from variables can only be checked once they are expanded.
+	if request.URL == "" {
+		add("%s: URL is required", kind)
+	} else if !strings.Contains(request.URL, "{{") && !validURL(request.URL) {
+		add("%s: URL %q must be an absolute http or https URL", kind, request.URL)
+	}
+	for _, extractor := range request.Extract {
+		if extractor.Var == "" {
+			add("%s: extractor variable name is required", kind)
+		}
+		switch extractor.Source {
+		case ExtractJSON, ExtractHeader:
+		case ExtractRegex:
+			if _, err := regexp.Compile(extractor.Expr); err != nil {
+				add("%s: invalid regex for %s: %v", kind, extractor.Var, err)
+			}
+		default:
+			add("%s: unknown extractor source %q", kind, extractor.Source)
+		}
+	}
+	if request.ResponseSchema != nil {
+		if _, err := schema.Compile(request.ResponseSchema); err != nil {
+			add("%s: invalid response schema: %v", kind, err)
+		}
+	}
+	// Check counts are reported by name, so a request check must not reuse
+	// the name of a global one.
+	names := make(map[string]bool)
+	for _, check := range global {
+		names[check.Name] = true
+	}
+	for _, check := range request.Checks {
+		if err := check.check(); err != nil {
+			add("%s: %v", kind, err)
+		}
+		if names[check.Name] {
+			add("%s: duplicate check name %q", kind, check.Name)
+		}
+		names[check.Name] = true
+	}
+	if request.ThresholdSuccess < 0 || request.ThresholdSuccess > 100 {
+		add("%s: success rate threshold must be between 0 and 100", kind)
+	}
+}
+
+func validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
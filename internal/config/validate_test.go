@@ -0,0 +1,46 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		scenario []Request
+		want     string // a problem the error must mention, "" when valid
+	}{
+		{name: "absolute URL", url: "https://example.com/x"},
+		{name: "templated host", url: `https://{{env "HOST"}}/x`},
+		{name: "templated scheme", url: `{{env "BASE_URL"}}/x`},
+		{name: "missing URL", want: "URL is required"},
+		{name: "relative URL", url: "/x", want: `URL "/x" must be an absolute http or https URL`},
+		{name: "other scheme", url: "ftp://example.com/x", want: "must be an absolute http or https URL"},
+		{
+			name:     "templated scenario step",
+			scenario: []Request{{Name: "get", Method: "GET", URL: "{{base}}/items/{{id}}"}},
+		},
+		{
+			name:     "relative scenario step",
+			scenario: []Request{{Name: "get", Method: "GET", URL: "/items"}},
+			want:     `scenario step get: URL "/items" must be an absolute http or https URL`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{N: 1, Threads: 1, URL: tt.url, Scenario: tt.scenario, TimelineBucket: time.Second}
+			err := Validate(cfg)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("Validate accepted the configuration, want %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("Validate: %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package dashboard serves a live view of a run in progress over HTTP and
+// the final report at the same URL once the run is over. Browsers follow
+// the run through Server-Sent Events.
+package dashboard
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"stormforce/internal/results"
+)
+
+// sampleInterval is how often a point is added to the charts.
+const sampleInterval = time.Second
+
+//go:embed live.html
+var livePage []byte
+
+// Server is the dashboard of one run.
+type Server struct {
+	live     *results.Live
+	listener net.Listener
+	server   *http.Server
+
+	mu      sync.Mutex
+	history []results.Progress // one point per sampleInterval, oldest first
+	report  []byte             // the final report, set by Finish
+
+	finished chan struct{}
+	stopped  chan struct{}
+}
+
+// Start listens on addr, e.g. ":8080", and serves the dashboard of the run
+// that live follows.
+func Start(addr string, live *results.Live) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error starting dashboard: %v", err)
+	}
+	s := &Server{
+		live:     live,
+		listener: listener,
+		finished: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handlePage)
+	mux.HandleFunc("/events", s.handleEvents)
+	s.server = &http.Server{Handler: mux}
+
+	go s.server.Serve(listener)
+	go s.sample()
+	return s, nil
+}
+
+// URL returns where the dashboard can be opened.
+func (s *Server) URL() string {
+	host, port, err := net.SplitHostPort(s.listener.Addr().String())
+	if err != nil {
+		return "http://" + s.listener.Addr().String() + "/"
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/"
+}
+
+// Finish replaces the live view with report, the final HTML report.
+// Connected browsers are told to reload.
+func (s *Server) Finish(report []byte) {
+	s.mu.Lock()
+	s.report = report
+	s.mu.Unlock()
+	close(s.finished)
+	<-s.stopped
+}
+
+// Close stops serving.
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// sample adds a point to the history every sampleInterval until Finish.
+func (s *Server) sample() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.finished:
+			return
+		case now := <-ticker.C:
+			p := s.live.Progress(now)
+			if !p.Started {
+				continue
+			}
+			p.History = nil // the charts plot the points themselves
+			s.mu.Lock()
+			s.history = append(s.history, p)
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	page := s.report
+	s.mu.Unlock()
+	if page == nil {
+		page = livePage
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(page)
+}
+
+// handleEvents streams every point of the history as a "data" event, the
+// ones already sampled first, and a "done" event once the report is ready.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	sent := 0
+	for {
+		s.mu.Lock()
+		points := s.history[sent:]
+		done := s.report != nil
+		s.mu.Unlock()
+
+		for _, p := range points {
+			data, err := json.Marshal(p)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		sent += len(points)
+		if done {
+			fmt.Fprint(w, "event: done\ndata: {}\n\n")
+		}
+		flusher.Flush()
+		if done {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.finished:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package dashboard
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"stormforce/internal/results"
+)
+
+func startServer(t *testing.T) (*Server, *results.Live) {
+	t.Helper()
+	live := results.NewLive()
+	s, err := Start("127.0.0.1:0", live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, live
+}
+
+func get(t *testing.T, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+// readEvents reads Server-Sent Events from r until a "done" event or the
+// end of the stream, returning the data of the other events.
+func readEvents(t *testing.T, r io.Reader) (points []results.Progress, done bool) {
+	t.Helper()
+	scanner := bufio.NewScanner(r)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if event == "done" {
+				return points, true
+			}
+			var p results.Progress
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &p); err != nil {
+				t.Fatalf("bad data event %q: %v", line, err)
+			}
+			points = append(points, p)
+		case line == "":
+			event = ""
+		}
+	}
+	return points, false
+}
+
+func TestPage(t *testing.T) {
+	s, _ := startServer(t)
+
+	resp, body := get(t, s.URL())
+	if resp.StatusCode != http.StatusOK || body != string(livePage) {
+		t.Errorf("GET / = %d, want the live page", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if resp, _ := get(t, s.URL()+"favicon.ico"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /favicon.ico = %d, want 404", resp.StatusCode)
+	}
+
+	s.Finish([]byte("<html>final report</html>"))
+	if _, body := get(t, s.URL()); body != "<html>final report</html>" {
+		t.Errorf("GET / after Finish = %q, want the report", body)
+	}
+}
+
+func TestEventsReplayHistoryAndEndWithDone(t *testing.T) {
+	s, _ := startServer(t)
+	// Points sampled before the browser connects.
+	s.mu.Lock()
+	s.history = []results.Progress{{Started: true, Requests: 10}, {Started: true, Requests: 25}}
+	s.mu.Unlock()
+
+	resp, err := http.Get(s.URL() + "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.mu.Lock()
+		s.history = append(s.history, results.Progress{Started: true, Requests: 40})
+		s.mu.Unlock()
+		s.Finish([]byte("report"))
+	}()
+	points, done := readEvents(t, resp.Body)
+	if !done {
+		t.Error("the stream ended without a done event")
+	}
+	var requests []int
+	for _, p := range points {
+		requests = append(requests, p.Requests)
+	}
+	if len(requests) != 3 || requests[0] != 10 || requests[1] != 25 || requests[2] != 40 {
+		t.Errorf("points with %v requests, want the replayed 10 and 25, then 40", requests)
+	}
+}
+
+func TestEventsAfterFinish(t *testing.T) {
+	s, _ := startServer(t)
+	s.mu.Lock()
+	s.history = []results.Progress{{Started: true, Requests: 5}}
+	s.mu.Unlock()
+	s.Finish([]byte("report"))
+
+	resp, body := get(t, s.URL()+"events")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /events = %d", resp.StatusCode)
+	}
+	points, done := readEvents(t, strings.NewReader(body))
+	if len(points) != 1 || !done {
+		t.Errorf("%d points, done %v, want the history then done", len(points), done)
+	}
+}
+
+func TestSamplesProgressOnceStarted(t *testing.T) {
+	s, live := startServer(t)
+	start := time.Now()
+	live.Begin(start, 60, 0)
+	live.Add(results.Sample{Start: start, Duration: 0.01, Attempt: 1, StatusCode: 200, Matched: true})
+
+	deadline := time.Now().Add(3 * sampleInterval)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		n := len(s.history)
+		var p results.Progress
+		if n > 0 {
+			p = s.history[0]
+		}
+		s.mu.Unlock()
+		if n > 0 {
+			if p.Requests != 1 || p.History != nil {
+				t.Errorf("sampled %+v, want 1 request and no history", p)
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("no point was sampled")
+}
@@ -0,0 +1,443 @@
+package importer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"stormforce/internal/config"
+)
+
+// curlOptions maps the curl options that take an argument to the name they
+// are handled under. Options such as -o are accepted and ignored.
+var curlOptions = map[string]string{
+	"-X":                "request",
+	"--request":         "request",
+	"-H":                "header",
+	"--header":          "header",
+	"-d":                "data",
+	"--data":            "data",
+	"--data-ascii":      "data",
+	"--data-raw":        "data-raw",
+	"--data-binary":     "data-binary",
+	"--data-urlencode":  "data-urlencode",
+	"--json":            "json",
+	"-u":                "user",
+	"--user":            "user",
+	"-A":                "user-agent",
+	"--user-agent":      "user-agent",
+	"-e":                "referer",
+	"--referer":         "referer",
+	"--url":             "url",
+	"-b":                "cookie",
+	"--cookie":          "cookie",
+	"-o":                "ignore",
+	"--output":          "ignore",
+	"-m":                "ignore",
+	"--max-time":        "ignore",
+	"--connect-timeout": "ignore",
+}
+
+// curlFlags maps the curl options without an argument that are understood
+// in the same way.
+var curlFlags = map[string]string{
+	"-k":           "insecure",
+	"--insecure":   "insecure",
+	"-G":           "get",
+	"--get":        "get",
+	"-I":           "head",
+	"--head":       "head",
+	"-s":           "ignore",
+	"--silent":     "ignore",
+	"-S":           "ignore",
+	"--show-error": "ignore",
+	"-L":           "ignore",
+	"--location":   "ignore",
+	"-i":           "ignore",
+	"--include":    "ignore",
+	"-v":           "ignore",
+	"--verbose":    "ignore",
+	"-f":           "ignore",
+	"--fail":       "ignore",
+	"-g":           "ignore",
+	"--globoff":    "ignore",
+	"--compressed": "ignore",
+	"--http1.1":    "ignore",
+	"--http2":      "ignore",
+}
+
+// FromCurl converts curl command lines into a scenario with a step per
+// command, in order. Commands are separated by newlines; a trailing
+// backslash continues a command on the next line, as in a shell. When any
+// command uses -k the plan skips TLS certificate verification.
+func FromCurl(input string) (*config.Plan, error) {
+	commands, err := splitCommands(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("no curl command found")
+	}
+
+	plan := &config.Plan{Version: config.PlanVersion}
+	used := names{}
+	for i, args := range commands {
+		request, insecure, err := parseCurl(args)
+		if err != nil {
+			return nil, fmt.Errorf("command %d: %v", i+1, err)
+		}
+		if insecure {
+			plan.Target.Insecure = &insecure
+		}
+		request.Name = used.unique(request.Name)
+		plan.Scenario = append(plan.Scenario, request)
+	}
+	return plan, nil
+}
+
+func parseCurl(args []string) (config.PlanRequest, bool, error) {
+	request := config.PlanRequest{Headers: make(map[string]string)}
+	if len(args) == 0 || args[0] != "curl" {
+		return request, false, fmt.Errorf("expected a command starting with curl")
+	}
+
+	var data []string
+	var rawURL string
+	var get, head, insecure bool
+	args = expandShortOptions(args)
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if rawURL != "" {
+				return request, false, fmt.Errorf("more than one URL: %s and %s", rawURL, arg)
+			}
+			rawURL = arg
+			continue
+		}
+
+		if name, ok := curlFlags[arg]; ok {
+			switch name {
+			case "insecure":
+				insecure = true
+			case "get":
+				get = true
+			case "head":
+				head = true
+			}
+			continue
+		}
+		name, ok := curlOptions[arg]
+		if !ok {
+			return request, false, fmt.Errorf("unsupported curl option %s", arg)
+		}
+		if i+1 == len(args) {
+			return request, false, fmt.Errorf("option %s needs an argument", arg)
+		}
+		i++
+		value := args[i]
+
+		switch name {
+		case "request":
+			request.Method = strings.ToUpper(value)
+		case "header":
+			key, val, found := strings.Cut(value, ":")
+			if !found {
+				return request, false, fmt.Errorf("invalid header %q: expected 'Key: Value'", value)
+			}
+			if key = strings.TrimSpace(key); keepHeader(key) {
+				request.Headers[key] = strings.TrimSpace(val)
+			}
+		case "data", "data-raw", "data-binary", "data-urlencode", "json":
+			part, err := curlData(name, value)
+			if err != nil {
+				return request, false, err
+			}
+			data = append(data, part)
+			if name == "json" {
+				setDefaultHeader(request.Headers, "Content-Type", "application/json")
+				setDefaultHeader(request.Headers, "Accept", "application/json")
+			}
+		case "user":
+			if !strings.Contains(value, ":") {
+				value += ":"
+			}
+			request.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(value))
+		case "cookie":
+			// Without a "=" the argument is a cookie file, which is left
+			// out like the other files curl reads or writes.
+			if strings.Contains(value, "=") {
+				addCookie(request.Headers, value)
+			}
+		case "user-agent":
+			request.Headers["User-Agent"] = value
+		case "referer":
+			request.Headers["Referer"] = value
+		case "url":
+			if rawURL != "" {
+				return request, false, fmt.Errorf("more than one URL: %s and %s", rawURL, value)
+			}
+			rawURL = value
+		}
+	}
+
+	if rawURL == "" {
+		return request, false, fmt.Errorf("no URL")
+	}
+	// Like curl, assume http when the URL has no scheme.
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	body := strings.Join(data, "&")
+	switch {
+	case get && body != "":
+		separator := "?"
+		if strings.Contains(rawURL, "?") {
+			separator = "&"
+		}
+		rawURL += separator + body
+		body = ""
+	case body != "":
+		setDefaultHeader(request.Headers, "Content-Type", "application/x-www-form-urlencoded")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return request, false, fmt.Errorf("invalid URL %q: %v", rawURL, err)
+	}
+
+	if request.Method == "" {
+		switch {
+		case head:
+			request.Method = "HEAD"
+		case body != "":
+			request.Method = "POST"
+		default:
+			request.Method = "GET"
+		}
+	}
+	request.URL = rawURL
+	request.Body = body
+	request.Name = requestName(request.Method, u)
+	if len(request.Headers) == 0 {
+		request.Headers = nil
+	}
+	return request, insecure, nil
+}
+
+// expandShortOptions splits grouped short options such as -sSk and
+// attached arguments such as -XPOST into separate words.
+func expandShortOptions(args []string) []string {
+	expanded := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if _, ok := curlOptions[arg]; ok && i+1 < len(args) {
+			expanded = append(expanded, arg, args[i+1])
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "--") || !strings.HasPrefix(arg, "-") || len(arg) <= 2 {
+			expanded = append(expanded, arg)
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			option := "-" + arg[j:j+1]
+			expanded = append(expanded, option)
+			if _, ok := curlOptions[option]; ok && j+1 < len(arg) {
+				expanded = append(expanded, arg[j+1:])
+				break
+			}
+		}
+	}
+	return expanded
+}
+
+// curlData returns the body part added by a data option. As in curl, -d
+// strips newlines from a file read with @file and --data-binary does not.
+func curlData(option, value string) (string, error) {
+	switch option {
+	case "data-raw":
+		return value, nil
+	case "data-urlencode":
+		name, content, found := strings.Cut(value, "=")
+		if !found {
+			if name, file, found := strings.Cut(value, "@"); found {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return "", fmt.Errorf("error reading data file: %v", err)
+				}
+				return joinNonEmpty(name, url.QueryEscape(string(data))), nil
+			}
+			return url.QueryEscape(value), nil
+		}
+		return joinNonEmpty(name, url.QueryEscape(content)), nil
+	}
+
+	if !strings.HasPrefix(value, "@") {
+		return value, nil
+	}
+	data, err := os.ReadFile(value[1:])
+	if err != nil {
+		return "", fmt.Errorf("error reading data file: %v", err)
+	}
+	if option == "data" {
+		return strings.NewReplacer("\r", "", "\n", "").Replace(string(data)), nil
+	}
+	return string(data), nil
+}
+
+func joinNonEmpty(name, value string) string {
+	if name == "" {
+		return value
+	}
+	return name + "=" + value
+}
+
+// addCookie adds cookies such as "a=1; b=2" to the Cookie header, after
+// those set already.
+func addCookie(headers map[string]string, cookies string) {
+	for key, value := range headers {
+		if strings.EqualFold(key, "Cookie") {
+			headers[key] = value + "; " + cookies
+			return
+		}
+	}
+	headers["Cookie"] = cookies
+}
+
+// setDefaultHeader sets key unless it is set already, in any case.
+func setDefaultHeader(headers map[string]string, key, value string) {
+	for existing := range headers {
+		if strings.EqualFold(existing, key) {
+			return
+		}
+	}
+	headers[key] = value
+}
+
+// splitCommands splits shell command lines into words, following the POSIX
+// quoting rules plus bash's $'...' strings, which browsers use when copying
+// a request as curl. Unquoted newlines end a command.
+func splitCommands(input string) ([][]string, error) {
+	var commands [][]string
+	var args []string
+	var word strings.Builder
+	inWord := false
+	endWord := func() {
+		if inWord {
+			args = append(args, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(args) > 0 {
+			commands = append(commands, args)
+			args = nil
+		}
+	}
+
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case c == '\\' && strings.HasPrefix(input[i+1:], "\n"):
+			i++
+		case c == '\\' && strings.HasPrefix(input[i+1:], "\r\n"):
+			i += 2
+		case c == '\\' && i+1 < len(input):
+			i++
+			word.WriteByte(input[i])
+			inWord = true
+		case c == '\n':
+			endCommand()
+		case c == ' ' || c == '\t' || c == '\r':
+			endWord()
+		case c == '#' && !inWord:
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+			endCommand()
+		case c == '\'':
+			end := strings.IndexByte(input[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(input[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && strings.HasPrefix(input[i+1:], "'"):
+			n, err := ansiCString(input[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(input) && input[i] != '"'; i++ {
+				if input[i] == '\\' && i+1 < len(input) && strings.IndexByte("$`\"\\\n", input[i+1]) >= 0 {
+					i++
+					if input[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(input[i])
+			}
+			if i == len(input) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endCommand()
+	return commands, nil
+}
+
+// ansiCString decodes the body of a $'...' string up to and including the
+// closing quote and returns the number of bytes consumed. As in bash, \x,
+// \u and \U take up to 2, 4 and 8 hex digits.
+func ansiCString(input string, word *strings.Builder) (int, error) {
+	escapes := map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '\'': '\'', '"': '"', '0': 0, 'e': 0x1b}
+	hexDigits := map[byte]int{'x': 2, 'u': 4, 'U': 8}
+	for i := 0; i < len(input); i++ {
+		switch c := input[i]; {
+		case c == '\'':
+			return i, nil
+		case c == '\\' && i+1 < len(input):
+			i++
+			escaped, ok := escapes[input[i]]
+			if ok {
+				word.WriteByte(escaped)
+				continue
+			}
+			n := 0
+			for n < hexDigits[input[i]] && i+1+n < len(input) && isHex(input[i+1+n]) {
+				n++
+			}
+			if n == 0 {
+				word.WriteByte('\\')
+				word.WriteByte(input[i])
+				continue
+			}
+			value, _ := strconv.ParseUint(input[i+1:i+1+n], 16, 32)
+			if input[i] == 'x' {
+				word.WriteByte(byte(value))
+			} else {
+				word.WriteRune(rune(value))
+			}
+			i += n
+		default:
+			word.WriteByte(c)
+		}
+	}
+	return 0, fmt.Errorf("unterminated $' quote")
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
@@ -0,0 +1,110 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func importCurlStep(t *testing.T, command string) map[string]string {
+	t.Helper()
+	plan, err := FromCurl(command)
+	if err != nil {
+		t.Fatalf("FromCurl(%s): %v", command, err)
+	}
+	if len(plan.Scenario) != 1 {
+		t.Fatalf("FromCurl(%s) imported %d steps", command, len(plan.Scenario))
+	}
+	step := plan.Scenario[0]
+	return map[string]string{"method": step.Method, "url": step.URL, "body": step.Body, "cookie": step.Headers["Cookie"]}
+}
+
+func TestCurlCookies(t *testing.T) {
+	tests := []struct {
+		command string
+		cookie  string
+	}{
+		{command: `curl -H 'Cookie: session=abc' https://example.com/`, cookie: "session=abc"},
+		{command: `curl -b 'session=abc; theme=dark' https://example.com/`, cookie: "session=abc; theme=dark"},
+		{command: `curl --cookie session=abc https://example.com/`, cookie: "session=abc"},
+		{command: `curl -H 'Cookie: a=1' -b b=2 -b c=3 https://example.com/`, cookie: "a=1; b=2; c=3"},
+		{command: `curl -b cookies.txt https://example.com/`, cookie: ""},
+	}
+	for _, tt := range tests {
+		if got := importCurlStep(t, tt.command)["cookie"]; got != tt.cookie {
+			t.Errorf("%s: Cookie = %q, want %q", tt.command, got, tt.cookie)
+		}
+	}
+}
+
+func TestCurlSkipsClientHeaders(t *testing.T) {
+	plan, err := FromCurl(`curl -H 'Host: example.com' -H 'Content-Length: 3' -H 'Accept-Encoding: gzip' -H 'X-Trace: 1' https://example.com/`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"X-Trace": "1"}; !reflect.DeepEqual(plan.Scenario[0].Headers, want) {
+		t.Errorf("headers = %v, want %v", plan.Scenario[0].Headers, want)
+	}
+}
+
+func TestANSICStrings(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{word: `$'a\nb'`, want: "a\nb"},
+		{word: `$'\x41'`, want: "A"},
+		{word: `$'x\x41\x42'`, want: "xAB"},
+		{word: `$'\x41B'`, want: "AB"},
+		{word: `$'\x4'`, want: "\x04"},
+		{word: `$'\x4g'`, want: "\x04g"},
+		{word: `$'\xg'`, want: `\xg`},
+		{word: `$'\u00e9'`, want: "é"},
+		{word: `$'\u20AC1'`, want: "€1"},
+		{word: `$'\U0001F600'`, want: "😀"},
+		{word: `$'it\'s'`, want: "it's"},
+		{word: `$'\q'`, want: `\q`},
+	}
+	for _, tt := range tests {
+		commands, err := splitCommands("curl -d " + tt.word + " https://example.com/")
+		if err != nil {
+			t.Errorf("%s: %v", tt.word, err)
+			continue
+		}
+		if got := commands[0][2]; got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+
+	if _, err := splitCommands(`curl -d $'\x41`); err == nil {
+		t.Errorf("an unterminated $' quote was accepted")
+	}
+}
+
+func TestCurlRequests(t *testing.T) {
+	tests := []struct {
+		command string
+		want    map[string]string
+	}{
+		{
+			command: `curl https://example.com/items`,
+			want:    map[string]string{"method": "GET", "url": "https://example.com/items", "body": "", "cookie": ""},
+		},
+		{
+			command: "curl 'https://example.com/items' \\\n  -H 'Content-Type: application/json' \\\n  --data-raw $'{\"name\":\"caf\\u00e9\"}'",
+			want:    map[string]string{"method": "POST", "url": "https://example.com/items", "body": `{"name":"café"}`, "cookie": ""},
+		},
+		{
+			command: `curl -G -d q=storm -d page=2 example.com/search`,
+			want:    map[string]string{"method": "GET", "url": "http://example.com/search?q=storm&page=2", "body": "", "cookie": ""},
+		},
+		{
+			command: `curl -XDELETE https://example.com/items/1`,
+			want:    map[string]string{"method": "DELETE", "url": "https://example.com/items/1", "body": "", "cookie": ""},
+		},
+	}
+	for _, tt := range tests {
+		if got := importCurlStep(t, tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", tt.command, got, tt.want)
+		}
+	}
+}
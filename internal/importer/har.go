@@ -0,0 +1,181 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"stormforce/internal/config"
+)
+
+// HAROptions controls which recorded requests end up in the scenario.
+type HAROptions struct {
+	// Domains lists the hosts to keep, including their subdomains. When
+	// empty, the host of the first request is kept.
+	Domains []string
+	// AllDomains keeps third-party requests as well.
+	AllDomains bool
+	// KeepStatic keeps images, scripts, stylesheets, fonts and media.
+	KeepStatic bool
+	// MinThinkTime drops shorter pauses between requests, which are mostly
+	// the browser fetching in parallel rather than the user thinking.
+	MinThinkTime time.Duration
+}
+
+type harFile struct {
+	Log struct {
+		Pages []struct {
+			Title string `json:"title"`
+		} `json:"pages"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"` // milliseconds
+	Request         struct {
+		Method   string      `json:"method"`
+		URL      string      `json:"url"`
+		Headers  []harHeader `json:"headers"`
+		PostData *struct {
+			MimeType string      `json:"mimeType"`
+			Text     string      `json:"text"`
+			Params   []harHeader `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Content struct {
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+var staticExtensions = map[string]bool{
+	".js": true, ".mjs": true, ".css": true, ".map": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".ico": true, ".webp": true, ".avif": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".eot": true,
+	".mp4": true, ".webm": true, ".mp3": true,
+}
+
+var staticMimePrefixes = []string{"image/", "font/", "video/", "audio/", "text/css", "text/javascript", "application/javascript"}
+
+// FromHAR converts a HAR capture into a plan with one scenario step per
+// request, in the order they were started. The pause between the end of a
+// kept request and the start of the next becomes that step's think time.
+func FromHAR(r io.Reader, opts HAROptions) (*config.Plan, error) {
+	var har harFile
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("error parsing HAR file: %v", err)
+	}
+
+	plan := &config.Plan{Version: config.PlanVersion}
+	if len(har.Log.Pages) > 0 {
+		plan.Name = har.Log.Pages[0].Title
+	}
+
+	// Browsers do not always list entries in the order they were started.
+	entries := har.Log.Entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+
+	domains := opts.Domains
+	used := names{}
+	var previousEnd time.Time
+	for _, entry := range entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if !opts.KeepStatic && isStatic(u, entry.Response.Content.MimeType) {
+			continue
+		}
+		if len(domains) == 0 {
+			domains = []string{u.Hostname()}
+		}
+		if !opts.AllDomains && !anyDomain(u.Hostname(), domains) {
+			continue
+		}
+
+		step := config.PlanRequest{
+			Name:    used.unique(requestName(entry.Request.Method, u)),
+			Method:  entry.Request.Method,
+			URL:     entry.Request.URL,
+			Headers: make(map[string]string),
+		}
+		for _, h := range entry.Request.Headers {
+			if keepRecordedHeader(h.Name) {
+				step.Headers[h.Name] = h.Value
+			}
+		}
+		if post := entry.Request.PostData; post != nil {
+			step.Body = post.Text
+			if step.Body == "" && len(post.Params) > 0 {
+				form := url.Values{}
+				for _, p := range post.Params {
+					form.Add(p.Name, p.Value)
+				}
+				step.Body = form.Encode()
+			}
+			if post.MimeType != "" && !hasHeader(step.Headers, "Content-Type") {
+				step.Headers["Content-Type"] = post.MimeType
+			}
+		}
+
+		if !previousEnd.IsZero() {
+			if pause := entry.StartedDateTime.Sub(previousEnd); pause >= opts.MinThinkTime && pause > 0 {
+				step.ThinkTime = pause.Round(time.Millisecond).String()
+			}
+		}
+		previousEnd = entry.StartedDateTime.Add(time.Duration(entry.Time * float64(time.Millisecond)))
+
+		plan.Scenario = append(plan.Scenario, step)
+	}
+
+	if len(plan.Scenario) == 0 {
+		return nil, fmt.Errorf("no requests left after filtering")
+	}
+	return plan, nil
+}
+
+func isStatic(u *url.URL, mimeType string) bool {
+	if staticExtensions[strings.ToLower(path.Ext(u.Path))] {
+		return true
+	}
+	mimeType = strings.ToLower(mimeType)
+	for _, prefix := range staticMimePrefixes {
+		if strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		if matchesDomain(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"stormforce/internal/config"
+)
+
+func importHARFixture(t *testing.T, opts HAROptions) *config.Plan {
+	t.Helper()
+	file, err := os.Open("testdata/session.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	plan, err := FromHAR(file, opts)
+	if err != nil {
+		t.Fatalf("FromHAR: %v", err)
+	}
+	return plan
+}
+
+func stepNames(steps []config.PlanRequest) string {
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestHARFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		opts HAROptions
+		want string
+	}{
+		{
+			name: "default",
+			want: "GET /, POST /cart, GET /checkout",
+		},
+		{
+			name: "keep static",
+			opts: HAROptions{KeepStatic: true},
+			want: "GET /, GET /static/app.js, POST /cart, GET /fonts/body.woff2, GET /checkout",
+		},
+		{
+			name: "all domains",
+			opts: HAROptions{AllDomains: true},
+			want: "GET /, GET /collect, POST /cart, GET /checkout",
+		},
+		{
+			name: "keep static from all domains",
+			opts: HAROptions{KeepStatic: true, AllDomains: true},
+			want: "GET /, GET /static/app.js, GET /logo, GET /collect, POST /cart, GET /fonts/body.woff2, GET /checkout",
+		},
+		{
+			name: "explicit domain",
+			opts: HAROptions{Domains: []string{"api.shop.example.com"}},
+			want: "POST /cart",
+		},
+		{
+			name: "explicit third-party domain",
+			opts: HAROptions{Domains: []string{".google-analytics.com"}},
+			want: "GET /collect",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := importHARFixture(t, tt.opts)
+			if got := stepNames(plan.Scenario); got != tt.want {
+				t.Errorf("steps = %s\nwant    %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHARStepsAndThinkTimes(t *testing.T) {
+	plan := importHARFixture(t, HAROptions{MinThinkTime: 100 * time.Millisecond})
+	if plan.Name != "Shop checkout" {
+		t.Errorf("name = %q, want the page title", plan.Name)
+	}
+	if len(plan.Scenario) != 3 {
+		t.Fatalf("steps = %s, want 3", stepNames(plan.Scenario))
+	}
+
+	// Entries are sorted by start time, so the page load comes first even
+	// though the HAR lists the cart request before it.
+	home, cart, checkout := plan.Scenario[0], plan.Scenario[1], plan.Scenario[2]
+	if home.ThinkTime != "" {
+		t.Errorf("first step think time = %q, want none", home.ThinkTime)
+	}
+	if cart.ThinkTime != "1.9s" {
+		t.Errorf("cart think time = %q, want 1.9s after the page loaded", cart.ThinkTime)
+	}
+	if checkout.ThinkTime != "3s" {
+		t.Errorf("checkout think time = %q, want 3s after the cart request ended", checkout.ThinkTime)
+	}
+
+	if cart.Method != "POST" || cart.Body != `{"sku":"A1","qty":1}` {
+		t.Errorf("cart = %s with body %q", cart.Method, cart.Body)
+	}
+	want := map[string]string{"Content-Type": "application/json", "X-Requested-With": "XMLHttpRequest"}
+	if len(cart.Headers) != len(want) {
+		t.Errorf("cart headers = %v, want %v", cart.Headers, want)
+	}
+	for key, value := range want {
+		if cart.Headers[key] != value {
+			t.Errorf("cart header %s = %q, want %q", key, cart.Headers[key], value)
+		}
+	}
+}
+
+func TestHARNothingLeft(t *testing.T) {
+	file, err := os.Open("testdata/session.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := FromHAR(file, HAROptions{Domains: []string{"elsewhere.example.org"}}); err == nil {
+		t.Errorf("FromHAR succeeded without any request left")
+	}
+}
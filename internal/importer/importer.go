@@ -0,0 +1,80 @@
+// Package importer converts recorded or documented traffic into StormForce
+// test plans.
+package importer
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"stormforce/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WritePlan writes plan as YAML, noting where it was imported from.
+func WritePlan(w io.Writer, plan *config.Plan, source string) error {
+	if _, err := fmt.Fprintf(w, "# Imported from %s by stormforce import.\n", source); err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(plan); err != nil {
+		return fmt.Errorf("error encoding plan: %v", err)
+	}
+	return encoder.Close()
+}
+
+// skippedHeaders are set by the HTTP client itself, so they are not copied
+// into a plan.
+var skippedHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"keep-alive":        true,
+	"accept-encoding":   true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"te":                true,
+}
+
+// keepHeader reports whether a header belongs in a plan. HTTP/2 pseudo
+// headers such as :authority are dropped too.
+func keepHeader(name string) bool {
+	return !strings.HasPrefix(name, ":") && !skippedHeaders[strings.ToLower(name)]
+}
+
+// keepRecordedHeader is keepHeader for headers recorded from a browser,
+// whose cookies belong to the recorded session rather than the plan.
+func keepRecordedHeader(name string) bool {
+	return keepHeader(name) && !strings.EqualFold(name, "Cookie")
+}
+
+// names hands out unique request names.
+type names map[string]int
+
+// unique returns name, or name with a counter appended when it was handed
+// out before.
+func (n names) unique(name string) string {
+	n[name]++
+	if n[name] == 1 {
+		return name
+	}
+	return fmt.Sprintf("%s (%d)", name, n[name])
+}
+
+// requestName names a request after its method and path.
+func requestName(method string, u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return method + " " + path
+}
+
+// matchesDomain reports whether host is domain or one of its subdomains.
+func matchesDomain(host, domain string) bool {
+	host, domain = strings.ToLower(host), strings.ToLower(strings.TrimPrefix(domain, "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -0,0 +1,526 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"stormforce/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPIOptions selects the operations to import and how.
+type OpenAPIOptions struct {
+	// Server is the base URL. By default the first server of the document
+	// is used.
+	Server string
+	// Operations and Tags select operations by operationId or tag. When both
+	// are empty every operation is imported.
+	Operations []string
+	Tags       []string
+	// ValidateResponses adds the schema of each operation's first 2xx JSON
+	// response, so responses are validated during the run. Selected
+	// operations that document no such schema are an error, unless their
+	// 2xx responses have no body.
+	ValidateResponses bool
+}
+
+var openAPIMethods = []string{"get", "post", "put", "patch", "delete", "head", "options"}
+
+type openAPIDoc struct {
+	root map[string]interface{}
+}
+
+// FromOpenAPI converts an OpenAPI 3 document, in YAML or JSON, into a plan
+// with a traffic mix entry per selected operation. Parameters and bodies
+// come from the document's examples or are synthesized from the schemas.
+func FromOpenAPI(r io.Reader, opts OpenAPIOptions) (*config.Plan, error) {
+	var decoded interface{}
+	if err := yaml.NewDecoder(r).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("error parsing OpenAPI document: %v", err)
+	}
+	root, ok := stringKeys(decoded).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error parsing OpenAPI document: expected an object")
+	}
+	version, _ := root["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q, expected 3.x", version)
+	}
+	d := &openAPIDoc{root: root}
+
+	server, err := d.server(opts.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &config.Plan{Version: config.PlanVersion}
+	if info, ok := root["info"].(map[string]interface{}); ok {
+		plan.Name, _ = info["title"].(string)
+	}
+
+	paths, _ := root["paths"].(map[string]interface{})
+	used := names{}
+	for _, path := range sortedKeys(paths) {
+		item := d.resolve(paths[path])
+		for _, method := range openAPIMethods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok || !selected(op, opts) {
+				continue
+			}
+			request, err := d.request(server, path, method, item, op, opts)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", strings.ToUpper(method), path, err)
+			}
+			request.Name = used.unique(request.Name)
+			plan.Requests = append(plan.Requests, request)
+		}
+	}
+
+	if len(plan.Requests) == 0 {
+		return nil, fmt.Errorf("no operations selected")
+	}
+	return plan, nil
+}
+
+func (d *openAPIDoc) server(override string) (string, error) {
+	base := override
+	if base == "" {
+		servers, _ := d.root["servers"].([]interface{})
+		if len(servers) > 0 {
+			server, _ := servers[0].(map[string]interface{})
+			base, _ = server["url"].(string)
+			// Server variables are replaced by their defaults.
+			variables, _ := server["variables"].(map[string]interface{})
+			for name, v := range variables {
+				variable, _ := v.(map[string]interface{})
+				base = strings.ReplaceAll(base, "{"+name+"}", fmt.Sprint(variable["default"]))
+			}
+		}
+	}
+	if u, err := url.Parse(base); err != nil || u.Host == "" {
+		return "", fmt.Errorf("server URL %q is not absolute, set one explicitly", base)
+	}
+	return strings.TrimSuffix(base, "/"), nil
+}
+
+func selected(op map[string]interface{}, opts OpenAPIOptions) bool {
+	if len(opts.Operations) == 0 && len(opts.Tags) == 0 {
+		return true
+	}
+	id, _ := op["operationId"].(string)
+	for _, want := range opts.Operations {
+		if id == want {
+			return true
+		}
+	}
+	tags, _ := op["tags"].([]interface{})
+	for _, tag := range tags {
+		for _, want := range opts.Tags {
+			if tag == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *openAPIDoc) request(server, path, method string, item, op map[string]interface{}, opts OpenAPIOptions) (config.PlanRequest, error) {
+	request := config.PlanRequest{Method: strings.ToUpper(method), Headers: make(map[string]string)}
+	request.Name, _ = op["operationId"].(string)
+	if request.Name == "" {
+		request.Name = request.Method + " " + path
+	}
+
+	// Operation parameters override path item parameters with the same
+	// name and location.
+	params := make(map[string]map[string]interface{})
+	var order []string
+	for _, list := range []interface{}{item["parameters"], op["parameters"]} {
+		items, _ := list.([]interface{})
+		for _, raw := range items {
+			param := d.resolve(raw)
+			key := fmt.Sprint(param["in"], ":", param["name"])
+			if _, seen := params[key]; !seen {
+				order = append(order, key)
+			}
+			params[key] = param
+		}
+	}
+
+	var query []string
+	for _, key := range order {
+		param := params[key]
+		name, _ := param["name"].(string)
+		required, _ := param["required"].(bool)
+		_, hasExample := param["example"]
+		value := d.paramValue(param)
+		switch param["in"] {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+name+"}", escape(value, url.PathEscape))
+		case "query":
+			if required || hasExample {
+				query = append(query, url.QueryEscape(name)+"="+escape(value, url.QueryEscape))
+			}
+		case "header":
+			if required {
+				request.Headers[name] = value
+			}
+		}
+	}
+	request.URL = server + path
+	if len(query) > 0 {
+		request.URL += "?" + strings.Join(query, "&")
+	}
+
+	if body := d.resolve(op["requestBody"]); body != nil {
+		contentType, media := pickMedia(d.resolve(body["content"]))
+		if media != nil {
+			value := d.mediaExample(media)
+			request.Headers["Content-Type"] = contentType
+			if strings.Contains(contentType, "x-www-form-urlencoded") {
+				request.Body = formBody(value)
+			} else {
+				encoded, err := json.Marshal(value)
+				if err != nil {
+					return request, fmt.Errorf("error encoding body: %v", err)
+				}
+				request.Body = string(encoded)
+			}
+		}
+	}
+
+	if opts.ValidateResponses {
+		schema, err := d.responseSchema(op)
+		if err != nil {
+			return request, err
+		}
+		request.ResponseSchema = schema
+	}
+	if len(request.Headers) == 0 {
+		request.Headers = nil
+	}
+	return request, nil
+}
+
+// escape escapes value for a URL unless it contains a template, which is
+// filled in when the request is sent.
+func escape(value string, escaper func(string) string) string {
+	if strings.Contains(value, "{{") {
+		return value
+	}
+	return escaper(value)
+}
+
+// pickMedia prefers JSON, then form bodies.
+func pickMedia(content map[string]interface{}) (string, map[string]interface{}) {
+	types := sortedKeys(content)
+	for _, want := range []string{"json", "x-www-form-urlencoded"} {
+		for _, contentType := range types {
+			if strings.Contains(contentType, want) {
+				media, _ := content[contentType].(map[string]interface{})
+				return contentType, media
+			}
+		}
+	}
+	return "", nil
+}
+
+func (d *openAPIDoc) mediaExample(media map[string]interface{}) interface{} {
+	if example, ok := media["example"]; ok {
+		return example
+	}
+	if examples, ok := media["examples"].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(examples) {
+			if value, ok := d.resolve(examples[name])["value"]; ok {
+				return value
+			}
+		}
+	}
+	return d.synthesize(media["schema"], map[string]bool{})
+}
+
+func (d *openAPIDoc) paramValue(param map[string]interface{}) string {
+	var value interface{}
+	if example, ok := param["example"]; ok {
+		value = example
+	} else if examples, ok := param["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		value = d.resolve(examples[sortedKeys(examples)[0]])["value"]
+	} else {
+		value = d.synthesize(param["schema"], map[string]bool{})
+	}
+	return text(value)
+}
+
+// synthesize builds an example value for a schema, preferring the example,
+// default and enum values it declares. Identifiers and timestamps become
+// templates so every request sends a fresh value. Recursive references
+// are left out.
+func (d *openAPIDoc) synthesize(raw interface{}, seen map[string]bool) interface{} {
+	if m, ok := raw.(map[string]interface{}); ok {
+		if ref, ok := m["$ref"].(string); ok {
+			if seen[ref] {
+				return nil
+			}
+			seen[ref] = true
+			defer delete(seen, ref)
+		}
+	}
+	s := d.resolve(raw)
+	if s == nil {
+		return nil
+	}
+	for _, key := range []string{"example", "default", "const"} {
+		if value, ok := s[key]; ok {
+			return value
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		merged := make(map[string]interface{})
+		for _, sub := range allOf {
+			if object, ok := d.synthesize(sub, seen).(map[string]interface{}); ok {
+				for k, v := range object {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if list, ok := s[key].([]interface{}); ok && len(list) > 0 {
+			return d.synthesize(list[0], seen)
+		}
+	}
+
+	switch schemaType(s) {
+	case "object":
+		object := make(map[string]interface{})
+		properties, _ := s["properties"].(map[string]interface{})
+		for name, prop := range properties {
+			if readOnly, _ := d.resolve(prop)["readOnly"].(bool); readOnly {
+				continue
+			}
+			if value := d.synthesize(prop, seen); value != nil {
+				object[name] = value
+			}
+		}
+		return object
+	case "array":
+		if item := d.synthesize(s["items"], seen); item != nil {
+			return []interface{}{item}
+		}
+		return []interface{}{}
+	case "integer":
+		if minimum, ok := s["minimum"]; ok {
+			return minimum
+		}
+		return 1
+	case "number":
+		if minimum, ok := s["minimum"]; ok {
+			return minimum
+		}
+		return 1.5
+	case "boolean":
+		return true
+	case "string":
+		switch s["format"] {
+		case "uuid":
+			return "{{uuid}}"
+		case "date-time":
+			return "{{now}}"
+		case "date":
+			// Bodies are JSON encoded, so placeholders must not contain
+			// quotes.
+			return "{{now date}}"
+		case "email":
+			return "user{{randInt 1 100000}}@example.com"
+		case "uri", "url":
+			return "https://example.com/"
+		}
+		return "string"
+	}
+	return nil
+}
+
+func schemaType(s map[string]interface{}) string {
+	switch t := s["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, item := range t {
+			if name, ok := item.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+	if _, ok := s["properties"]; ok {
+		return "object"
+	}
+	return ""
+}
+
+// responseSchema returns the inlined schema of the first 2xx JSON response.
+// Operations whose 2xx responses have no body, such as a 204, have nothing
+// to validate; any other operation without a 2xx JSON schema is an error,
+// so that validation is not silently left out.
+func (d *openAPIDoc) responseSchema(op map[string]interface{}) (interface{}, error) {
+	responses, _ := op["responses"].(map[string]interface{})
+	documented, withBody := false, false
+	for _, code := range sortedKeys(responses) {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		documented = true
+		content := d.resolve(d.resolve(responses[code])["content"])
+		withBody = withBody || len(content) > 0
+		contentType, media := pickMedia(content)
+		if media == nil || !strings.Contains(contentType, "json") || media["schema"] == nil {
+			continue
+		}
+		return d.inline(media["schema"], map[string]bool{}), nil
+	}
+	if documented && !withBody {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("no 2xx JSON response schema to validate responses against, leave the operation out with -operation or -tag")
+}
+
+// droppedSchemaKeys are documentation only and would bloat the plan.
+var droppedSchemaKeys = map[string]bool{
+	"description": true, "title": true, "example": true, "examples": true,
+	"externalDocs": true, "xml": true, "deprecated": true,
+}
+
+// inline copies node with every $ref replaced by its target. A reference
+// back into a schema that is already being inlined becomes {} (anything).
+func (d *openAPIDoc) inline(node interface{}, seen map[string]bool) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := n["$ref"].(string); ok {
+			if seen[ref] {
+				return map[string]interface{}{}
+			}
+			seen[ref] = true
+			defer delete(seen, ref)
+			return d.inline(d.lookup(ref), seen)
+		}
+		copied := make(map[string]interface{}, len(n))
+		for key, value := range n {
+			if !droppedSchemaKeys[key] {
+				copied[key] = d.inline(value, seen)
+			}
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(n))
+		for i, value := range n {
+			copied[i] = d.inline(value, seen)
+		}
+		return copied
+	}
+	return node
+}
+
+// resolve follows $ref chains and returns the object they point to, or nil.
+func (d *openAPIDoc) resolve(node interface{}) map[string]interface{} {
+	for i := 0; i < 32; i++ {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		node = d.lookup(ref)
+	}
+	return nil
+}
+
+// lookup returns the node a local reference such as
+// #/components/schemas/Pet points to.
+func (d *openAPIDoc) lookup(ref string) interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var node interface{} = d.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]interface{}:
+			node = n[token]
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil
+			}
+			node = n[i]
+		default:
+			return nil
+		}
+	}
+	return node
+}
+
+// text formats a parameter value; objects and arrays are JSON encoded.
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int, float64, bool:
+		return fmt.Sprint(v)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func formBody(value interface{}) string {
+	object, _ := value.(map[string]interface{})
+	var pairs []string
+	for _, key := range sortedKeys(object) {
+		pairs = append(pairs, url.QueryEscape(key)+"="+escape(text(object[key]), url.QueryEscape))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// stringKeys converts the keys of every map in node to strings. YAML
+// decodes a mapping with keys that are not strings, such as the unquoted
+// status codes of responses, as map[interface{}]interface{}.
+func stringKeys(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			n[key] = stringKeys(value)
+		}
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(n))
+		for key, value := range n {
+			converted[fmt.Sprint(key)] = stringKeys(value)
+		}
+		return converted
+	case []interface{}:
+		for i, value := range n {
+			n[i] = stringKeys(value)
+		}
+	}
+	return node
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
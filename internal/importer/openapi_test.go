@@ -0,0 +1,147 @@
+package importer
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"stormforce/internal/config"
+	"stormforce/internal/schema"
+)
+
+func importPetstore(t *testing.T, opts OpenAPIOptions) (*config.Plan, error) {
+	t.Helper()
+	file, err := os.Open("testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	return FromOpenAPI(file, opts)
+}
+
+func findRequest(t *testing.T, plan *config.Plan, name string) config.PlanRequest {
+	t.Helper()
+	for _, r := range plan.Requests {
+		if r.Name == name {
+			return r
+		}
+	}
+	t.Fatalf("no request %s in %s", name, stepNames(plan.Requests))
+	return config.PlanRequest{}
+}
+
+func TestOpenAPIRequests(t *testing.T) {
+	plan, err := importPetstore(t, OpenAPIOptions{})
+	if err != nil {
+		t.Fatalf("FromOpenAPI: %v", err)
+	}
+	if plan.Name != "Petstore" {
+		t.Errorf("name = %q", plan.Name)
+	}
+	if got, want := stepNames(plan.Requests), "health, listPets, createPet, showPet, deletePet"; got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+
+	list := findRequest(t, plan, "listPets")
+	if list.URL != "https://api.petstore.example.com/v1/pets?limit=1" {
+		t.Errorf("listPets URL = %s", list.URL)
+	}
+	// Placeholders in JSON bodies take no quoted arguments, which JSON
+	// encoding would escape.
+	create := findRequest(t, plan, "createPet")
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(create.Body), &body); err != nil {
+		t.Fatalf("createPet body %s: %v", create.Body, err)
+	}
+	if body["born"] != "{{now date}}" || body["name"] != "string" {
+		t.Errorf("createPet body = %s", create.Body)
+	}
+	if strings.Contains(create.Body, `\"`) {
+		t.Errorf("createPet body %s has escaped quotes", create.Body)
+	}
+
+	show := findRequest(t, plan, "showPet")
+	if show.Method != "GET" || show.URL != "https://api.petstore.example.com/v1/pets/{{uuid}}" {
+		t.Errorf("showPet = %s %s", show.Method, show.URL)
+	}
+	if show.ResponseSchema != nil {
+		t.Errorf("showPet has a response schema without -validate-responses")
+	}
+}
+
+func TestOpenAPIResponseSchemas(t *testing.T) {
+	plan, err := importPetstore(t, OpenAPIOptions{ValidateResponses: true, Tags: []string{"pets", "admin"}, Operations: []string{"showPet"}})
+	if err != nil {
+		t.Fatalf("FromOpenAPI: %v", err)
+	}
+
+	// listPets and createPet document their responses under unquoted
+	// status codes, showPet under a quoted one.
+	valid := map[string]string{
+		"listPets":  `[{"id": "p1", "name": "Rex"}]`,
+		"createPet": `{"id": "p1", "name": "Rex", "born": "2020-01-02"}`,
+		"showPet":   `{"id": "p1", "name": "Rex"}`,
+	}
+	invalid := map[string]string{
+		"listPets":  `[{"name": "Rex"}]`,
+		"createPet": `{"id": "p1"}`,
+		"showPet":   `{"id": 1, "name": "Rex"}`,
+	}
+	for name, body := range valid {
+		request := findRequest(t, plan, name)
+		if request.ResponseSchema == nil {
+			t.Errorf("%s has no response schema", name)
+			continue
+		}
+		s, err := schema.Compile(request.ResponseSchema)
+		if err != nil {
+			t.Errorf("%s response schema: %v", name, err)
+			continue
+		}
+		if err := s.ValidateJSON([]byte(body)); err != nil {
+			t.Errorf("%s rejected %s: %v", name, body, err)
+		}
+		if err := s.ValidateJSON([]byte(invalid[name])); err == nil {
+			t.Errorf("%s accepted %s", name, invalid[name])
+		}
+	}
+	if request := findRequest(t, plan, "deletePet"); request.ResponseSchema != nil {
+		t.Errorf("deletePet has a response schema for a 204 without a body: %v", request.ResponseSchema)
+	}
+}
+
+func TestOpenAPIMissingResponseSchema(t *testing.T) {
+	_, err := importPetstore(t, OpenAPIOptions{ValidateResponses: true})
+	if err == nil {
+		t.Fatal("importing health, which returns text, succeeded with -validate-responses")
+	}
+	if !strings.HasPrefix(err.Error(), "GET /health: no 2xx JSON response schema") {
+		t.Errorf("error = %v, want it to name GET /health", err)
+	}
+}
+
+func TestStringKeys(t *testing.T) {
+	decoded := map[string]interface{}{
+		"responses": map[interface{}]interface{}{
+			200:   map[string]interface{}{"list": []interface{}{map[interface{}]interface{}{true: "yes"}}},
+			"4XX": "error",
+		},
+	}
+	converted := stringKeys(decoded).(map[string]interface{})
+	responses, ok := converted["responses"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("responses = %T, want string keys", converted["responses"])
+	}
+	if responses["4XX"] != "error" {
+		t.Errorf("4XX = %v", responses["4XX"])
+	}
+	ok200, _ := responses["200"].(map[string]interface{})
+	list, _ := ok200["list"].([]interface{})
+	if len(list) != 1 {
+		t.Fatalf("200 = %v", responses["200"])
+	}
+	if item, ok := list[0].(map[string]interface{}); !ok || item["true"] != "yes" {
+		t.Errorf("list item = %#v, want string keys", list[0])
+	}
+}
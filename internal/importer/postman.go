@@ -0,0 +1,345 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"stormforce/internal/config"
+)
+
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanVariable `json:"variable"`
+	Auth     *postmanAuth      `json:"auth"`
+}
+
+// postmanItem is a request or, when Item is set, a folder.
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []postmanItem   `json:"item"`
+	Request *postmanRequest `json:"request"`
+	Auth    *postmanAuth    `json:"auth"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanVariable `json:"header"`
+	Body   *postmanBody      `json:"body"`
+	URL    postmanURL        `json:"url"`
+	Auth   *postmanAuth      `json:"auth"`
+}
+
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw"`
+	URLEncoded []postmanVariable `json:"urlencoded"`
+	FormData   []postmanVariable `json:"formdata"`
+	GraphQL    struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+}
+
+// postmanURL is either a string or an object with the parts of the URL.
+type postmanURL struct {
+	Raw string `json:"raw"`
+}
+
+func (u *postmanURL) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &u.Raw)
+	}
+	type plain postmanURL
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+type postmanAuth struct {
+	Type   string            `json:"type"`
+	Bearer []postmanVariable `json:"bearer"`
+	Basic  []postmanVariable `json:"basic"`
+	APIKey []postmanVariable `json:"apikey"`
+	OAuth2 []postmanVariable `json:"oauth2"`
+}
+
+// postmanVariable is a key/value pair as used for variables, headers, form
+// fields and auth attributes.
+type postmanVariable struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Type     string      `json:"type"`
+	Disabled bool        `json:"disabled"`
+	Enabled  *bool       `json:"enabled"` // environments use enabled instead
+}
+
+func (v postmanVariable) active() bool {
+	return !v.Disabled && (v.Enabled == nil || *v.Enabled)
+}
+
+type postmanEnvironment struct {
+	Values []postmanVariable `json:"values"`
+}
+
+// postmanDynamic maps Postman's dynamic variables to template functions.
+// They take no quoted arguments since GraphQL bodies are JSON encoded.
+var postmanDynamic = map[string]string{
+	"$guid":         "{{uuid}}",
+	"$randomUUID":   "{{uuid}}",
+	"$timestamp":    "{{now unix}}",
+	"$isoTimestamp": "{{now}}",
+	"$randomInt":    "{{randInt 0 1000}}",
+}
+
+var postmanVariablePattern = regexp.MustCompile(`{{\s*([^{}]+?)\s*}}`)
+
+// postmanImport holds the variables of a collection and its environment.
+type postmanImport struct {
+	vars map[string]string
+	used names
+	plan *config.Plan
+}
+
+// FromPostman converts a Postman v2.1 collection into a scenario with a step
+// per request, in collection order. Variables are taken from environment,
+// when given, and then from the collection. Variables without a value, such
+// as those set by scripts, are kept as {{name}} for an extractor or data
+// file to fill in; scripts themselves are not imported.
+func FromPostman(collection io.Reader, environment io.Reader) (*config.Plan, error) {
+	var c postmanCollection
+	if err := json.NewDecoder(collection).Decode(&c); err != nil {
+		return nil, fmt.Errorf("error parsing Postman collection: %v", err)
+	}
+	if c.Info.Schema != "" && !strings.Contains(c.Info.Schema, "v2.1") {
+		return nil, fmt.Errorf("unsupported collection schema %s, export the collection as v2.1", c.Info.Schema)
+	}
+
+	imp := &postmanImport{
+		vars: make(map[string]string),
+		used: names{},
+		plan: &config.Plan{Version: config.PlanVersion, Name: c.Info.Name},
+	}
+	for _, v := range c.Variable {
+		if v.active() {
+			imp.vars[v.Key] = text(v.Value)
+		}
+	}
+	if environment != nil {
+		var env postmanEnvironment
+		if err := json.NewDecoder(environment).Decode(&env); err != nil {
+			return nil, fmt.Errorf("error parsing Postman environment: %v", err)
+		}
+		for _, v := range env.Values {
+			if v.active() {
+				imp.vars[v.Key] = text(v.Value)
+			}
+		}
+	}
+
+	if err := imp.items(c.Item, "", c.Auth); err != nil {
+		return nil, err
+	}
+	if len(imp.plan.Scenario) == 0 {
+		return nil, fmt.Errorf("the collection has no requests")
+	}
+	return imp.plan, nil
+}
+
+// items imports requests depth first. Folder names prefix request names and
+// requests without auth inherit it from the closest folder that has one.
+func (imp *postmanImport) items(items []postmanItem, prefix string, auth *postmanAuth) error {
+	for _, item := range items {
+		itemAuth := auth
+		if item.Auth != nil {
+			itemAuth = item.Auth
+		}
+		if item.Request == nil {
+			if err := imp.items(item.Item, prefix+item.Name+" / ", itemAuth); err != nil {
+				return err
+			}
+			continue
+		}
+		if item.Request.Auth != nil {
+			itemAuth = item.Request.Auth
+		}
+		request, err := imp.request(item.Request, itemAuth)
+		if err != nil {
+			return fmt.Errorf("request %s%s: %v", prefix, item.Name, err)
+		}
+		request.Name = imp.used.unique(prefix + item.Name)
+		imp.plan.Scenario = append(imp.plan.Scenario, request)
+	}
+	return nil
+}
+
+func (imp *postmanImport) request(r *postmanRequest, auth *postmanAuth) (config.PlanRequest, error) {
+	request := config.PlanRequest{Method: strings.ToUpper(r.Method), Headers: make(map[string]string)}
+	if request.Method == "" {
+		request.Method = "GET"
+	}
+	request.URL = imp.resolve(r.URL.Raw)
+	if request.URL == "" {
+		return request, fmt.Errorf("no URL")
+	}
+	if !strings.Contains(request.URL, "://") {
+		request.URL = "http://" + request.URL
+	}
+
+	for _, h := range r.Header {
+		if h.active() && keepHeader(h.Key) {
+			request.Headers[h.Key] = imp.resolve(text(h.Value))
+		}
+	}
+	if err := imp.auth(&request, auth); err != nil {
+		return request, err
+	}
+	if r.Body != nil {
+		if err := imp.body(&request, r.Body); err != nil {
+			return request, err
+		}
+	}
+	if len(request.Headers) == 0 {
+		request.Headers = nil
+	}
+	return request, nil
+}
+
+func (imp *postmanImport) auth(request *config.PlanRequest, auth *postmanAuth) error {
+	if auth == nil {
+		return nil
+	}
+	attr := func(list []postmanVariable, key string) string {
+		for _, v := range list {
+			if v.Key == key {
+				return imp.resolve(text(v.Value))
+			}
+		}
+		return ""
+	}
+
+	switch auth.Type {
+	case "noauth", "":
+	case "bearer":
+		request.Headers["Authorization"] = "Bearer " + attr(auth.Bearer, "token")
+	case "oauth2":
+		request.Headers["Authorization"] = "Bearer " + attr(auth.OAuth2, "accessToken")
+	case "basic":
+		credentials := attr(auth.Basic, "username") + ":" + attr(auth.Basic, "password")
+		request.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	case "apikey":
+		key, value := attr(auth.APIKey, "key"), attr(auth.APIKey, "value")
+		if attr(auth.APIKey, "in") != "query" {
+			request.Headers[key] = value
+			break
+		}
+		separator := "?"
+		if strings.Contains(request.URL, "?") {
+			separator = "&"
+		}
+		request.URL += separator + url.QueryEscape(key) + "=" + escape(value, url.QueryEscape)
+	default:
+		return fmt.Errorf("unsupported auth type %q", auth.Type)
+	}
+	return nil
+}
+
+func (imp *postmanImport) body(request *config.PlanRequest, b *postmanBody) error {
+	switch b.Mode {
+	case "", "none":
+	case "raw":
+		request.Body = imp.resolve(b.Raw)
+		contentTypes := map[string]string{
+			"json":       "application/json",
+			"xml":        "application/xml",
+			"html":       "text/html",
+			"javascript": "application/javascript",
+			"text":       "text/plain",
+		}
+		if contentType, ok := contentTypes[b.Options.Raw.Language]; ok && request.Body != "" {
+			setDefaultHeader(request.Headers, "Content-Type", contentType)
+		}
+	case "urlencoded":
+		var pairs []string
+		for _, field := range b.URLEncoded {
+			if field.active() {
+				pairs = append(pairs, url.QueryEscape(imp.resolve(field.Key))+"="+escape(imp.resolve(text(field.Value)), url.QueryEscape))
+			}
+		}
+		request.Body = strings.Join(pairs, "&")
+		setDefaultHeader(request.Headers, "Content-Type", "application/x-www-form-urlencoded")
+	case "formdata":
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		if err := writer.SetBoundary("stormforce-form-boundary"); err != nil {
+			return err
+		}
+		for _, field := range b.FormData {
+			if !field.active() {
+				continue
+			}
+			if field.Type == "file" {
+				return fmt.Errorf("form field %s: file uploads are not supported", field.Key)
+			}
+			if err := writer.WriteField(imp.resolve(field.Key), imp.resolve(text(field.Value))); err != nil {
+				return err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		request.Body = body.String()
+		setDefaultHeader(request.Headers, "Content-Type", writer.FormDataContentType())
+	case "graphql":
+		payload := map[string]interface{}{"query": imp.resolve(b.GraphQL.Query)}
+		if variables := strings.TrimSpace(imp.resolve(b.GraphQL.Variables)); variables != "" {
+			payload["variables"] = json.RawMessage(variables)
+		}
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("error encoding GraphQL body: %v", err)
+		}
+		request.Body = string(encoded)
+		setDefaultHeader(request.Headers, "Content-Type", "application/json")
+	default:
+		return fmt.Errorf("unsupported body mode %q", b.Mode)
+	}
+	return nil
+}
+
+// resolve replaces {{name}} with the variable's value, following variables
+// that refer to other variables. Dynamic variables such as {{$guid}} become
+// template functions, and unknown variables are left for the run to fill in.
+func (imp *postmanImport) resolve(s string) string {
+	for depth := 0; depth < 10 && strings.Contains(s, "{{"); depth++ {
+		changed := false
+		s = postmanVariablePattern.ReplaceAllStringFunc(s, func(match string) string {
+			name := postmanVariablePattern.FindStringSubmatch(match)[1]
+			if value, ok := imp.vars[name]; ok {
+				changed = true
+				return value
+			}
+			if value, ok := postmanDynamic[name]; ok {
+				return value
+			}
+			return match
+		})
+		if !changed {
+			break
+		}
+	}
+	return s
+}
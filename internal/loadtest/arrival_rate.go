@@ -0,0 +1,92 @@
+package loadtest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ArrivalRateExecutor starts jobs on a fixed schedule regardless of how long
+// earlier jobs take (an open workload model). Jobs run concurrently up to
+// maxInFlight; arrivals beyond that cap are dropped and counted. Each job is
+// passed the in-flight slot (1 to maxInFlight) it occupies, which no other
+// running job holds.
+type ArrivalRateExecutor struct {
+	rate        float64
+	maxInFlight int
+	slots       chan int
+	wg          sync.WaitGroup
+	dropped     atomic.Int64
+}
+
+func NewArrivalRateExecutor(rate float64, maxInFlight int) *ArrivalRateExecutor {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	e := &ArrivalRateExecutor{
+		rate:        rate,
+		maxInFlight: maxInFlight,
+		slots:       make(chan int, maxInFlight),
+	}
+	for slot := 1; slot <= maxInFlight; slot++ {
+		e.slots <- slot
+	}
+	return e
+}
+
+// Dispatch starts job once per arrival until done reports true for the
+// number of arrivals so far or ctx is done, then waits for the in-flight jobs
+// to finish.
+func (e *ArrivalRateExecutor) Dispatch(ctx context.Context, done func(arrivals int) bool, job func(slot int)) {
+	interval := time.Duration(float64(time.Second) / e.rate)
+	start := time.Now()
+	arrivals := 0
+
+	for ctx.Err() == nil && !done(arrivals) {
+		// Catch up on every arrival that is due so that sleep granularity
+		// does not lower the offered rate.
+		due := int(time.Since(start).Seconds()*e.rate) + 1
+		for arrivals < due && !done(arrivals) {
+			arrivals++
+			e.launch(job)
+		}
+
+		next := start.Add(time.Duration(arrivals) * interval)
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+			case <-timer.C:
+			}
+			timer.Stop()
+		}
+	}
+
+	e.wg.Wait()
+}
+
+func (e *ArrivalRateExecutor) launch(job func(slot int)) {
+	var slot int
+	select {
+	case slot = <-e.slots:
+	default:
+		e.dropped.Add(1)
+		return
+	}
+
+	e.wg.Add(1)
+	go func() {
+		defer func() {
+			e.slots <- slot
+			e.wg.Done()
+		}()
+		job(slot)
+	}()
+}
+
+// Dropped returns the number of arrivals skipped because maxInFlight
+// requests were already running.
+func (e *ArrivalRateExecutor) Dropped() int {
+	return int(e.dropped.Load())
+}
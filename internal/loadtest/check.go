@@ -0,0 +1,174 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"stormforce/internal/config"
+	"stormforce/internal/results"
+	"stormforce/internal/schema"
+)
+
+// check is a compiled config.Check.
+type check struct {
+	config.Check
+	path   []pathElem
+	re     *regexp.Regexp
+	schema *schema.Schema
+}
+
+func newCheck(c config.Check) (*check, error) {
+	x := &check{Check: c}
+	var err error
+	switch c.Kind {
+	case config.CheckJSON:
+		x.path, err = parseJSONPath(c.Target)
+	case config.CheckSchema:
+		x.schema, err = schema.Compile(c.Schema)
+	}
+	if err == nil && c.Op == config.CheckMatches {
+		x.re, err = regexp.Compile(c.Value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("check %s: %v", c.Name, err)
+	}
+	return x, nil
+}
+
+// errorClass is the error class of a request failed by the check.
+func (x *check) errorClass() string {
+	switch x.Kind {
+	case config.CheckStatus:
+		return results.ErrorStatus
+	case config.CheckSchema:
+		return results.ErrorSchema
+	}
+	return results.ErrorCheck
+}
+
+// response is what checks inspect. JSON checks share one decoding of the
+// body.
+type response struct {
+	status   int
+	header   http.Header
+	body     []byte
+	duration float64 // seconds
+
+	decoded bool
+	value   interface{}
+	err     error
+}
+
+func (r *response) json() (interface{}, error) {
+	if !r.decoded {
+		r.value, r.err = decodeJSON(r.body)
+		r.decoded = true
+	}
+	return r.value, r.err
+}
+
+// run returns why the check failed, or nil when it passed.
+func (x *check) run(r *response) error {
+	switch x.Kind {
+	case config.CheckStatus:
+		for _, s := range x.Statuses {
+			if r.status >= s.Min && r.status <= s.Max {
+				return nil
+			}
+		}
+		return fmt.Errorf("status %d, expected %s", r.status, config.FormatStatuses(x.Statuses))
+	case config.CheckHeader:
+		values, ok := r.header[http.CanonicalHeaderKey(x.Target)]
+		return x.compare("header "+x.Target, strings.Join(values, ", "), ok)
+	case config.CheckJSON:
+		root, err := r.json()
+		if err != nil {
+			return err
+		}
+		value, ok := lookupPath(root, x.path)
+		if x.Op != config.CheckType {
+			return x.compare(x.Target, jsonText(value), ok)
+		}
+		if !ok {
+			return fmt.Errorf("%s is missing", x.Target)
+		}
+		if t := jsonType(value); t != x.Value && !(x.Value == "number" && t == "integer") {
+			return fmt.Errorf("%s is %s, expected %s", x.Target, t, x.Value)
+		}
+	case config.CheckBody:
+		return x.compare("body", string(r.body), true)
+	case config.CheckBodySize:
+		size := int64(len(r.body))
+		if size < x.MinSize || (x.MaxSize > 0 && size > x.MaxSize) {
+			return fmt.Errorf("body is %d bytes", size)
+		}
+	case config.CheckLatency:
+		if r.duration > x.MaxLatency.Seconds() {
+			return fmt.Errorf("took %.3fs, limit %s", r.duration, x.MaxLatency)
+		}
+	case config.CheckSchema:
+		return x.schema.ValidateJSON(r.body)
+	}
+	return nil
+}
+
+// compare applies the check's operator to text, the value of what. exists
+// reports whether what was in the response at all.
+func (x *check) compare(what, text string, exists bool) error {
+	switch {
+	case x.Op == config.CheckAbsent:
+		if exists {
+			return fmt.Errorf("%s is present", what)
+		}
+		return nil
+	case !exists:
+		return fmt.Errorf("%s is missing", what)
+	}
+
+	switch x.Op {
+	case config.CheckEquals:
+		if text != x.Value {
+			return fmt.Errorf("%s is %q, expected %q", what, shorten(text), x.Value)
+		}
+	case config.CheckContains:
+		if !strings.Contains(text, x.Value) {
+			return fmt.Errorf("%s does not contain %q", what, x.Value)
+		}
+	case config.CheckMatches:
+		if !x.re.MatchString(text) {
+			return fmt.Errorf("%s does not match %s", what, x.Value)
+		}
+	}
+	return nil
+}
+
+// jsonType names the JSON type of a value decoded by decodeJSON.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// shorten keeps error messages readable when a whole body is compared.
+func shorten(s string) string {
+	if len(s) <= 80 {
+		return s
+	}
+	return s[:77] + "..."
+}
@@ -0,0 +1,164 @@
+package loadtest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"stormforce/internal/config"
+	"stormforce/internal/results"
+)
+
+func TestCheckRun(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	resp := func() *response {
+		return &response{
+			status: 404,
+			header: http.Header{
+				"Content-Type": {"application/json; charset=utf-8"},
+				"X-Request-Id": {"a1", "b2"},
+			},
+			body:     []byte(`{"user": {"name": "Bob", "id": 7, "tags": ["a"], "score": 1.5, "note": null}, "ok": true}`),
+			duration: 0.25,
+		}
+	}
+	statuses := func(s string) []config.StatusRange {
+		ranges, err := config.ParseStatuses(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ranges
+	}
+
+	tests := []struct {
+		name  string
+		check config.Check
+		body  string // replaces the JSON body when set
+		err   string // "" when the check passes
+	}{
+		{"status in range", config.Check{Kind: config.CheckStatus, Statuses: statuses("400-499")}, "", ""},
+		{"status outside", config.Check{Kind: config.CheckStatus, Statuses: statuses("2xx,304")}, "", "status 404, expected 2xx,304"},
+
+		{"header equals", config.Check{Kind: config.CheckHeader, Target: "content-type", Op: config.CheckEquals, Value: "application/json; charset=utf-8"}, "", ""},
+		{"header differs", config.Check{Kind: config.CheckHeader, Target: "Content-Type", Op: config.CheckEquals, Value: "text/html"}, "", `header Content-Type is "application/json; charset=utf-8", expected "text/html"`},
+		{"header values joined", config.Check{Kind: config.CheckHeader, Target: "X-Request-Id", Op: config.CheckEquals, Value: "a1, b2"}, "", ""},
+		{"header contains", config.Check{Kind: config.CheckHeader, Target: "Content-Type", Op: config.CheckContains, Value: "json"}, "", ""},
+		{"header lacks", config.Check{Kind: config.CheckHeader, Target: "Content-Type", Op: config.CheckContains, Value: "xml"}, "", `header Content-Type does not contain "xml"`},
+		{"header matches", config.Check{Kind: config.CheckHeader, Target: "Content-Type", Op: config.CheckMatches, Value: "^application/"}, "", ""},
+		{"header mismatches", config.Check{Kind: config.CheckHeader, Target: "Content-Type", Op: config.CheckMatches, Value: "^text/"}, "", "header Content-Type does not match ^text/"},
+		{"header exists", config.Check{Kind: config.CheckHeader, Target: "X-Request-Id", Op: config.CheckExists}, "", ""},
+		{"header missing", config.Check{Kind: config.CheckHeader, Target: "ETag", Op: config.CheckExists}, "", "header ETag is missing"},
+		{"header missing for equals", config.Check{Kind: config.CheckHeader, Target: "ETag", Op: config.CheckEquals, Value: "x"}, "", "header ETag is missing"},
+		{"header absent", config.Check{Kind: config.CheckHeader, Target: "ETag", Op: config.CheckAbsent}, "", ""},
+		{"header present", config.Check{Kind: config.CheckHeader, Target: "X-Request-Id", Op: config.CheckAbsent}, "", "header X-Request-Id is present"},
+
+		{"json equals string", config.Check{Kind: config.CheckJSON, Target: "$.user.name", Op: config.CheckEquals, Value: "Bob"}, "", ""},
+		{"json equals number", config.Check{Kind: config.CheckJSON, Target: "$.user.id", Op: config.CheckEquals, Value: "7"}, "", ""},
+		{"json differs", config.Check{Kind: config.CheckJSON, Target: "$.user.name", Op: config.CheckEquals, Value: "Ann"}, "", `$.user.name is "Bob", expected "Ann"`},
+		{"json contains", config.Check{Kind: config.CheckJSON, Target: "$.user.name", Op: config.CheckContains, Value: "Ann"}, "", `$.user.name does not contain "Ann"`},
+		{"json matches", config.Check{Kind: config.CheckJSON, Target: "$.user.tags[0]", Op: config.CheckMatches, Value: "^[a-z]$"}, "", ""},
+		{"json mismatches", config.Check{Kind: config.CheckJSON, Target: "$.user.id", Op: config.CheckMatches, Value: "^[a-z]+$"}, "", "$.user.id does not match ^[a-z]+$"},
+		{"json exists", config.Check{Kind: config.CheckJSON, Target: "$.user.note", Op: config.CheckExists}, "", ""},
+		{"json missing", config.Check{Kind: config.CheckJSON, Target: "$.user.email", Op: config.CheckExists}, "", "$.user.email is missing"},
+		{"json absent", config.Check{Kind: config.CheckJSON, Target: "$.user.email", Op: config.CheckAbsent}, "", ""},
+		{"json present", config.Check{Kind: config.CheckJSON, Target: "$.ok", Op: config.CheckAbsent}, "", "$.ok is present"},
+		{"json type", config.Check{Kind: config.CheckJSON, Target: "$.user.tags", Op: config.CheckType, Value: "array"}, "", ""},
+		{"json integer is a number", config.Check{Kind: config.CheckJSON, Target: "$.user.id", Op: config.CheckType, Value: "number"}, "", ""},
+		{"json number is not an integer", config.Check{Kind: config.CheckJSON, Target: "$.user.score", Op: config.CheckType, Value: "integer"}, "", "$.user.score is number, expected integer"},
+		{"json null type", config.Check{Kind: config.CheckJSON, Target: "$.user.note", Op: config.CheckType, Value: "string"}, "", "$.user.note is null, expected string"},
+		{"json type missing", config.Check{Kind: config.CheckJSON, Target: "$.user.email", Op: config.CheckType, Value: "string"}, "", "$.user.email is missing"},
+
+		{"body equals", config.Check{Kind: config.CheckBody, Op: config.CheckEquals, Value: "pong"}, "pong", ""},
+		{"body differs", config.Check{Kind: config.CheckBody, Op: config.CheckEquals, Value: "pong"}, long, `body is "` + strings.Repeat("x", 77) + `...", expected "pong"`},
+		{"body contains", config.Check{Kind: config.CheckBody, Op: config.CheckContains, Value: `"ok": true`}, "", ""},
+		{"body lacks", config.Check{Kind: config.CheckBody, Op: config.CheckContains, Value: "error"}, "", `body does not contain "error"`},
+		{"body mismatches", config.Check{Kind: config.CheckBody, Op: config.CheckMatches, Value: "^pong$"}, "ping", "body does not match ^pong$"},
+
+		{"body size within", config.Check{Kind: config.CheckBodySize, MinSize: 1, MaxSize: 100}, "pong", ""},
+		{"body too small", config.Check{Kind: config.CheckBodySize, MinSize: 10}, "pong", "body is 4 bytes"},
+		{"body too large", config.Check{Kind: config.CheckBodySize, MaxSize: 50}, long, "body is 100 bytes"},
+		{"body size unbounded", config.Check{Kind: config.CheckBodySize, MinSize: 10}, long, ""},
+
+		{"latency within", config.Check{Kind: config.CheckLatency, MaxLatency: time.Second}, "", ""},
+		{"latency exceeded", config.Check{Kind: config.CheckLatency, MaxLatency: 100 * time.Millisecond}, "", "took 0.250s, limit 100ms"},
+
+		{"schema valid", config.Check{Kind: config.CheckSchema, Schema: map[string]interface{}{"required": []interface{}{"user"}}}, "", ""},
+		{
+			"schema invalid",
+			config.Check{Kind: config.CheckSchema, Schema: map[string]interface{}{
+				"properties": map[string]interface{}{"user": map[string]interface{}{
+					"properties": map[string]interface{}{"id": map[string]interface{}{"type": "string"}},
+				}},
+			}},
+			"", "$.user.id: expected string, got integer",
+		},
+	}
+
+	for _, tt := range tests {
+		x, err := newCheck(tt.check)
+		if err != nil {
+			t.Errorf("%s: newCheck: %v", tt.name, err)
+			continue
+		}
+		r := resp()
+		if tt.body != "" {
+			r.body = []byte(tt.body)
+		}
+		err = x.run(r)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: failed: %v", tt.name, err)
+		case tt.err != "" && err == nil:
+			t.Errorf("%s: passed, want %q", tt.name, tt.err)
+		case tt.err != "" && err.Error() != tt.err:
+			t.Errorf("%s: %q, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestCheckNotJSON(t *testing.T) {
+	for _, c := range []config.Check{
+		{Kind: config.CheckJSON, Target: "$.id", Op: config.CheckExists},
+		{Kind: config.CheckSchema, Schema: map[string]interface{}{}},
+	} {
+		x, err := newCheck(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = x.run(&response{status: 200, body: []byte("<html>")})
+		if err == nil || !strings.HasPrefix(err.Error(), "response is not JSON: ") {
+			t.Errorf("%s check on HTML = %v, want a decoding error", c.Kind, err)
+		}
+	}
+}
+
+func TestNewCheckErrors(t *testing.T) {
+	tests := []struct {
+		check config.Check
+		err   string
+	}{
+		{config.Check{Name: "path", Kind: config.CheckJSON, Target: "$.tags[x]", Op: config.CheckExists}, `check path: invalid JSON path "$.tags[x]": bad index "x"`},
+		{config.Check{Name: "regex", Kind: config.CheckBody, Op: config.CheckMatches, Value: "("}, "check regex: error parsing regexp"},
+		{config.Check{Name: "schema", Kind: config.CheckSchema, Schema: "object"}, "check schema: $: schema must be an object"},
+	}
+	for _, tt := range tests {
+		if _, err := newCheck(tt.check); err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("newCheck(%s) = %v, want %q...", tt.check.Name, err, tt.err)
+		}
+	}
+}
+
+func TestCheckErrorClass(t *testing.T) {
+	for kind, want := range map[string]string{
+		config.CheckStatus:  results.ErrorStatus,
+		config.CheckSchema:  results.ErrorSchema,
+		config.CheckHeader:  results.ErrorCheck,
+		config.CheckJSON:    results.ErrorCheck,
+		config.CheckLatency: results.ErrorCheck,
+	} {
+		if got := (&check{Check: config.Check{Kind: kind}}).errorClass(); got != want {
+			t.Errorf("%s check error class = %s, want %s", kind, got, want)
+		}
+	}
+}
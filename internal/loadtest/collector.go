@@ -0,0 +1,88 @@
+package loadtest
+
+import (
+	"time"
+
+	"stormforce/internal/results"
+)
+
+// collector is the only goroutine that writes to a Results value. Workers
+// send samples over a channel, so no locking is needed around the slices
+// and counters however many workers are running.
+type collector struct {
+	samples chan results.Sample
+	begin   chan time.Time
+	done    chan struct{}
+	res     *results.Results
+
+	// monitor, when set, is checked every second and abort is called once
+	// with the reason when a threshold fails.
+	monitor *monitor
+	abort   func(reason string)
+	live    *results.Live // nil without a live display
+}
+
+func newCollector(res *results.Results, buffer int, m *monitor, abort func(reason string), live *results.Live) *collector {
+	c := &collector{
+		samples: make(chan results.Sample, buffer),
+		begin:   make(chan time.Time),
+		done:    make(chan struct{}),
+		res:     res,
+		monitor: m,
+		abort:   abort,
+		live:    live,
+	}
+	go c.run()
+	return c
+}
+
+func (c *collector) run() {
+	defer close(c.done)
+	var tick <-chan time.Time
+	if c.monitor != nil {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case sample, ok := <-c.samples:
+			if !ok {
+				return
+			}
+			c.res.Add(sample)
+			if c.live != nil {
+				c.live.Add(sample)
+			}
+			if c.monitor != nil {
+				c.monitor.window.Add(sample)
+			}
+		case start := <-c.begin:
+			c.res.Begin(start)
+		case now := <-tick:
+			if reason := c.monitor.check(now); reason != "" {
+				c.res.Aborted = true
+				c.monitor, tick = nil, nil
+				c.abort(reason)
+			}
+		}
+	}
+}
+
+// Begin marks the start of the measured run. It returns once the collector
+// has taken it, so every sample recorded afterwards is measured from start.
+func (c *collector) Begin(start time.Time) {
+	c.begin <- start
+}
+
+// Record hands a sample to the collector goroutine. It is safe to call from
+// any number of goroutines until Close is called.
+func (c *collector) Record(sample results.Sample) {
+	c.samples <- sample
+}
+
+// Close waits until every recorded sample has been added to the results.
+func (c *collector) Close() {
+	close(c.samples)
+	<-c.done
+}
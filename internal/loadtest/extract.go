@@ -0,0 +1,174 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"stormforce/internal/config"
+)
+
+// extractor pulls one value out of a response into a VU variable.
+type extractor struct {
+	variable string
+	source   string
+	expr     string
+	path     []pathElem
+	re       *regexp.Regexp
+}
+
+// pathElem is one step of a JSON path: an object key, or an array index
+// when index is not negative.
+type pathElem struct {
+	key   string
+	index int
+}
+
+func newExtractor(e config.Extractor) (*extractor, error) {
+	x := &extractor{variable: e.Var, source: e.Source, expr: e.Expr}
+	var err error
+	switch e.Source {
+	case config.ExtractJSON:
+		x.path, err = parseJSONPath(e.Expr)
+	case config.ExtractRegex:
+		x.re, err = regexp.Compile(e.Expr)
+	case config.ExtractHeader:
+	default:
+		err = fmt.Errorf("unknown extractor source %q", e.Source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("extractor %s: %v", e.Var, err)
+	}
+	return x, nil
+}
+
+func (x *extractor) extract(resp *http.Response, body []byte) (string, error) {
+	switch x.source {
+	case config.ExtractJSON:
+		return lookupJSON(body, x.path, x.expr)
+	case config.ExtractRegex:
+		m := x.re.FindSubmatch(body)
+		if m == nil {
+			return "", fmt.Errorf("regex %q did not match", x.expr)
+		}
+		if len(m) > 1 {
+			return string(m[1]), nil
+		}
+		return string(m[0]), nil
+	default:
+		value := resp.Header.Get(x.expr)
+		if value == "" {
+			return "", fmt.Errorf("header %s is missing", x.expr)
+		}
+		return value, nil
+	}
+}
+
+// parseJSONPath parses a path such as $.data.items[0].id. The leading $ and
+// dot are optional.
+func parseJSONPath(expr string) ([]pathElem, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	var path []pathElem
+	for rest != "" {
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: unterminated index", expr)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: bad index %q", expr, rest[1:end])
+			}
+			path = append(path, pathElem{index: index})
+			rest = rest[end+1:]
+			continue
+		}
+		if rest[0] != '.' {
+			return nil, fmt.Errorf("invalid JSON path %q", expr)
+		}
+		rest = rest[1:]
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("invalid JSON path %q: empty key", expr)
+		}
+		path = append(path, pathElem{key: rest[:end], index: -1})
+		rest = rest[end:]
+	}
+	return path, nil
+}
+
+// lookupJSON returns the value at path in body. Strings are returned as is,
+// other values in their JSON encoding.
+func lookupJSON(body []byte, path []pathElem, expr string) (string, error) {
+	value, err := decodeJSON(body)
+	if err != nil {
+		return "", err
+	}
+	value, ok := lookupPath(value, path)
+	if !ok {
+		return "", fmt.Errorf("JSON path %q not found", expr)
+	}
+	if value == nil {
+		return "", fmt.Errorf("JSON path %q is null", expr)
+	}
+	return jsonText(value), nil
+}
+
+// decodeJSON decodes a response body, keeping numbers as json.Number so
+// they are passed on as written.
+func decodeJSON(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("response is not JSON: %v", err)
+	}
+	return value, nil
+}
+
+// lookupPath returns the value at path and whether it exists.
+func lookupPath(value interface{}, path []pathElem) (interface{}, bool) {
+	for _, elem := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[elem.key]
+			if elem.index >= 0 || !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			if elem.index < 0 || elem.index >= len(v) {
+				return nil, false
+			}
+			value = v[elem.index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// jsonText returns strings as is and other decoded JSON values, including
+// null, in their JSON encoding.
+func jsonText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
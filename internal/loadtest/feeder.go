@@ -0,0 +1,184 @@
+package loadtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"stormforce/internal/config"
+)
+
+// feeder hands out the rows of a data file. Rows are loaded into memory
+// when the run starts.
+type feeder struct {
+	file        string
+	strategy    string
+	onExhausted string
+	rows        []map[string]string
+	columns     []string
+	next        atomic.Int64
+}
+
+func loadFeeders(configs []config.Feeder) ([]*feeder, error) {
+	feeders := make([]*feeder, 0, len(configs))
+	for _, fc := range configs {
+		f, err := loadFeeder(fc)
+		if err != nil {
+			return nil, err
+		}
+		feeders = append(feeders, f)
+	}
+	return feeders, nil
+}
+
+func loadFeeder(cfg config.Feeder) (*feeder, error) {
+	var rows []map[string]string
+	var err error
+	switch strings.ToLower(filepath.Ext(cfg.File)) {
+	case ".csv":
+		rows, err = readCSV(cfg.File)
+	case ".jsonl", ".ndjson", ".json":
+		rows, err = readJSONLines(cfg.File)
+	default:
+		return nil, fmt.Errorf("data file %s: unknown format, use .csv or .jsonl", cfg.File)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("data file %s: no rows", cfg.File)
+	}
+	// JSON-lines rows may have different keys; every column is set on each
+	// feed so that a value from a previous row never lingers.
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range rows {
+		for column := range row {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	return &feeder{file: cfg.File, strategy: cfg.Strategy, onExhausted: cfg.OnExhausted, rows: rows, columns: columns}, nil
+}
+
+// take returns the next row, or false when the rows ran out and the policy
+// is to stop.
+func (f *feeder) take() (map[string]string, bool) {
+	if f.strategy == config.FeedRandom {
+		return f.rows[rand.IntN(len(f.rows))], true
+	}
+	i := int(f.next.Add(1) - 1)
+	if i >= len(f.rows) {
+		if f.onExhausted == config.FeedStop {
+			return nil, false
+		}
+		i %= len(f.rows)
+	}
+	return f.rows[i], true
+}
+
+// feed copies the row for vu's current iteration into its variables and
+// reports false when the feeder is exhausted. Unique rows are taken once per
+// VU and kept. Warmup iterations read rows without consuming them.
+func (f *feeder) feed(vu *virtualUser, warmup bool) bool {
+	var row map[string]string
+	switch {
+	case warmup:
+		row = f.rows[(vu.iteration-1)%len(f.rows)]
+	case f.strategy == config.FeedUnique && vu.rows[f] != nil:
+		row = vu.rows[f]
+	default:
+		var ok bool
+		if row, ok = f.take(); !ok {
+			return false
+		}
+		if f.strategy == config.FeedUnique {
+			vu.rows[f] = row
+		}
+	}
+	for _, column := range f.columns {
+		vu.vars[column] = row[column]
+	}
+	return true
+}
+
+func readCSV(path string) ([]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening data file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("data file %s: error reading header: %v", path, err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("data file %s: %v", path, err)
+		}
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readJSONLines(path string) ([]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening data file: %v", err)
+	}
+	defer file.Close()
+
+	var rows []map[string]string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			return nil, fmt.Errorf("%s:%d: expected a JSON object: %v", path, line, err)
+		}
+		row := make(map[string]string, len(object))
+		for key, value := range object {
+			if value != nil {
+				row[key] = jsonText(value)
+			} else {
+				row[key] = ""
+			}
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("data file %s: %v", path, err)
+	}
+	return rows, nil
+}
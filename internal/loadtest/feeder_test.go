@@ -0,0 +1,167 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"stormforce/internal/config"
+)
+
+// writeUsers writes a CSV data file with n users, user1 to userN.
+func writeUsers(t *testing.T, n int) string {
+	t.Helper()
+	lines := []string{"user,password"}
+	for i := 1; i <= n; i++ {
+		lines = append(lines, fmt.Sprintf("user%d,secret%d", i, i))
+	}
+	path := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestFeeder(t *testing.T, rows int, strategy, onExhausted string) *feeder {
+	t.Helper()
+	f, err := loadFeeder(config.Feeder{File: writeUsers(t, rows), Strategy: strategy, OnExhausted: onExhausted})
+	if err != nil {
+		t.Fatalf("loadFeeder: %v", err)
+	}
+	return f
+}
+
+// feedUser runs the feeder for the next iteration of vu and returns the
+// user it was given, or "" when the feeder is exhausted.
+func feedUser(f *feeder, vu *virtualUser, warmup bool) string {
+	vu.iteration++
+	if !f.feed(vu, warmup) {
+		return ""
+	}
+	return vu.vars["user"]
+}
+
+func TestFeederSequential(t *testing.T) {
+	tests := []struct {
+		onExhausted string
+		want        []string
+	}{
+		{config.FeedRecycle, []string{"user1", "user2", "user3", "user1", "user2"}},
+		{config.FeedStop, []string{"user1", "user2", "user3", "", ""}},
+	}
+	for _, tt := range tests {
+		f := newTestFeeder(t, 3, config.FeedSequential, tt.onExhausted)
+		vu := newVirtualUser(1)
+		var got []string
+		for range tt.want {
+			got = append(got, feedUser(f, vu, false))
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: fed %q, want %q", tt.onExhausted, got, tt.want)
+		}
+	}
+}
+
+func TestFeederRandomIsNeverExhausted(t *testing.T) {
+	f := newTestFeeder(t, 3, config.FeedRandom, config.FeedStop)
+	vu := newVirtualUser(1)
+	for i := 0; i < 50; i++ {
+		user := feedUser(f, vu, false)
+		if user != "user1" && user != "user2" && user != "user3" {
+			t.Fatalf("iteration %d fed %q", i+1, user)
+		}
+		if vu.vars["password"] != "secret"+strings.TrimPrefix(user, "user") {
+			t.Fatalf("row %s fed password %q", user, vu.vars["password"])
+		}
+	}
+}
+
+func TestFeederUniqueGivesEachVUItsOwnRow(t *testing.T) {
+	f := newTestFeeder(t, 10, config.FeedUnique, config.FeedStop)
+	var mu sync.Mutex
+	owner := make(map[string]int)
+	var wg sync.WaitGroup
+	for id := 1; id <= 10; id++ {
+		wg.Add(1)
+		go func(vu *virtualUser) {
+			defer wg.Done()
+			first := feedUser(f, vu, false)
+			for i := 0; i < 5; i++ {
+				if user := feedUser(f, vu, false); user != first {
+					t.Errorf("VU %d was fed %q after %q", vu.id, user, first)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if other, taken := owner[first]; taken {
+				t.Errorf("VUs %d and %d were both fed %q", other, vu.id, first)
+			}
+			owner[first] = vu.id
+		}(newVirtualUser(id))
+	}
+	wg.Wait()
+
+	if user := feedUser(f, newVirtualUser(11), false); user != "" {
+		t.Errorf("an eleventh VU was fed %q from 10 rows", user)
+	}
+}
+
+func TestFeederWarmupDoesNotConsumeRows(t *testing.T) {
+	for _, strategy := range []string{config.FeedSequential, config.FeedUnique} {
+		f := newTestFeeder(t, 3, strategy, config.FeedStop)
+		warm := newVirtualUser(0)
+		for i := 0; i < 5; i++ {
+			if feedUser(f, warm, true) == "" {
+				t.Fatalf("%s: warmup iteration %d was not fed", strategy, i+1)
+			}
+		}
+		var got []string
+		for id := 1; id <= 3; id++ {
+			got = append(got, feedUser(f, newVirtualUser(id), false))
+		}
+		if want := "user1,user2,user3"; strings.Join(got, ",") != want {
+			t.Errorf("%s: after warmup fed %q, want %s", strategy, got, want)
+		}
+	}
+}
+
+func TestRunStopsWhenRowsRunOut(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-VU") == "0" {
+			return
+		}
+		mu.Lock()
+		seen[r.URL.Query().Get("user")]++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL + "/?user={{user}}")
+	cfg.N = 1000
+	cfg.Threads = 4
+	cfg.Feeders = []config.Feeder{{File: writeUsers(t, 20), Strategy: config.FeedSequential, OnExhausted: config.FeedStop}}
+
+	res, err := Run(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !strings.Contains(res.StopReason, "ran out of rows") {
+		t.Errorf("StopReason = %q, want the data file to have run out", res.StopReason)
+	}
+	if res.TotalRequests != 20 {
+		t.Errorf("TotalRequests = %d, want one per row", res.TotalRequests)
+	}
+	for i := 1; i <= 20; i++ {
+		if user := fmt.Sprintf("user%d", i); seen[user] != 1 {
+			t.Errorf("%s was sent %d times, want once", user, seen[user])
+		}
+	}
+}
@@ -1,157 +1,460 @@
 package loadtest
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
-	"math"
+	"net"
 	"net/http"
 	"regexp"
-	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"stormforce/internal/config"
 	"stormforce/internal/results"
+	"stormforce/internal/threshold"
 	"stormforce/pkg/httpclient"
 )
 
-func Run(cfg config.Config) (results.Results, error) {
-	startTime := time.Now()
+// Validate compiles the requests of cfg as Run does, so that bad templates
+// and variables no extractor or data file column sets are reported before a
+// run. Data files are read to learn their columns.
+func Validate(cfg config.Config) error {
+	feeders, err := loadFeeders(cfg.Feeders)
+	if err != nil {
+		return err
+	}
+	_, err = buildRequests(cfg, templateVariables(cfg, feeders))
+	return err
+}
 
-	res := results.Results{
-		ResponseTimes:     make([]float64, 0, cfg.N),
-		FailedStatusCodes: make([]int, 0),
-		MinTime:           math.MaxFloat64,
-		MaxTime:           0,
+// Run executes the load test described by cfg. Cancelling ctx stops new
+// requests from being started, aborts the ones in flight and returns the
+// results collected so far with Interrupted set. When live is not nil it is
+// kept up to date for live displays.
+func Run(ctx context.Context, cfg config.Config, live *results.Live) (results.Results, error) {
+	thresholds := cfg.AllThresholds()
+	res := results.New(threshold.Percentiles(cfg.Percentiles, thresholds), cfg.MaxSamples, cfg.TimelineBucket)
+	res.ExpectedInterval = cfg.ExpectedInterval.Seconds()
+
+	if len(cfg.Stages) > 0 {
+		if cfg.Rate > 0 {
+			return res, fmt.Errorf("load stages cannot be combined with an arrival rate")
+		}
+		cfg.Duration = stagesDuration(cfg.Stages)
+		res.StageBoundaries = stageBoundaries(cfg.Stages)
 	}
 
-	client := httpclient.NewClient(cfg.CurlMaxTime)
+	feeders, err := loadFeeders(cfg.Feeders)
+	if err != nil {
+		return res, err
+	}
+	for _, f := range feeders {
+		log.Printf("Data file %s: %d rows, %s, %s when exhausted", f.file, len(f.rows), f.strategy, f.onExhausted)
+	}
+	requests, err := buildRequests(cfg, templateVariables(cfg, feeders))
+	if err != nil {
+		return res, err
+	}
+	res.ScenarioSteps = len(cfg.Scenario)
+	var trafficMix *mix
+	if len(cfg.Requests) > 0 {
+		trafficMix = newMix(requests, cfg.Requests)
+	}
+	var names []string
+	for _, rd := range requests {
+		if rd.name != "" {
+			names = append(names, rd.name)
+		}
+		checks := make([]string, len(rd.checks))
+		for i, x := range rd.checks {
+			checks[i] = x.Name
+		}
+		res.DeclareChecks(rd.name, checks)
+	}
+	res.DeclareEndpoints(names)
+	for _, rd := range requests {
+		res.DeclareTags(rd.name, rd.tags)
+	}
+
+	var pattern *regexp.Regexp
+	if cfg.ResponsePattern != "" {
+		if pattern, err = regexp.Compile(cfg.ResponsePattern); err != nil {
+			return res, fmt.Errorf("invalid response pattern: %v", err)
+		}
+	}
 
-	log.Printf("Starting load test with %d requests and %d threads", cfg.N, cfg.Threads)
-	log.Printf("URL: %s", cfg.URL)
-	log.Printf("Method: %s", cfg.Method)
+	// submitCtx bounds how long new work is started; in-flight requests only
+	// observe ctx so they can finish when the duration runs out or the run is
+	// stopped early.
+	submitCtx, stopSubmitting := context.WithCancel(ctx)
+	defer stopSubmitting()
+	var stopOnce sync.Once
+	var stopReason string
+	stop := func(reason string) {
+		stopOnce.Do(func() {
+			log.Printf("Stopping the run: %s", reason)
+			stopReason = reason
+			stopSubmitting()
+		})
+	}
+
+	client := httpclient.NewClient(cfg.CurlMaxTime, cfg.Insecure)
+	var watch *monitor
+	if cfg.AbortOnFail {
+		watch = newMonitor(&res, thresholds, cfg.ThresholdWindow, cfg.ThresholdGrace)
+	}
+	collector := newCollector(&res, collectorBuffer(cfg), watch, stop, live)
+	r := &runner{client: client, cfg: cfg, requests: requests, mix: trafficMix, pattern: pattern, feeders: feeders, collector: collector, stop: stop, live: live}
+
+	concurrency := fmt.Sprintf("%d threads", cfg.Threads)
+	if len(cfg.Stages) > 0 {
+		concurrency = fmt.Sprintf("stages %s", config.FormatStages(cfg.Stages))
+	} else if cfg.Rate > 0 {
+		res.TargetRate = cfg.Rate
+		concurrency = fmt.Sprintf("%.2f requests/second (max %d in flight)", cfg.Rate, cfg.MaxInFlight)
+	}
+	if cfg.Duration > 0 {
+		res.PlannedDuration = cfg.Duration.Seconds()
+		log.Printf("Starting load test for %s at %s", cfg.Duration, concurrency)
+	} else {
+		res.PlannedRequests = cfg.N
+		log.Printf("Starting load test with %d requests at %s", cfg.N, concurrency)
+	}
+	if len(cfg.Scenario) > 0 {
+		log.Printf("Scenario: %d steps per iteration", len(cfg.Scenario))
+		for i, s := range cfg.Scenario {
+			log.Printf("Step %d %s: %s %s", i+1, s.Name, s.Method, s.URL)
+		}
+	} else if len(cfg.Requests) > 0 {
+		log.Printf("Traffic mix: %d requests", len(cfg.Requests))
+		for _, def := range cfg.Requests {
+			log.Printf("Request %s (weight %g): %s %s", def.Name, def.Weight, def.Method, def.URL)
+		}
+	} else {
+		log.Printf("URL: %s", cfg.URL)
+		log.Printf("Method: %s", cfg.Method)
+	}
 	log.Printf("Retry limit: %d", cfg.RetryLimit)
-	log.Printf("Threshold time: %.2f seconds", cfg.ThresholdTime)
-	log.Printf("Threshold success rate: %.2f%%", cfg.ThresholdSuccess)
+	for _, t := range thresholds {
+		log.Printf("Threshold: %s", t.Expr)
+	}
+	if watch != nil {
+		log.Printf("Aborting when a threshold fails over a %s window, after a %s grace period", cfg.ThresholdWindow, cfg.ThresholdGrace)
+	}
 
 	// Warmup necessity depends on loadtesting services; enable/disable in env?
 	fmt.Println("Starting warmup...")
-	for i := 0; i < cfg.Threads; i++ {
-		makeRequest(client, cfg, &res)
+	// Warmup runs as VU 0; workers and arrival slots are numbered from 1.
+	warmupVU := newVirtualUser(0)
+	for i := 0; i < cfg.Threads && ctx.Err() == nil; i++ {
+		r.iteration(ctx, warmupVU, true)
 	}
 
 	fmt.Println("> WARMUP COMPLETE, STARTING UP THE STORM")
 	fmt.Println("========================================")
 
-	pool := NewWorkerPool(cfg.Threads)
-	pool.Start()
+	// Throughput is measured from here so warmup does not dilute it.
+	startTime := time.Now()
+	collector.Begin(startTime)
+	if live != nil {
+		planned := cfg.N * max(len(cfg.Scenario), 1)
+		live.Begin(startTime, cfg.Duration.Seconds(), planned)
+	}
 
-	var wg sync.WaitGroup
-	for i := 0; i < cfg.N; i++ {
-		wg.Add(1)
-		pool.Submit(func() {
-			defer wg.Done()
-			makeRequest(client, cfg, &res)
-		})
+	var deadline time.Time
+	if cfg.Duration > 0 {
+		deadline = time.Now().Add(cfg.Duration)
+		var cancel context.CancelFunc
+		submitCtx, cancel = context.WithDeadline(submitCtx, deadline)
+		defer cancel()
+	}
+	done := func(submitted int) bool {
+		if submitCtx.Err() != nil {
+			return true
+		}
+		if cfg.Duration > 0 {
+			return !time.Now().Before(deadline)
+		}
+		return submitted >= cfg.N
 	}
+	vus := newVirtualUsers()
+	job := func(vu int) {
+		// Jobs still queued when the deadline passes or the run is
+		// interrupted or stopped are dropped.
+		if submitCtx.Err() != nil {
+			return
+		}
+		r.iteration(ctx, vus.get(vu), false)
+	}
+
+	if cfg.Rate > 0 {
+		executor := NewArrivalRateExecutor(cfg.Rate, cfg.MaxInFlight)
+		executor.Dispatch(submitCtx, done, job)
+		res.DroppedArrivals = executor.Dropped()
+		if res.DroppedArrivals > 0 {
+			log.Printf("Dropped %d arrivals because %d requests were already in flight", res.DroppedArrivals, cfg.MaxInFlight)
+		}
+	} else {
+		workers := cfg.Threads
+		if len(cfg.Stages) > 0 {
+			workers = 0
+		}
+		pool := NewWorkerPool(workers)
+		pool.Start()
 
-	wg.Wait()
-	pool.Stop()
+		var timeline chan []results.VUPoint
+		stopRamp := make(chan struct{})
+		if len(cfg.Stages) > 0 {
+			timeline = make(chan []results.VUPoint, 1)
+			go func() {
+				timeline <- rampWorkers(pool, cfg.Stages, time.Now(), stopRamp)
+			}()
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; !done(i); i++ {
+			wg.Add(1)
+			task := func(worker int) {
+				defer wg.Done()
+				job(worker)
+			}
+			if !pool.Submit(submitCtx, task) {
+				wg.Done()
+				break
+			}
+		}
 
-	// Calculate statistics
-	sort.Float64s(res.ResponseTimes)
-	res.MedianTime = res.ResponseTimes[len(res.ResponseTimes)/2]
-	res.PercentileTime90 = res.ResponseTimes[int(float64(len(res.ResponseTimes))*0.9)]
-	res.AverageTime = calculateAverage(res.ResponseTimes)
+		wg.Wait()
+		close(stopRamp)
+		pool.Stop()
+		if timeline != nil {
+			res.VUTimeline = <-timeline
+		}
+	}
+
+	collector.Close()
+	res.StopReason = stopReason
+
+	if ctx.Err() != nil {
+		res.Interrupted = true
+		log.Printf("Load test interrupted, reporting partial results")
+	}
+
+	res.Summarize()
 	res.TotalDuration = time.Since(startTime).Seconds()
+	if res.TotalDuration > 0 {
+		res.Throughput = float64(res.TotalRequests) / res.TotalDuration
+		for i := range res.Endpoints {
+			res.Endpoints[i].Throughput = float64(res.Endpoints[i].TotalRequests) / res.TotalDuration
+		}
+		for i := range res.Tags {
+			res.Tags[i].Throughput = float64(res.Tags[i].TotalRequests) / res.TotalDuration
+		}
+	}
+	threshold.Evaluate(thresholds, &res)
 
 	return res, nil
 }
 
-func makeRequest(client *http.Client, cfg config.Config, res *results.Results) {
-	var req *http.Request
-	var err error
+// collectorBuffer sizes the sample channel so that workers rarely wait on
+// the collector goroutine.
+func collectorBuffer(cfg config.Config) int {
+	if cfg.Rate > 0 {
+		return cfg.MaxInFlight * 4
+	}
+	return cfg.Threads * 4
+}
+
+// runner sends the requests of each iteration: every scenario step in
+// order, one request picked from the traffic mix, or the single target URL.
+type runner struct {
+	client    *http.Client
+	cfg       config.Config
+	requests  []*request
+	mix       *mix
+	pattern   *regexp.Regexp // RESPONSE_PATTERN, nil when not set
+	feeders   []*feeder
+	collector *collector
+	stop      func(reason string)
+	live      *results.Live // nil without a live display
+	inFlight  atomic.Int64
+}
+
+// record stamps sample with the number of requests in flight and hands it to
+// the collector.
+func (r *runner) record(sample results.Sample) {
+	sample.InFlight = int(r.inFlight.Load())
+	r.collector.Record(sample)
+}
+
+// iteration sends the requests of one iteration for vu. A failed scenario
+// step ends the iteration since later steps usually depend on what it
+// returned.
+func (r *runner) iteration(ctx context.Context, vu *virtualUser, warmup bool) {
+	vu.iteration++
+	for _, f := range r.feeders {
+		if !f.feed(vu, warmup) {
+			r.stop(fmt.Sprintf("data file %s ran out of rows", f.file))
+			return
+		}
+	}
+	requests := r.requests
+	if r.mix != nil {
+		requests = []*request{r.mix.pick()}
+	}
+	for _, rd := range requests {
+		if rd.thinkTime > 0 && !warmup && !sleep(ctx, rd.thinkTime) {
+			return
+		}
+		if !r.makeRequest(ctx, rd, vu, warmup) || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// makeRequest sends one request, retrying network errors up to
+// cfg.RetryLimit attempts, and records a sample per attempt. Warmup samples
+// are kept out of the reported statistics. Every check runs on each
+// response; on success the request's extractors store their values in vu.
+func (r *runner) makeRequest(ctx context.Context, rd *request, vu *virtualUser, warmup bool) bool {
+	cfg := r.cfg
+	attempts := max(cfg.RetryLimit, 1)
+	r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	if r.live != nil {
+		r.live.AddInFlight(1)
+		defer r.live.AddInFlight(-1)
+	}
 
-	for attempt := 0; attempt < cfg.RetryLimit; attempt++ {
-		start := time.Now()
+	url := rd.url.render(vu)
+	var requestBody string
+	if rd.body != nil {
+		requestBody = rd.body.render(vu)
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		sample := results.Sample{Name: rd.name, Start: time.Now(), Attempt: attempt, Warmup: warmup, Matched: true}
 
-		if cfg.Method == "POST" && cfg.RequestBody != "" {
-			req, err = http.NewRequest(cfg.Method, cfg.URL, strings.NewReader(cfg.RequestBody))
-		} else {
-			req, err = http.NewRequest(cfg.Method, cfg.URL, nil)
+		var reqBody io.Reader
+		if requestBody != "" {
+			reqBody = strings.NewReader(requestBody)
+			sample.BytesSent = int64(len(requestBody))
 		}
 
+		tracer := &phaseTracer{}
+		req, err := http.NewRequestWithContext(tracer.withContext(ctx), rd.method, url, reqBody)
 		if err != nil {
 			log.Printf("Error creating request: %v\n", err)
+			sample.ErrorClass = results.ErrorRequest
+			sample.Error = err.Error()
+			sample.Retried = attempt < attempts
+			r.record(sample)
 			continue
 		}
 
-		for key, value := range cfg.Headers {
-			req.Header.Set(key, value)
+		for _, h := range rd.headers {
+			req.Header.Set(h.key, h.value.render(vu))
 		}
 
-		if cfg.BearerToken != "" {
-			req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
-		}
-
-		resp, err := client.Do(req)
-		duration := time.Since(start).Seconds()
+		resp, err := r.client.Do(req)
+		sample.Duration = time.Since(sample.Start).Seconds()
 
 		if err != nil {
-			log.Printf("Error: %v (Attempt %d/%d)\n", err, attempt+1, cfg.RetryLimit)
+			if errors.Is(err, context.Canceled) {
+				// Aborted by an interrupt; the request never completed so it is not recorded.
+				return false
+			}
+			log.Printf("Error: %v (Attempt %d/%d)\n", err, attempt, attempts)
+			sample.ErrorClass = classifyError(err)
+			sample.Error = err.Error()
+			sample.Retried = attempt < attempts
+			r.record(sample)
 			continue
 		}
 
-		body, _ := ioutil.ReadAll(resp.Body)
+		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		sample.Phases = tracer.phases(time.Now())
+		sample.StatusCode = resp.StatusCode
+		sample.BytesReceived = int64(len(body))
 
-		if resp.StatusCode >= 400 {
-			res.FailedStatusCodes = append(res.FailedStatusCodes, resp.StatusCode)
-			log.Printf("Failed request: Status %d, Time: %.2fs (Attempt %d/%d)\n", resp.StatusCode, duration, attempt+1, cfg.RetryLimit)
-			res.ResponseTimes = append(res.ResponseTimes, -1) // Indicate a failed request
-			return
-		} else {
-			log.Printf("Successful request: Status %d, Time: %.2fs\n", resp.StatusCode, duration)
-
-			if cfg.ResponsePattern != "" {
-				matched, _ := regexp.Match(cfg.ResponsePattern, body)
-				if !matched {
-					log.Printf("Response doesn't match the expected pattern\n")
-					res.FailedStatusCodes = append(res.FailedStatusCodes, resp.StatusCode)
-					res.ResponseTimes = append(res.ResponseTimes, -1) // Indicate a failed request
-					return
+		if !rd.statusChecked && resp.StatusCode >= 400 {
+			log.Printf("Failed request: Status %d, Time: %.2fs (Attempt %d/%d)\n", resp.StatusCode, sample.Duration, attempt, attempts)
+			sample.ErrorClass = results.ErrorStatus
+			sample.Error = resp.Status
+		}
+
+		if len(rd.checks) > 0 {
+			checked := &response{status: resp.StatusCode, header: resp.Header, body: body, duration: sample.Duration}
+			sample.Checks = make([]results.CheckOutcome, len(rd.checks))
+			for i, x := range rd.checks {
+				err := x.run(checked)
+				sample.Checks[i] = results.CheckOutcome{Name: x.Name, Passed: err == nil}
+				if err != nil && sample.Success() {
+					log.Printf("Check %s failed: %v\n", x.Name, err)
+					sample.ErrorClass = x.errorClass()
+					sample.Error = fmt.Sprintf("check %s: %v", x.Name, err)
 				}
 			}
 		}
+		if !sample.Success() {
+			r.record(sample)
+			return false
+		}
+
+		log.Printf("Successful request: Status %d, Time: %.2fs\n", resp.StatusCode, sample.Duration)
 
-		res.ResponseTimes = append(res.ResponseTimes, duration)
-		if duration < res.MinTime {
-			res.MinTime = duration
+		if r.pattern != nil && !r.pattern.Match(body) {
+			log.Printf("Response doesn't match the expected pattern\n")
+			sample.Matched = false
+			sample.ErrorClass = results.ErrorPattern
+			sample.Error = "response doesn't match the expected pattern"
+			r.record(sample)
+			return false
 		}
-		if duration > res.MaxTime {
-			res.MaxTime = duration
+
+		for _, x := range rd.extract {
+			value, err := x.extract(resp, body)
+			if err != nil {
+				log.Printf("Extracting %s failed: %v\n", x.variable, err)
+				sample.ErrorClass = results.ErrorExtract
+				sample.Error = err.Error()
+				r.record(sample)
+				return false
+			}
+			vu.vars[x.variable] = value
 		}
-		res.TotalRequests++
-		res.SuccessfulRequests++
-		return
+
+		r.record(sample)
+		return true
 	}
 
-	log.Printf("Request failed after %d attempts\n", cfg.RetryLimit)
-	res.ResponseTimes = append(res.ResponseTimes, -1) // Indicate a failed request after all retries
-	res.FailedRequests++
+	log.Printf("Request failed after %d attempts\n", attempts)
+	return false
 }
 
-func calculateAverage(values []float64) float64 {
-	if len(values) == 0 {
-		return 0
+// sleep waits for d and reports false if ctx was done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
-	sum := 0.0
-	for _, v := range values {
-		if v >= 0 {
-			sum += v
-		}
+}
+
+// classifyError maps a transport error to a Sample error class.
+func classifyError(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return results.ErrorTimeout
 	}
-	return sum / float64(len(values))
+	return results.ErrorNetwork
 }
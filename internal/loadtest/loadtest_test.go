@@ -0,0 +1,106 @@
+package loadtest
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"stormforce/internal/config"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// countingServer answers every third measured request with a 500 when
+// failing is set. Warmup requests, sent as VU 0, are not counted.
+type countingServer struct {
+	*httptest.Server
+	ok     atomic.Int64
+	failed atomic.Int64
+}
+
+func newCountingServer(t *testing.T, failing bool) *countingServer {
+	s := &countingServer{}
+	var seen atomic.Int64
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-VU") == "0" {
+			return
+		}
+		if failing && seen.Add(1)%3 == 0 {
+			s.failed.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.ok.Add(1)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testConfig(url string) config.Config {
+	return config.Config{
+		N:           500,
+		Threads:     32,
+		URL:         url,
+		Method:      "GET",
+		Headers:     map[string]string{"X-VU": "{{vu_id}}"},
+		RetryLimit:  1,
+		CurlMaxTime: 5,
+	}
+}
+
+func TestRunCountsEveryRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		failing  bool
+	}{
+		{name: "requests", failing: false},
+		{name: "requests with failures", failing: true},
+		{name: "duration", duration: 300 * time.Millisecond, failing: false},
+		{name: "duration with failures", duration: 300 * time.Millisecond, failing: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newCountingServer(t, tt.failing)
+			cfg := testConfig(server.URL)
+			cfg.Duration = tt.duration
+
+			res, err := Run(context.Background(), cfg, nil)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			ok, failed := int(server.ok.Load()), int(server.failed.Load())
+			if res.TotalRequests != ok+failed {
+				t.Errorf("TotalRequests = %d, server saw %d", res.TotalRequests, ok+failed)
+			}
+			if res.SuccessfulRequests != ok {
+				t.Errorf("SuccessfulRequests = %d, server answered %d with 200", res.SuccessfulRequests, ok)
+			}
+			if res.FailedRequests != failed {
+				t.Errorf("FailedRequests = %d, server answered %d with 500", res.FailedRequests, failed)
+			}
+			if tt.failing && failed == 0 {
+				t.Errorf("server answered no request with 500")
+			}
+			if tt.duration == 0 && res.TotalRequests != cfg.N {
+				t.Errorf("TotalRequests = %d, want %d", res.TotalRequests, cfg.N)
+			}
+			if res.WarmupRequests != cfg.Threads {
+				t.Errorf("WarmupRequests = %d, want %d", res.WarmupRequests, cfg.Threads)
+			}
+			if len(res.Samples) != res.TotalAttempts {
+				t.Errorf("kept %d samples of %d attempts", len(res.Samples), res.TotalAttempts)
+			}
+		})
+	}
+}
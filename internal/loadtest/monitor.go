@@ -0,0 +1,51 @@
+package loadtest
+
+import (
+	"fmt"
+	"time"
+
+	"stormforce/internal/results"
+	"stormforce/internal/threshold"
+)
+
+// monitor watches thresholds over a sliding window of the run, so a run
+// against a target that fell over can be stopped instead of running to the
+// end. It is only used by the collector goroutine.
+type monitor struct {
+	window     *results.Window
+	thresholds []threshold.Threshold
+	grace      time.Duration
+}
+
+// newMonitor returns nil when there is nothing to watch. Thresholds on
+// request counts only apply to the whole run and are left out.
+func newMonitor(res *results.Results, thresholds []threshold.Threshold, window, grace time.Duration) *monitor {
+	m := &monitor{window: res.NewWindow(window), grace: grace}
+	for _, t := range thresholds {
+		if t.Windowed() {
+			m.thresholds = append(m.thresholds, t)
+		}
+	}
+	if len(m.thresholds) == 0 {
+		return nil
+	}
+	return m
+}
+
+// check returns why the run should be aborted at now, or "" to go on. A
+// threshold that cannot be measured over the window, such as a latency
+// without successful requests, does not abort the run.
+func (m *monitor) check(now time.Time) string {
+	start := m.window.Started()
+	if start.IsZero() || now.Sub(start) < m.grace {
+		return ""
+	}
+	snapshot := m.window.Summarize(now)
+	threshold.Evaluate(m.thresholds, &snapshot)
+	for _, t := range snapshot.Thresholds {
+		if !t.Passed && t.Error == "" {
+			return fmt.Sprintf("threshold %s failed over the last %s (%s)", t.Threshold, m.window.Length, t.Actual)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"stormforce/internal/results"
+	"stormforce/internal/threshold"
+)
+
+func parseThresholds(t *testing.T, list string) []threshold.Threshold {
+	t.Helper()
+	thresholds, err := threshold.ParseList(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return thresholds
+}
+
+// feedMonitor adds a request ending every 100ms from start until end.
+func feedMonitor(m *monitor, start, end time.Time, status int) {
+	for at := start; at.Before(end); at = at.Add(100 * time.Millisecond) {
+		s := results.Sample{Start: at.Add(-50 * time.Millisecond), Duration: 0.05, Attempt: 1, StatusCode: status, Matched: status < 400}
+		if status >= 400 {
+			s.ErrorClass, s.Error = results.ErrorStatus, "status"
+		}
+		m.window.Add(s)
+	}
+}
+
+func TestMonitorAbortsAfterGracePeriod(t *testing.T) {
+	res := results.New(nil, 0, 0)
+	m := newMonitor(&res, parseThresholds(t, "error_rate < 10%, requests > 1000000"), 10*time.Second, 5*time.Second)
+	if m == nil {
+		t.Fatal("no monitor for a windowed threshold")
+	}
+	if len(m.thresholds) != 1 {
+		t.Errorf("watching %d thresholds, want the error rate only", len(m.thresholds))
+	}
+
+	start := time.Unix(1_700_000_000, 0)
+	if reason := m.check(start.Add(time.Minute)); reason != "" {
+		t.Errorf("aborted before any response: %s", reason)
+	}
+	feedMonitor(m, start, start.Add(10*time.Second), 500)
+
+	for _, at := range []time.Duration{0, time.Second, 4999 * time.Millisecond} {
+		if reason := m.check(start.Add(at)); reason != "" {
+			t.Errorf("aborted %s into the grace period: %s", at, reason)
+		}
+	}
+	reason := m.check(start.Add(5 * time.Second))
+	if want := "threshold error_rate < 10% failed over the last 10s (100.00%)"; reason != want {
+		t.Errorf("after the grace period: %q, want %q", reason, want)
+	}
+}
+
+func TestMonitorJudgesTheWindowOnly(t *testing.T) {
+	res := results.New(nil, 0, 0)
+	m := newMonitor(&res, parseThresholds(t, "error_rate < 10%"), 10*time.Second, time.Second)
+	start := time.Unix(1_700_000_000, 0)
+
+	// Failures early in the run leave the window once the target recovers.
+	feedMonitor(m, start, start.Add(5*time.Second), 500)
+	feedMonitor(m, start.Add(5*time.Second), start.Add(30*time.Second), 200)
+	if reason := m.check(start.Add(30 * time.Second)); reason != "" {
+		t.Errorf("aborted on failures outside the window: %s", reason)
+	}
+}
+
+func TestMonitorWithoutWindowedThresholds(t *testing.T) {
+	res := results.New(nil, 0, 0)
+	if m := newMonitor(&res, parseThresholds(t, "requests > 10, failed_requests < 5"), 10*time.Second, 0); m != nil {
+		t.Errorf("a monitor watches %d count thresholds", len(m.thresholds))
+	}
+}
@@ -0,0 +1,209 @@
+package loadtest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"stormforce/internal/config"
+)
+
+// request is a configured request with its templates compiled.
+type request struct {
+	name      string
+	method    string
+	thinkTime time.Duration
+	tags      []string
+	url       *template
+	headers   []header
+	body      *template // nil when no body is sent
+	extract   []*extractor
+	checks    []*check
+	// statusChecked is set when a status check replaces the default rule
+	// that status codes of 400 and up are failures.
+	statusChecked bool
+}
+
+type header struct {
+	key   string
+	value *template
+}
+
+// buildRequests compiles the configured scenario steps or traffic mix, whose
+// templates may use the variables in vars. Without either the run is a
+// single unnamed request built from the target URL, so its samples are not
+// broken down per endpoint.
+func buildRequests(cfg config.Config, vars map[string]bool) ([]*request, error) {
+	defs := cfg.Scenario
+	if len(cfg.Requests) > 0 {
+		defs = cfg.Requests
+	}
+	if len(defs) == 0 {
+		single := config.Request{Method: cfg.Method, URL: cfg.URL}
+		if cfg.Method == "POST" {
+			single.Body = cfg.RequestBody
+		}
+		defs = []config.Request{single}
+	}
+
+	requests := make([]*request, 0, len(defs))
+	for _, def := range defs {
+		rd, err := compileRequest(def, cfg, vars)
+		if err != nil && def.Name != "" {
+			return nil, fmt.Errorf("request %s: %v", def.Name, err)
+		} else if err != nil {
+			return nil, err
+		}
+		requests = append(requests, rd)
+	}
+	return requests, nil
+}
+
+func compileRequest(def config.Request, cfg config.Config, vars map[string]bool) (*request, error) {
+	rd := &request{name: def.Name, method: def.Method, thinkTime: def.ThinkTime, tags: def.Tags}
+	if rd.method == "" {
+		rd.method = "GET"
+	}
+
+	var err error
+	if rd.url, err = compileTemplate(def.URL, vars); err != nil {
+		return nil, err
+	}
+	if def.Body != "" {
+		if rd.body, err = compileTemplate(def.Body, vars); err != nil {
+			return nil, err
+		}
+	}
+
+	// Request headers are set last so they can override the global headers
+	// and bearer token, e.g. with a token extracted by an earlier step.
+	add := func(key, value string) error {
+		t, err := compileTemplate(value, vars)
+		if err != nil {
+			return fmt.Errorf("header %s: %v", key, err)
+		}
+		rd.headers = append(rd.headers, header{key: key, value: t})
+		return nil
+	}
+	for key, value := range cfg.Headers {
+		if err := add(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.BearerToken != "" {
+		if err := add("Authorization", "Bearer "+cfg.BearerToken); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range def.Headers {
+		if err := add(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// The global checks run first. A response schema is a schema check.
+	checks := append(append([]config.Check{}, cfg.Checks...), def.Checks...)
+	if def.ResponseSchema != nil {
+		checks = append(checks, config.Check{Name: "response schema", Kind: config.CheckSchema, Schema: def.ResponseSchema})
+	}
+	names := make(map[string]bool)
+	for _, c := range checks {
+		if names[c.Name] {
+			return nil, fmt.Errorf("duplicate check name %q", c.Name)
+		}
+		names[c.Name] = true
+		x, err := newCheck(c)
+		if err != nil {
+			return nil, err
+		}
+		rd.checks = append(rd.checks, x)
+		rd.statusChecked = rd.statusChecked || c.Kind == config.CheckStatus
+	}
+
+	for _, e := range def.Extract {
+		x, err := newExtractor(e)
+		if err != nil {
+			return nil, err
+		}
+		rd.extract = append(rd.extract, x)
+	}
+	return rd, nil
+}
+
+// templateVariables returns the variables templates may use: those set by
+// the extractors of every request and the columns of the data files.
+func templateVariables(cfg config.Config, feeders []*feeder) map[string]bool {
+	vars := make(map[string]bool)
+	for _, defs := range [][]config.Request{cfg.Scenario, cfg.Requests} {
+		for _, def := range defs {
+			for _, e := range def.Extract {
+				vars[e.Var] = true
+			}
+		}
+	}
+	for _, f := range feeders {
+		for _, column := range f.columns {
+			vars[column] = true
+		}
+	}
+	return vars
+}
+
+// mix picks requests at random in proportion to their weights.
+type mix struct {
+	requests   []*request
+	cumulative []float64
+}
+
+func newMix(requests []*request, defs []config.Request) *mix {
+	m := &mix{requests: requests, cumulative: make([]float64, len(defs))}
+	total := 0.0
+	for i, def := range defs {
+		total += def.Weight
+		m.cumulative[i] = total
+	}
+	return m
+}
+
+func (m *mix) pick() *request {
+	x := rand.Float64() * m.cumulative[len(m.cumulative)-1]
+	i := sort.Search(len(m.cumulative), func(i int) bool { return m.cumulative[i] > x })
+	return m.requests[min(i, len(m.requests)-1)]
+}
+
+// virtualUser is the state a VU carries from one step and iteration to the
+// next. It is only used by the goroutine currently running that VU.
+type virtualUser struct {
+	id        int
+	iteration int
+	vars      map[string]string
+	rows      map[*feeder]map[string]string // rows of unique feeders
+}
+
+func newVirtualUser(id int) *virtualUser {
+	return &virtualUser{id: id, vars: make(map[string]string), rows: make(map[*feeder]map[string]string)}
+}
+
+// virtualUsers hands out the state of each VU by id, creating it on first
+// use.
+type virtualUsers struct {
+	mu  sync.Mutex
+	vus map[int]*virtualUser
+}
+
+func newVirtualUsers() *virtualUsers {
+	return &virtualUsers{vus: make(map[int]*virtualUser)}
+}
+
+func (v *virtualUsers) get(id int) *virtualUser {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	vu, ok := v.vus[id]
+	if !ok {
+		vu = newVirtualUser(id)
+		v.vus[id] = vu
+	}
+	return vu
+}
@@ -0,0 +1,72 @@
+package loadtest
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"stormforce/internal/config"
+	"stormforce/internal/results"
+)
+
+// rampInterval is how often the worker count is adjusted during a stage.
+const rampInterval = 100 * time.Millisecond
+
+func stagesDuration(stages []config.Stage) time.Duration {
+	var total time.Duration
+	for _, stage := range stages {
+		total += stage.Duration
+	}
+	return total
+}
+
+// stageBoundaries returns the elapsed seconds at which each stage ends.
+func stageBoundaries(stages []config.Stage) []float64 {
+	boundaries := make([]float64, len(stages))
+	var elapsed time.Duration
+	for i, stage := range stages {
+		elapsed += stage.Duration
+		boundaries[i] = elapsed.Seconds()
+	}
+	return boundaries
+}
+
+// stageTarget returns the number of workers the profile asks for after
+// elapsed, interpolating linearly from the previous stage's target.
+func stageTarget(stages []config.Stage, elapsed time.Duration) int {
+	from := 0
+	for _, stage := range stages {
+		if elapsed < stage.Duration {
+			progress := float64(elapsed) / float64(stage.Duration)
+			return int(math.Round(float64(from) + float64(stage.Target-from)*progress))
+		}
+		elapsed -= stage.Duration
+		from = stage.Target
+	}
+	return from
+}
+
+// rampWorkers scales pool to follow stages until stop is closed and returns
+// every change in worker count.
+func rampWorkers(pool *WorkerPool, stages []config.Stage, start time.Time, stop <-chan struct{}) []results.VUPoint {
+	ticker := time.NewTicker(rampInterval)
+	defer ticker.Stop()
+
+	var timeline []results.VUPoint
+	current := -1
+	for {
+		elapsed := time.Since(start)
+		if target := stageTarget(stages, elapsed); target != current {
+			pool.Scale(target)
+			current = target
+			timeline = append(timeline, results.VUPoint{Elapsed: elapsed.Seconds(), VUs: target})
+			log.Printf("Scaled to %d threads", target)
+		}
+
+		select {
+		case <-stop:
+			return append(timeline, results.VUPoint{Elapsed: time.Since(start).Seconds(), VUs: current})
+		case <-ticker.C:
+		}
+	}
+}
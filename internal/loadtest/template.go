@@ -0,0 +1,241 @@
+package loadtest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// template is a string with {{...}} placeholders. It is parsed once when the
+// run starts into literal text and value functions, so rendering it per
+// request only calls those functions and concatenates.
+//
+// A placeholder is either a variable name, set by an extractor or a data
+// feeder, a quoted string that is written as is, such as {{"{{"}} for a
+// literal {{, or one of these functions:
+//
+//	{{uuid}}            random version 4 UUID
+//	{{randInt 1 1000}}  random integer between the bounds, inclusive
+//	{{now}}             current time as RFC 3339; {{now "unix"}},
+//	                    {{now "unixms"}}, {{now "date"}} (2006-01-02) or a
+//	                    Go time layout also work
+//	{{vu_id}}           id of the virtual user sending the request
+//	{{iteration}}       iteration number of that virtual user, from 1
+//	{{env "X"}}         environment variable X, read once at start; it
+//	                    must be set
+type template struct {
+	parts []templatePart
+}
+
+type templatePart struct {
+	text  string
+	value func(vu *virtualUser) string // set for a placeholder
+}
+
+// compileTemplate parses input. Variables must be among vars, the names the
+// extractors and data feeders of the run set.
+func compileTemplate(input string, vars map[string]bool) (*template, error) {
+	t := &template{}
+	rest := input
+	for rest != "" {
+		open := strings.Index(rest, "{{")
+		if open < 0 {
+			t.parts = append(t.parts, templatePart{text: rest})
+			break
+		}
+		if open > 0 {
+			t.parts = append(t.parts, templatePart{text: rest[:open]})
+		}
+		end := strings.Index(rest[open:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated placeholder in %q", input)
+		}
+		part, err := compilePlaceholder(rest[open+2:open+end], vars)
+		if err != nil {
+			return nil, fmt.Errorf("placeholder %s in %q: %v", rest[open:open+end+2], input, err)
+		}
+		t.parts = append(t.parts, part)
+		rest = rest[open+end+2:]
+	}
+	return t, nil
+}
+
+func compilePlaceholder(expr string, vars map[string]bool) (templatePart, error) {
+	args, err := splitArgs(expr)
+	if err != nil {
+		return templatePart{}, err
+	}
+	if len(args) == 0 {
+		return templatePart{}, fmt.Errorf("empty placeholder")
+	}
+	if strings.HasPrefix(strings.TrimSpace(expr), `"`) {
+		if len(args) != 1 {
+			return templatePart{}, fmt.Errorf("a quoted string takes no arguments")
+		}
+		return templatePart{text: args[0]}, nil
+	}
+	name, args := args[0], args[1:]
+	arity := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s takes %d arguments, got %d", name, n, len(args))
+		}
+		return nil
+	}
+
+	switch name {
+	case "uuid":
+		if err := arity(0); err != nil {
+			return templatePart{}, err
+		}
+		return templatePart{value: func(*virtualUser) string { return newUUID() }}, nil
+
+	case "randInt":
+		if err := arity(2); err != nil {
+			return templatePart{}, err
+		}
+		low, errLow := strconv.Atoi(args[0])
+		high, errHigh := strconv.Atoi(args[1])
+		if errLow != nil || errHigh != nil || low > high {
+			return templatePart{}, fmt.Errorf("randInt needs two integers, low <= high")
+		}
+		// Subtracting unsigned cannot overflow, and rand.IntN needs the
+		// number of values to fit in an int.
+		if uint64(high)-uint64(low) >= math.MaxInt {
+			return templatePart{}, fmt.Errorf("randInt range %d to %d is too large", low, high)
+		}
+		span := high - low + 1
+		return templatePart{value: func(*virtualUser) string {
+			return strconv.Itoa(low + rand.IntN(span))
+		}}, nil
+
+	case "now":
+		if len(args) > 1 {
+			return templatePart{}, fmt.Errorf("now takes at most 1 argument, got %d", len(args))
+		}
+		format := time.RFC3339
+		if len(args) == 1 {
+			format = args[0]
+		}
+		return templatePart{value: func(*virtualUser) string {
+			now := time.Now()
+			switch format {
+			case "unix":
+				return strconv.FormatInt(now.Unix(), 10)
+			case "unixms":
+				return strconv.FormatInt(now.UnixMilli(), 10)
+			case "date":
+				return now.Format(time.DateOnly)
+			}
+			return now.Format(format)
+		}}, nil
+
+	case "vu_id":
+		if err := arity(0); err != nil {
+			return templatePart{}, err
+		}
+		return templatePart{value: func(vu *virtualUser) string { return strconv.Itoa(vu.id) }}, nil
+
+	case "iteration":
+		if err := arity(0); err != nil {
+			return templatePart{}, err
+		}
+		return templatePart{value: func(vu *virtualUser) string { return strconv.Itoa(vu.iteration) }}, nil
+
+	case "env":
+		if err := arity(1); err != nil {
+			return templatePart{}, err
+		}
+		value, ok := os.LookupEnv(args[0])
+		if !ok {
+			return templatePart{}, fmt.Errorf("environment variable %s is not set", args[0])
+		}
+		return templatePart{text: value}, nil
+	}
+
+	if len(args) > 0 {
+		return templatePart{}, fmt.Errorf("unknown function %q", name)
+	}
+	if !vars[name] {
+		return templatePart{}, fmt.Errorf("unknown variable %q: no extractor or data file column sets it", name)
+	}
+	return templatePart{value: func(vu *virtualUser) string { return vu.vars[name] }}, nil
+}
+
+// splitArgs splits a placeholder on whitespace. Arguments may be double
+// quoted to include spaces.
+func splitArgs(expr string) ([]string, error) {
+	var args []string
+	rest := strings.TrimSpace(expr)
+	for rest != "" {
+		if rest[0] == '"' {
+			end := 1
+			for end < len(rest) && (rest[end] != '"' || rest[end-1] == '\\') {
+				end++
+			}
+			if end == len(rest) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			arg, err := strconv.Unquote(rest[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", rest[:end+1])
+			}
+			args = append(args, arg)
+			rest = rest[end+1:]
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			args = append(args, rest[:end])
+			rest = rest[end:]
+		}
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	}
+	return args, nil
+}
+
+// render evaluates the template for a request sent by vu.
+func (t *template) render(vu *virtualUser) string {
+	if len(t.parts) == 1 && t.parts[0].value == nil {
+		return t.parts[0].text
+	}
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.value != nil {
+			b.WriteString(part.value(vu))
+		} else {
+			b.WriteString(part.text)
+		}
+	}
+	return b.String()
+}
+
+func newUUID() string {
+	var u [16]byte
+	for i := 0; i < 16; i += 8 {
+		n := rand.Uint64()
+		for j := 0; j < 8; j++ {
+			u[i+j] = byte(n >> (8 * j))
+		}
+	}
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+
+	var s [36]byte
+	hex.Encode(s[0:8], u[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], u[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], u[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], u[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], u[10:])
+	return string(s[:])
+}
@@ -0,0 +1,194 @@
+package loadtest
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"stormforce/internal/config"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+		err  string
+	}{
+		{expr: "uuid", want: []string{"uuid"}},
+		{expr: "  randInt   1 10  ", want: []string{"randInt", "1", "10"}},
+		{expr: `now "2006-01-02 15:04"`, want: []string{"now", "2006-01-02 15:04"}},
+		{expr: `env "A" "B C"`, want: []string{"env", "A", "B C"}},
+		{expr: `x "say \"hi\""`, want: []string{"x", `say "hi"`}},
+		{expr: `x ""`, want: []string{"x", ""}},
+		{expr: "", want: nil},
+		{expr: `now "2006`, err: "unterminated string"},
+		{expr: `now "\q"`, err: `invalid string "\q"`},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.expr)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("splitArgs(%q) error = %v, want %q", tt.expr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitArgs(%q): %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	t.Setenv("STORMFORCE_TEST_HOST", "api.example.com")
+	vu := newVirtualUser(7)
+	vu.iteration = 3
+	vu.vars["token"] = "abc"
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "plain text", want: "plain text"},
+		{input: "", want: ""},
+		{input: "Bearer {{token}}", want: "Bearer abc"},
+		{input: "{{ token }}", want: "abc"},
+		{input: `{{"{{"}}token}}`, want: "{{token}}"},
+		{input: `{{ "a b" }}`, want: "a b"},
+		{input: "vu {{vu_id}} iteration {{iteration}}", want: "vu 7 iteration 3"},
+		{input: `https://{{env "STORMFORCE_TEST_HOST"}}/x`, want: "https://api.example.com/x"},
+		{input: "{{randInt 5 5}}", want: "5"},
+		{input: "a {{token}}{{token}} b", want: "a abcabc b"},
+	}
+	for _, tt := range tests {
+		tmpl, err := compileTemplate(tt.input, map[string]bool{"token": true})
+		if err != nil {
+			t.Errorf("compileTemplate(%q): %v", tt.input, err)
+			continue
+		}
+		if got := tmpl.render(vu); got != tt.want {
+			t.Errorf("render(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateFunctions(t *testing.T) {
+	vu := newVirtualUser(1)
+	render := func(input string) string {
+		t.Helper()
+		tmpl, err := compileTemplate(input, nil)
+		if err != nil {
+			t.Fatalf("compileTemplate(%q): %v", input, err)
+		}
+		return tmpl.render(vu)
+	}
+
+	if id := render("{{uuid}}"); !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("uuid = %q, want a version 4 UUID", id)
+	}
+	if render("{{uuid}}") == render("{{uuid}}") {
+		t.Errorf("uuid repeats")
+	}
+	for i := 0; i < 100; i++ {
+		n, err := strconv.Atoi(render("{{randInt -2 2}}"))
+		if err != nil || n < -2 || n > 2 {
+			t.Fatalf("randInt -2 2 = %d, %v", n, err)
+		}
+	}
+	for _, bounds := range [][2]int{{math.MaxInt - 1, math.MaxInt}, {math.MinInt, math.MinInt + 1}, {math.MinInt / 2, math.MaxInt/2 - 1}} {
+		input := fmt.Sprintf("{{randInt %d %d}}", bounds[0], bounds[1])
+		if n, err := strconv.Atoi(render(input)); err != nil || n < bounds[0] || n > bounds[1] {
+			t.Errorf("%s = %d, %v", input, n, err)
+		}
+	}
+
+	before := time.Now().Add(-time.Second)
+	if _, err := time.Parse(time.RFC3339, render("{{now}}")); err != nil {
+		t.Errorf("now: %v", err)
+	}
+	if unix, _ := strconv.ParseInt(render(`{{now "unix"}}`), 10, 64); unix < before.Unix() {
+		t.Errorf("now unix = %d, before %d", unix, before.Unix())
+	}
+	if ms, _ := strconv.ParseInt(render(`{{now "unixms"}}`), 10, 64); ms < before.UnixMilli() {
+		t.Errorf("now unixms = %d, before %d", ms, before.UnixMilli())
+	}
+	if unix, _ := strconv.ParseInt(render("{{now unix}}"), 10, 64); unix < before.Unix() {
+		t.Errorf("now unix without quotes = %d, before %d", unix, before.Unix())
+	}
+	if got := render("{{now date}}"); got != time.Now().Format("2006-01-02") && got != before.Format("2006-01-02") {
+		t.Errorf("now date = %q, want today", got)
+	}
+	if got, want := render(`{{now "2006-01-02 15"}}`), time.Now().Format("2006-01-02 15"); got != want && got != before.Format("2006-01-02 15") {
+		t.Errorf("now with a layout = %q, want %q", got, want)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{input: "{{uuid 1}}", err: "uuid takes 0 arguments, got 1"},
+		{input: "{{vu_id x}}", err: "vu_id takes 0 arguments, got 1"},
+		{input: "{{iteration x}}", err: "iteration takes 0 arguments, got 1"},
+		{input: "{{randInt 1}}", err: "randInt takes 2 arguments, got 1"},
+		{input: "{{randInt a b}}", err: "randInt needs two integers, low <= high"},
+		{input: "{{randInt 5 1}}", err: "randInt needs two integers, low <= high"},
+		{input: fmt.Sprintf("{{randInt %d %d}}", math.MinInt, math.MaxInt), err: "too large"},
+		{input: fmt.Sprintf("{{randInt 0 %d}}", math.MaxInt), err: "too large"},
+		{input: fmt.Sprintf("{{randInt -1 %d}}", math.MaxInt-1), err: "too large"},
+		{input: "{{missing}}", err: `unknown variable "missing": no extractor or data file column sets it`},
+		{input: `{{env "STORMFORCE_TEST_UNSET"}}`, err: "environment variable STORMFORCE_TEST_UNSET is not set"},
+		{input: `{{"{{" "x"}}`, err: "a quoted string takes no arguments"},
+		{input: `{{now "unix" "x"}}`, err: "now takes at most 1 argument, got 2"},
+		{input: "{{env}}", err: "env takes 1 arguments, got 0"},
+		{input: "{{lower name}}", err: `unknown function "lower"`},
+		{input: "x {{ }} y", err: "empty placeholder"},
+		{input: "x {{token", err: `unterminated placeholder in "x {{token"`},
+		{input: `{{env "HOST}}`, err: "unterminated string"},
+	}
+	for _, tt := range tests {
+		_, err := compileTemplate(tt.input, nil)
+		if err == nil {
+			t.Errorf("compileTemplate(%q) succeeded, want %q", tt.input, tt.err)
+			continue
+		}
+		if !strings.HasSuffix(err.Error(), tt.err) {
+			t.Errorf("compileTemplate(%q) error = %q, want it to end in %q", tt.input, err, tt.err)
+		}
+	}
+}
+
+func TestValidateKnowsTemplateVariables(t *testing.T) {
+	login := config.Request{
+		Name:    "login",
+		URL:     "https://api.example.com/login?user={{user}}",
+		Extract: []config.Extractor{{Var: "token", Source: config.ExtractJSON, Expr: "token"}},
+	}
+	profile := func(header string) config.Config {
+		return config.Config{
+			Scenario: []config.Request{login, {Name: "profile", URL: "https://api.example.com/me", Headers: map[string]string{"Authorization": header}}},
+			Feeders:  []config.Feeder{{File: writeUsers(t, 2), Strategy: config.FeedSequential}},
+		}
+	}
+
+	if err := Validate(profile("Bearer {{token}} {{password}}")); err != nil {
+		t.Errorf("extracted variable and data column: %v", err)
+	}
+	err := Validate(profile("Bearer {{tokn}}"))
+	if err == nil || !strings.Contains(err.Error(), `request profile: header Authorization: placeholder {{tokn}}`) {
+		t.Errorf("misspelled variable: error = %v", err)
+	}
+	single := config.Config{URL: "https://api.example.com/?user={{user}}"}
+	if err := Validate(single); err == nil {
+		t.Errorf("data column without a data file: succeeded")
+	}
+}
@@ -0,0 +1,76 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"stormforce/internal/results"
+)
+
+// phaseTracer collects connection and response timings for one attempt via
+// net/http/httptrace. Callbacks can fire from the transport's dialer
+// goroutines, hence the mutex.
+type phaseTracer struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+}
+
+func (t *phaseTracer) withContext(ctx context.Context) context.Context {
+	set := func(field *time.Time) {
+		t.mu.Lock()
+		*field = time.Now()
+		t.mu.Unlock()
+	}
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { set(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { set(&t.dnsDone) },
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			// Keep the first dial when several addresses are raced.
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone:          func(string, string, error) { set(&t.connectDone) },
+		TLSHandshakeStart:    func() { set(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { set(&t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { set(&t.firstByte) },
+	})
+}
+
+// phases converts the recorded timestamps into durations. bodyRead is when
+// the response body was fully consumed.
+func (t *phaseTracer) phases(bodyRead time.Time) results.Phases {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return results.Phases{
+		DNS:      between(t.dnsStart, t.dnsDone),
+		Connect:  between(t.connectStart, t.connectDone),
+		TLS:      between(t.tlsStart, t.tlsDone),
+		TTFB:     between(t.wroteRequest, t.firstByte),
+		Transfer: between(t.firstByte, bodyRead),
+	}
+}
+
+// between returns the seconds from start to end, or 0 when either phase
+// boundary was not observed (for example on a reused connection).
+func between(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start).Seconds()
+}
@@ -1,36 +1,87 @@
 package loadtest
 
 import (
+	"context"
 	"sync"
 )
 
+// WorkerPool runs jobs on a resizable set of workers. Each job is passed the
+// id of the worker running it; ids are never reused, so a worker that is
+// still finishing its last job after a scale down never shares an id with a
+// new one.
 type WorkerPool struct {
 	workerCount int
-	jobs        chan func()
+	jobs        chan func(worker int)
 	wg          sync.WaitGroup
+	mu          sync.Mutex
+	quits       []chan struct{}
+	nextID      int
 }
 
 func NewWorkerPool(workerCount int) *WorkerPool {
 	return &WorkerPool{
 		workerCount: workerCount,
-		jobs:        make(chan func(), workerCount),
+		jobs:        make(chan func(worker int), workerCount),
 	}
 }
 
 func (wp *WorkerPool) Start() {
-	for i := 0; i < wp.workerCount; i++ {
+	wp.Scale(wp.workerCount)
+}
+
+// Scale grows or shrinks the pool to n workers. Removed workers finish the
+// job they are running before they exit.
+func (wp *WorkerPool) Scale(n int) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	for len(wp.quits) < n {
+		quit := make(chan struct{})
+		wp.quits = append(wp.quits, quit)
+		wp.nextID++
 		wp.wg.Add(1)
-		go func() {
-			defer wp.wg.Done()
-			for job := range wp.jobs {
-				job()
+		go wp.work(wp.nextID, quit)
+	}
+	for len(wp.quits) > n {
+		last := len(wp.quits) - 1
+		close(wp.quits[last])
+		wp.quits = wp.quits[:last]
+	}
+	wp.workerCount = n
+}
+
+// Workers returns the current number of workers.
+func (wp *WorkerPool) Workers() int {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	return wp.workerCount
+}
+
+func (wp *WorkerPool) work(id int, quit chan struct{}) {
+	defer wp.wg.Done()
+	for {
+		select {
+		case <-quit:
+			return
+		case job, ok := <-wp.jobs:
+			if !ok {
+				return
 			}
-		}()
+			job(id)
+		}
 	}
 }
 
-func (wp *WorkerPool) Submit(job func()) {
-	wp.jobs <- job
+// Submit queues job and reports whether it was accepted. It gives up once ctx
+// is done, which matters when the pool has been scaled down to zero workers
+// or the run was interrupted.
+func (wp *WorkerPool) Submit(ctx context.Context, job func(worker int)) bool {
+	select {
+	case wp.jobs <- job:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 func (wp *WorkerPool) Stop() {
@@ -0,0 +1,53 @@
+package results
+
+// CheckOutcome is the result of one named check on a response.
+type CheckOutcome struct {
+	Name   string
+	Passed bool
+}
+
+// CheckStats counts how often a check passed and failed on the responses
+// to one endpoint. Endpoint is empty when a single URL is tested.
+type CheckStats struct {
+	Endpoint string
+	Name     string
+	Passed   int
+	Failed   int
+}
+
+type checkKey struct {
+	endpoint string
+	name     string
+}
+
+// DeclareChecks reports the named checks of an endpoint in the given order,
+// even those that never run.
+func (r *Results) DeclareChecks(endpoint string, names []string) {
+	for _, name := range names {
+		r.check(endpoint, name)
+	}
+}
+
+// check returns the counters of the named check of endpoint.
+func (r *Results) check(endpoint, name string) *CheckStats {
+	if r.checks == nil {
+		r.checks = make(map[checkKey]*CheckStats)
+	}
+	key := checkKey{endpoint: endpoint, name: name}
+	c, ok := r.checks[key]
+	if !ok {
+		c = &CheckStats{Endpoint: endpoint, Name: name}
+		r.checks[key] = c
+		r.checkOrder = append(r.checkOrder, key)
+	}
+	return c
+}
+
+// PassRate returns the percentage of evaluations of the check that passed.
+func (s CheckStats) PassRate() float64 {
+	total := s.Passed + s.Failed
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Passed) / float64(total) * 100
+}
@@ -0,0 +1,118 @@
+package results
+
+// EndpointStats summarizes the requests of one named endpoint: a scenario
+// step or an entry of the traffic mix. The same statistics are kept for all
+// endpoints with a tag, named after the tag. Latency covers successful
+// requests only and Share is the percentage of all requests sent to the
+// endpoint.
+type EndpointStats struct {
+	Name               string
+	Tags               []string `json:",omitempty"`
+	TotalRequests      int
+	Share              float64
+	SuccessfulRequests int
+	FailedRequests     int
+	FailuresByClass    map[string]int
+	Latency            LatencyStats
+	Percentiles        []Percentile
+	Throughput         float64
+}
+
+// endpoint accumulates EndpointStats while the run is in progress.
+type endpoint struct {
+	stats   EndpointStats
+	latency *Histogram
+}
+
+// DeclareEndpoints reports the named endpoints in the given order, even
+// those that end up without requests. Other names are added in the order
+// they are first seen.
+func (r *Results) DeclareEndpoints(names []string) {
+	for _, name := range names {
+		r.endpoint(name)
+	}
+}
+
+// DeclareTags tags the named endpoint. Tagged samples are also summarized per
+// tag, in the order the tags are declared.
+func (r *Results) DeclareTags(name string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if r.endpointTags == nil {
+		r.endpointTags = make(map[string][]string)
+		r.tags = make(map[string]*endpoint)
+	}
+	r.endpointTags[name] = tags
+	r.endpoint(name).stats.Tags = tags
+	for _, tag := range tags {
+		if _, ok := r.tags[tag]; !ok {
+			r.tags[tag] = &endpoint{stats: EndpointStats{Name: tag}, latency: NewHistogram()}
+			r.tagOrder = append(r.tagOrder, tag)
+		}
+	}
+}
+
+// endpoint returns the accumulator for name.
+func (r *Results) endpoint(name string) *endpoint {
+	if r.endpoints == nil {
+		r.endpoints = make(map[string]*endpoint)
+	}
+	e, ok := r.endpoints[name]
+	if !ok {
+		e = &endpoint{stats: EndpointStats{Name: name}, latency: NewHistogram()}
+		r.endpoints[name] = e
+		r.endpointOrder = append(r.endpointOrder, name)
+	}
+	return e
+}
+
+func (e *endpoint) add(sample Sample) {
+	e.stats.TotalRequests++
+	if !sample.Success() {
+		e.stats.FailedRequests++
+		if e.stats.FailuresByClass == nil {
+			e.stats.FailuresByClass = make(map[string]int)
+		}
+		e.stats.FailuresByClass[sample.ErrorClass]++
+		return
+	}
+	e.stats.SuccessfulRequests++
+	e.latency.Record(sample.Duration)
+}
+
+// merge adds the requests counted by other to e.
+func (e *endpoint) merge(other *endpoint) {
+	e.stats.TotalRequests += other.stats.TotalRequests
+	e.stats.SuccessfulRequests += other.stats.SuccessfulRequests
+	e.stats.FailedRequests += other.stats.FailedRequests
+	for class, count := range other.stats.FailuresByClass {
+		if e.stats.FailuresByClass == nil {
+			e.stats.FailuresByClass = make(map[string]int)
+		}
+		e.stats.FailuresByClass[class] += count
+	}
+	e.latency.Merge(other.latency)
+}
+
+func (e *endpoint) summarize(percentiles []float64, totalRequests int) EndpointStats {
+	stats := e.stats
+	if totalRequests > 0 {
+		stats.Share = float64(stats.TotalRequests) / float64(totalRequests) * 100
+	}
+	stats.Latency = latencyStats(e.latency)
+	stats.Percentiles = make([]Percentile, len(percentiles))
+	for i, p := range percentiles {
+		stats.Percentiles[i] = Percentile{Percentile: p, Value: e.latency.Percentile(p)}
+	}
+	return stats
+}
+
+// SuccessRate returns the percentage of the endpoint's requests that
+// succeeded.
+func (s EndpointStats) SuccessRate() float64 {
+	if s.TotalRequests == 0 {
+		return 0
+	}
+	return float64(s.SuccessfulRequests) / float64(s.TotalRequests) * 100
+}
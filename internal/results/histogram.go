@@ -0,0 +1,254 @@
+package results
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	// histogramUnit is the resolution values are recorded at (1µs).
+	histogramUnit = 1e-6
+	// histogramHighest is the largest trackable value in units (1 hour).
+	histogramHighest = int64(3600 / histogramUnit)
+	// histogramSignificantFigures keeps every recorded value within 0.1%.
+	histogramSignificantFigures = 3
+)
+
+// Histogram is a High Dynamic Range style latency histogram. It uses
+// logarithmic buckets split into linear sub-buckets, so memory stays fixed
+// (a few hundred KB) however many values are recorded while percentiles
+// remain accurate to the configured number of significant figures.
+// Values are passed in and returned as seconds.
+type Histogram struct {
+	subBucketHalfCountMagnitude int
+	subBucketHalfCount          int
+	subBucketMask               int64
+	subBucketCount              int
+	counts                      []int64
+
+	total      int64
+	sum        float64
+	sumSquares float64
+	min        float64
+	max        float64
+}
+
+func NewHistogram() *Histogram {
+	return newHistogram(histogramSignificantFigures)
+}
+
+// newHistogram returns a histogram that keeps values to the given number of
+// significant figures. Fewer figures take less memory.
+func newHistogram(significantFigures int) *Histogram {
+	largestSingleUnit := 2 * int64(math.Pow10(significantFigures))
+	subBucketCountMagnitude := int(math.Ceil(math.Log2(float64(largestSingleUnit))))
+	subBucketCount := 1 << subBucketCountMagnitude
+
+	bucketsNeeded := 1
+	for smallestUntrackable := int64(subBucketCount); smallestUntrackable <= histogramHighest; smallestUntrackable <<= 1 {
+		bucketsNeeded++
+	}
+
+	h := &Histogram{
+		subBucketHalfCountMagnitude: subBucketCountMagnitude - 1,
+		subBucketHalfCount:          subBucketCount / 2,
+		subBucketMask:               int64(subBucketCount - 1),
+		subBucketCount:              subBucketCount,
+		min:                         math.Inf(1),
+	}
+	h.counts = make([]int64, (bucketsNeeded+1)*h.subBucketHalfCount)
+	return h
+}
+
+// Record adds a value in seconds. Values beyond the trackable range are
+// clamped to it.
+func (h *Histogram) Record(seconds float64) {
+	h.RecordN(seconds, 1)
+}
+
+// RecordN adds count occurrences of a value in seconds.
+func (h *Histogram) RecordN(seconds float64, count int64) {
+	if count <= 0 {
+		return
+	}
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	units := int64(math.Round(seconds / histogramUnit))
+	if units > histogramHighest {
+		units = histogramHighest
+	}
+	h.counts[h.countsIndex(units)] += count
+
+	h.total += count
+	h.sum += seconds * float64(count)
+	h.sumSquares += seconds * seconds * float64(count)
+	h.min = math.Min(h.min, seconds)
+	h.max = math.Max(h.max, seconds)
+}
+
+// Merge adds every value recorded in other to h.
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.total == 0 {
+		return
+	}
+	for i, count := range other.counts {
+		h.counts[i] += count
+	}
+	h.total += other.total
+	h.sum += other.sum
+	h.sumSquares += other.sumSquares
+	h.min = math.Min(h.min, other.min)
+	h.max = math.Max(h.max, other.max)
+}
+
+// reset forgets every recorded value, so the histogram can be reused.
+func (h *Histogram) reset() {
+	clear(h.counts)
+	h.total, h.sum, h.sumSquares = 0, 0, 0
+	h.min, h.max = math.Inf(1), 0
+}
+
+// CorrectedCopy returns a copy of h compensated for coordinated omission.
+// For every value larger than expectedInterval it adds the values that the
+// requests which would have been sent while waiting would have seen
+// (value-interval, value-2*interval, ...), as HdrHistogram does.
+func (h *Histogram) CorrectedCopy(expectedInterval float64) *Histogram {
+	corrected := NewHistogram()
+	if h.total == 0 {
+		return corrected
+	}
+	if expectedInterval <= 0 {
+		corrected.Merge(h)
+		return corrected
+	}
+
+	for i, count := range h.counts {
+		if count == 0 {
+			continue
+		}
+		value := math.Min(float64(h.highestEquivalentValue(h.valueFromIndex(i)))*histogramUnit, h.max)
+		corrected.RecordN(value, count)
+		for missing := value - expectedInterval; missing >= expectedInterval; missing -= expectedInterval {
+			corrected.RecordN(missing, count)
+		}
+	}
+	corrected.min = math.Min(corrected.min, h.min)
+	return corrected
+}
+
+func (h *Histogram) Count() int64 {
+	return h.total
+}
+
+func (h *Histogram) Min() float64 {
+	if h.total == 0 {
+		return 0
+	}
+	return h.min
+}
+
+func (h *Histogram) Max() float64 {
+	return h.max
+}
+
+func (h *Histogram) Mean() float64 {
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / float64(h.total)
+}
+
+// StdDev returns the population standard deviation of the recorded values.
+func (h *Histogram) StdDev() float64 {
+	if h.total == 0 {
+		return 0
+	}
+	mean := h.Mean()
+	variance := h.sumSquares/float64(h.total) - mean*mean
+	if variance < 0 {
+		return 0
+	}
+	return math.Sqrt(variance)
+}
+
+// Percentile returns the value in seconds below which p percent of the
+// recorded values fall.
+func (h *Histogram) Percentile(p float64) float64 {
+	if h.total == 0 {
+		return 0
+	}
+	p = math.Min(math.Max(p, 0), 100)
+
+	target := int64(p/100*float64(h.total) + 0.5)
+	if target < 1 {
+		target = 1
+	}
+
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= target {
+			value := float64(h.highestEquivalentValue(h.valueFromIndex(i))) * histogramUnit
+			return math.Min(math.Max(value, h.min), h.max)
+		}
+	}
+	return h.max
+}
+
+// HistogramPoint is one step of a cumulative distribution.
+type HistogramPoint struct {
+	Value      float64 // seconds
+	Cumulative float64 // fraction of values <= Value
+}
+
+// Distribution returns the cumulative distribution over all non-empty
+// buckets, in ascending order.
+func (h *Histogram) Distribution() []HistogramPoint {
+	var points []HistogramPoint
+	var seen int64
+	for i, count := range h.counts {
+		if count == 0 {
+			continue
+		}
+		seen += count
+		value := float64(h.highestEquivalentValue(h.valueFromIndex(i))) * histogramUnit
+		points = append(points, HistogramPoint{
+			Value:      math.Min(value, h.max),
+			Cumulative: float64(seen) / float64(h.total),
+		})
+	}
+	return points
+}
+
+func (h *Histogram) bucketIndex(units int64) int {
+	pow2Ceiling := 64 - bits.LeadingZeros64(uint64(units|h.subBucketMask))
+	return pow2Ceiling - (h.subBucketHalfCountMagnitude + 1)
+}
+
+func (h *Histogram) countsIndex(units int64) int {
+	bucket := h.bucketIndex(units)
+	subBucket := int(units >> uint(bucket))
+	return (bucket+1)<<uint(h.subBucketHalfCountMagnitude) + subBucket - h.subBucketHalfCount
+}
+
+func (h *Histogram) valueFromIndex(index int) int64 {
+	bucket := (index >> uint(h.subBucketHalfCountMagnitude)) - 1
+	subBucket := (index & (h.subBucketHalfCount - 1)) + h.subBucketHalfCount
+	if bucket < 0 {
+		subBucket -= h.subBucketHalfCount
+		bucket = 0
+	}
+	return int64(subBucket) << uint(bucket)
+}
+
+func (h *Histogram) highestEquivalentValue(units int64) int64 {
+	bucket := h.bucketIndex(units)
+	subBucket := units >> uint(bucket)
+	size := int64(1) << uint(bucket)
+	if subBucket >= int64(h.subBucketCount) {
+		size <<= 1
+	}
+	return subBucket<<uint(bucket) + size - 1
+}
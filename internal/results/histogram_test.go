@@ -0,0 +1,181 @@
+package results
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// referencePercentile picks the value at the same rank as
+// Histogram.Percentile from sorted values.
+func referencePercentile(sorted []float64, p float64) float64 {
+	rank := int(p/100*float64(len(sorted)) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// withinPrecision reports whether got is within the histogram's precision
+// of want: three significant figures, at the 1µs recording unit.
+func withinPrecision(got, want float64) bool {
+	return math.Abs(got-want) <= want*math.Pow10(-histogramSignificantFigures)+histogramUnit
+}
+
+// latencies returns n log-normally distributed response times of around
+// 50ms, from 1µs up to several seconds.
+func latencies(n int, seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = math.Max(0.05*math.Exp(rng.NormFloat64()*1.5), histogramUnit)
+	}
+	return values
+}
+
+func TestHistogramRecord(t *testing.T) {
+	h := NewHistogram()
+	values := []float64{0.010, 0.020, 0.030, 0.040}
+	for _, v := range values {
+		h.Record(v)
+	}
+	h.RecordN(0.050, 2)
+
+	if h.Count() != 6 {
+		t.Errorf("Count = %d, want 6", h.Count())
+	}
+	if h.Min() != 0.010 {
+		t.Errorf("Min = %g, want 0.010", h.Min())
+	}
+	if h.Max() != 0.050 {
+		t.Errorf("Max = %g, want 0.050", h.Max())
+	}
+	if mean := 0.2 / 6; math.Abs(h.Mean()-mean) > 1e-12 {
+		t.Errorf("Mean = %g, want %g", h.Mean(), mean)
+	}
+	if got := h.Percentile(50); !withinPrecision(got, 0.030) {
+		t.Errorf("P50 = %g, want 0.030", got)
+	}
+	if got := h.Percentile(100); got != 0.050 {
+		t.Errorf("P100 = %g, want the max 0.050", got)
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	h := NewHistogram()
+	for name, got := range map[string]float64{
+		"Min": h.Min(), "Max": h.Max(), "Mean": h.Mean(), "StdDev": h.StdDev(), "P99": h.Percentile(99),
+	} {
+		if got != 0 {
+			t.Errorf("%s of an empty histogram = %g, want 0", name, got)
+		}
+	}
+}
+
+func TestHistogramClampsOutOfRangeValues(t *testing.T) {
+	h := NewHistogram()
+	h.Record(-1)
+	h.Record(2 * 3600)
+	if h.Count() != 2 {
+		t.Fatalf("Count = %d, want 2", h.Count())
+	}
+	if h.Min() != 0 {
+		t.Errorf("Min = %g, want 0", h.Min())
+	}
+	if got := h.Percentile(100); got > 2*3600 {
+		t.Errorf("P100 = %g, beyond the recorded max", got)
+	}
+}
+
+func TestHistogramPercentileAccuracy(t *testing.T) {
+	values := latencies(100000, 1)
+	h := NewHistogram()
+	for _, v := range values {
+		h.Record(v)
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	for _, p := range []float64{1, 10, 50, 75, 90, 95, 99, 99.9, 99.99, 100} {
+		want := referencePercentile(sorted, p)
+		if got := h.Percentile(p); !withinPrecision(got, want) {
+			t.Errorf("P%g = %g, want %g within %d significant figures", p, got, want, histogramSignificantFigures)
+		}
+	}
+	if h.Min() != sorted[0] {
+		t.Errorf("Min = %g, want %g", h.Min(), sorted[0])
+	}
+	if h.Max() != sorted[len(sorted)-1] {
+		t.Errorf("Max = %g, want %g", h.Max(), sorted[len(sorted)-1])
+	}
+
+	var sum, squares float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(squares / float64(len(values)))
+	if math.Abs(h.Mean()-mean) > 1e-9 {
+		t.Errorf("Mean = %g, want %g", h.Mean(), mean)
+	}
+	if math.Abs(h.StdDev()-stddev) > stddev*1e-6 {
+		t.Errorf("StdDev = %g, want %g", h.StdDev(), stddev)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	first, second := latencies(20000, 2), latencies(5000, 3)
+	a, b, all := NewHistogram(), NewHistogram(), NewHistogram()
+	for _, v := range first {
+		a.Record(v)
+		all.Record(v)
+	}
+	for _, v := range second {
+		b.Record(v)
+		all.Record(v)
+	}
+	a.Merge(b)
+	a.Merge(nil)
+	a.Merge(NewHistogram())
+
+	sorted := append(append([]float64{}, first...), second...)
+	sort.Float64s(sorted)
+	if a.Count() != int64(len(sorted)) {
+		t.Fatalf("Count = %d, want %d", a.Count(), len(sorted))
+	}
+	if a.Min() != sorted[0] || a.Max() != sorted[len(sorted)-1] {
+		t.Errorf("Min, Max = %g, %g, want %g, %g", a.Min(), a.Max(), sorted[0], sorted[len(sorted)-1])
+	}
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		want := referencePercentile(sorted, p)
+		if got := a.Percentile(p); !withinPrecision(got, want) {
+			t.Errorf("merged P%g = %g, want %g", p, got, want)
+		}
+		if got, direct := a.Percentile(p), all.Percentile(p); got != direct {
+			t.Errorf("merged P%g = %g, recording directly gives %g", p, got, direct)
+		}
+	}
+}
+
+func TestHistogramDistribution(t *testing.T) {
+	h := NewHistogram()
+	for _, v := range latencies(1000, 4) {
+		h.Record(v)
+	}
+	points := h.Distribution()
+	if len(points) == 0 {
+		t.Fatal("empty distribution")
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].Value <= points[i-1].Value || points[i].Cumulative <= points[i-1].Cumulative {
+			t.Fatalf("distribution not ascending at %d: %+v after %+v", i, points[i], points[i-1])
+		}
+	}
+	if last := points[len(points)-1]; last.Cumulative != 1 || last.Value != h.Max() {
+		t.Errorf("last point = %+v, want cumulative 1 at the max %g", last, h.Max())
+	}
+}
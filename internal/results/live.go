@@ -0,0 +1,186 @@
+package results
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// LiveHistory is how many seconds of request rate history a Progress
+	// holds.
+	LiveHistory = 60
+	// LiveLatencyWindow is how many seconds the rolling percentiles of a
+	// Progress cover.
+	LiveLatencyWindow = 10
+	// liveRateWindow is how many complete seconds the current rate of a
+	// Progress is averaged over.
+	liveRateWindow = 5
+	// liveSignificantFigures is the precision of the rolling percentiles,
+	// which keeps them within 1% like those of the timeline.
+	liveSignificantFigures = 2
+)
+
+// Progress is a snapshot of a run in progress, for live displays.
+type Progress struct {
+	Started         bool    // false during warmup
+	Elapsed         float64 // seconds since the run started
+	PlannedDuration float64 // seconds, 0 when the run is a number of requests
+	Done            float64 // share of the planned run completed, 0 to 1
+	Requests        int
+	Failed          int
+	InFlight        int
+	RPS             float64 // requests per second over the last few seconds
+	P50             float64 // rolling percentiles of successful requests, in seconds
+	P95             float64
+	P99             float64
+	Errors          map[string]int // failed requests by status code or error class
+	History         []int          `json:",omitempty"` // requests completed in each second, oldest first
+}
+
+// Live keeps the statistics of a run that live displays show. Samples are
+// added by the collector while displays take snapshots from other
+// goroutines, so unlike Results it is safe for concurrent use. Only samples
+// that complete after Begin are counted.
+type Live struct {
+	mu              sync.Mutex
+	start           time.Time
+	plannedDuration float64
+	plannedRequests int
+	requests        int
+	failed          int
+	errors          map[string]int
+	second          int64 // latest second since start with a bucket
+	counts          [LiveHistory]int
+	latency         [LiveLatencyWindow]*Histogram
+	merged          *Histogram // reused by every Progress
+
+	inFlight atomic.Int64
+}
+
+func NewLive() *Live {
+	return &Live{errors: make(map[string]int)}
+}
+
+// Begin marks the start of the measured run, after warmup. Progress is
+// measured against plannedDuration seconds, or else plannedRequests.
+func (l *Live) Begin(start time.Time, plannedDuration float64, plannedRequests int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.start = start
+	l.plannedDuration = plannedDuration
+	l.plannedRequests = plannedRequests
+}
+
+// AddInFlight adjusts the number of requests in flight by delta.
+func (l *Live) AddInFlight(delta int) {
+	l.inFlight.Add(int64(delta))
+}
+
+// Add counts the final attempt of a request. Warmup samples, retried
+// attempts and samples that complete before Begin are ignored.
+func (l *Live) Add(sample Sample) {
+	if sample.Warmup || sample.Retried {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.start.IsZero() {
+		return
+	}
+	second := int64(sampleEnd(sample).Sub(l.start) / time.Second)
+	l.advance(second)
+	if second < 0 || second <= l.second-LiveHistory {
+		return
+	}
+
+	l.requests++
+	l.counts[second%LiveHistory]++
+	if !sample.Success() {
+		l.failed++
+		key := sample.ErrorClass
+		if sample.ErrorClass == ErrorStatus && sample.StatusCode > 0 {
+			key = strconv.Itoa(sample.StatusCode)
+		}
+		l.errors[key]++
+		return
+	}
+	if second > l.second-LiveLatencyWindow {
+		h := l.latency[second%LiveLatencyWindow]
+		if h == nil {
+			h = newHistogram(liveSignificantFigures)
+			l.latency[second%LiveLatencyWindow] = h
+		}
+		h.Record(sample.Duration)
+	}
+}
+
+// advance moves the latest second forward to second, clearing the buckets
+// of the seconds it passes.
+func (l *Live) advance(second int64) {
+	for s := max(l.second+1, second-LiveHistory+1); s <= second; s++ {
+		l.counts[s%LiveHistory] = 0
+		l.latency[s%LiveLatencyWindow] = nil
+	}
+	l.second = max(l.second, second)
+}
+
+// Progress returns a snapshot of the run at now.
+func (l *Live) Progress(now time.Time) Progress {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p := Progress{
+		Started:         !l.start.IsZero(),
+		PlannedDuration: l.plannedDuration,
+		Requests:        l.requests,
+		Failed:          l.failed,
+		InFlight:        int(l.inFlight.Load()),
+		Errors:          make(map[string]int, len(l.errors)),
+	}
+	for key, count := range l.errors {
+		p.Errors[key] = count
+	}
+	if !p.Started {
+		return p
+	}
+
+	p.Elapsed = now.Sub(l.start).Seconds()
+	switch {
+	case l.plannedDuration > 0:
+		p.Done = min(p.Elapsed/l.plannedDuration, 1)
+	case l.plannedRequests > 0:
+		p.Done = min(float64(l.requests)/float64(l.plannedRequests), 1)
+	}
+
+	// The current second is still filling up, so rates and history use
+	// complete seconds only.
+	current := int64(now.Sub(l.start) / time.Second)
+	l.advance(current)
+	for s := max(0, current-LiveHistory+1); s < current; s++ {
+		p.History = append(p.History, l.counts[s%LiveHistory])
+	}
+	if n := min(len(p.History), liveRateWindow); n > 0 {
+		total := 0
+		for _, count := range p.History[len(p.History)-n:] {
+			total += count
+		}
+		p.RPS = float64(total) / float64(n)
+	} else if p.Elapsed > 0 {
+		p.RPS = float64(l.requests) / p.Elapsed
+	}
+
+	if l.merged == nil {
+		l.merged = newHistogram(liveSignificantFigures)
+	}
+	l.merged.reset()
+	for _, h := range l.latency {
+		if h != nil {
+			l.merged.Merge(h)
+		}
+	}
+	if l.merged.Count() > 0 {
+		p.P50, p.P95, p.P99 = l.merged.Percentile(50), l.merged.Percentile(95), l.merged.Percentile(99)
+	}
+	return p
+}
@@ -0,0 +1,135 @@
+package results
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// endingIn returns s started so that it completes in the given second of a
+// run that began at base.
+func endingIn(base time.Time, second int, s Sample) Sample {
+	return at(base, float64(second)+0.5-s.Duration, s)
+}
+
+func TestLiveCountsCompleteSeconds(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	l := NewLive()
+	l.Add(endingIn(base, 0, succeeded(0.1))) // before Begin
+	l.Begin(base, 60, 0)
+	l.AddInFlight(3)
+
+	for second := 0; second < 5; second++ {
+		for i := 0; i <= second; i++ {
+			l.Add(endingIn(base, second, succeeded(0.1)))
+		}
+		l.Add(endingIn(base, second, warmup(succeeded(0.1))))
+		l.Add(endingIn(base, second, retried(0.1)))
+	}
+	unavailable := failed(0.1, ErrorStatus)
+	unavailable.StatusCode = 503
+	l.Add(endingIn(base, 2, unavailable))
+	l.Add(endingIn(base, 3, failed(0.1, ErrorTimeout)))
+	// Still filling up when the snapshot is taken.
+	l.Add(endingIn(base, 5, succeeded(0.1)))
+
+	p := l.Progress(base.Add(5500 * time.Millisecond))
+	if !p.Started || p.Elapsed != 5.5 || p.Done != 5.5/60 {
+		t.Errorf("started %v, elapsed %g, done %g, want true, 5.5, %g", p.Started, p.Elapsed, p.Done, 5.5/60)
+	}
+	if p.Requests != 18 || p.Failed != 2 || p.InFlight != 3 {
+		t.Errorf("%d requests, %d failed, %d in flight, want 18, 2, 3", p.Requests, p.Failed, p.InFlight)
+	}
+	if want := []int{1, 2, 4, 5, 5}; !reflect.DeepEqual(p.History, want) {
+		t.Errorf("History = %v, want %v", p.History, want)
+	}
+	if p.RPS != 17.0/5 {
+		t.Errorf("RPS = %g, want %g over the complete seconds", p.RPS, 17.0/5)
+	}
+	if want := map[string]int{"503": 1, ErrorTimeout: 1}; !reflect.DeepEqual(p.Errors, want) {
+		t.Errorf("Errors = %v, want %v", p.Errors, want)
+	}
+}
+
+func TestLiveBeforeBegin(t *testing.T) {
+	l := NewLive()
+	l.Add(succeeded(0.1))
+	if p := l.Progress(time.Now()); p.Started || p.Requests != 0 {
+		t.Errorf("progress before Begin = %+v", p)
+	}
+}
+
+func TestLiveHistoryRollsOver(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	l := NewLive()
+	l.Begin(base, 0, 0)
+	count := func(second int) int { return second%7 + 1 }
+	total := 0
+	for second := 0; second < 70; second++ {
+		for i := 0; i < count(second); i++ {
+			l.Add(endingIn(base, second, succeeded(0.1)))
+		}
+		total += count(second)
+	}
+	// Too late for a second that left the history.
+	l.Add(endingIn(base, 5, succeeded(0.1)))
+
+	p := l.Progress(base.Add(70500 * time.Millisecond))
+	if p.Requests != total {
+		t.Errorf("Requests = %d, want %d", p.Requests, total)
+	}
+	if len(p.History) != LiveHistory-1 {
+		t.Fatalf("%d seconds of history, want %d", len(p.History), LiveHistory-1)
+	}
+	for i, got := range p.History {
+		if second := 70 - (LiveHistory - 1) + i; got != count(second) {
+			t.Errorf("history of second %d = %d, want %d", second, got, count(second))
+		}
+	}
+
+	// A minute without requests clears every bucket it passes.
+	p = l.Progress(base.Add(135 * time.Second))
+	for i, got := range p.History {
+		if got != 0 {
+			t.Fatalf("history %d = %d after a minute without requests", i, got)
+		}
+	}
+	if p.RPS != 0 || p.P99 != 0 {
+		t.Errorf("RPS %g, P99 %g after a minute without requests, want 0", p.RPS, p.P99)
+	}
+}
+
+func TestLiveRollingPercentiles(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	l := NewLive()
+	l.Begin(base, 0, 100)
+	for second := 0; second < 5; second++ {
+		l.Add(endingIn(base, second, succeeded(0.2)))
+		l.Add(endingIn(base, second, succeeded(0.4)))
+		l.Add(endingIn(base, second, failed(3, ErrorTimeout)))
+	}
+
+	now := base.Add(9500 * time.Millisecond)
+	p := l.Progress(now)
+	if !withinWindowPrecision(p.P50, 0.2) || !withinWindowPrecision(p.P99, 0.4) {
+		t.Errorf("P50, P99 = %g, %g, want 0.2, 0.4 from the successful requests", p.P50, p.P99)
+	}
+	if p.Done != 15.0/100 {
+		t.Errorf("Done = %g, want the share of the planned requests", p.Done)
+	}
+	if again := l.Progress(now); again.P50 != p.P50 || again.P99 != p.P99 {
+		t.Errorf("a second snapshot gives P50, P99 = %g, %g, want %g, %g", again.P50, again.P99, p.P50, p.P99)
+	}
+
+	// Seconds 0 to 4 leave the latency window as seconds 10 to 14 start.
+	for second := 12; second < 14; second++ {
+		l.Add(endingIn(base, second, succeeded(0.01)))
+	}
+	p = l.Progress(base.Add(14500 * time.Millisecond))
+	if !withinWindowPrecision(p.P50, 0.01) || !withinWindowPrecision(p.P99, 0.01) {
+		t.Errorf("P50, P99 = %g, %g, want 0.01 from the last %d seconds only", p.P50, p.P99, LiveLatencyWindow)
+	}
+	if p.Requests != 17 {
+		t.Errorf("Requests = %d, want every one of the 17", p.Requests)
+	}
+}
@@ -0,0 +1,30 @@
+package results
+
+// Phases breaks the time spent on an attempt down into the HTTP phases
+// reported by httptrace. All values are in seconds; connection phases are 0
+// when a kept-alive connection was reused.
+type Phases struct {
+	DNS      float64
+	Connect  float64
+	TLS      float64
+	TTFB     float64 // request written until first response byte (server time)
+	Transfer float64 // first response byte until the body was read
+}
+
+// PhaseNames lists the phases in the order they happen.
+var PhaseNames = []string{"DNS", "Connect", "TLS", "TTFB", "Transfer"}
+
+func (p Phases) values() []float64 {
+	return []float64{p.DNS, p.Connect, p.TLS, p.TTFB, p.Transfer}
+}
+
+// PhaseStats summarizes one phase across all successful requests. Count is
+// the number of requests in which the phase was observed at all.
+type PhaseStats struct {
+	Name   string
+	Count  int
+	Min    float64
+	Median float64
+	P90    float64
+	P99    float64
+}
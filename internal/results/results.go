@@ -4,33 +4,153 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"strconv"
+	"time"
 )
 
+// VUPoint records the number of active threads at a point in the run.
+type VUPoint struct {
+	Elapsed float64
+	VUs     int
+}
+
+// DefaultPercentiles are reported when no percentiles are configured.
+var DefaultPercentiles = []float64{50, 75, 90, 95, 99}
+
+// Percentile is the response time (in seconds) at a given percentile.
+type Percentile struct {
+	Percentile float64
+	Value      float64
+}
+
 type Results struct {
-	TotalRequests      int
-	SuccessfulRequests int
-	FailedRequests     int
-	ResponseTimes      []float64
-	FailedStatusCodes  []int
-	MinTime            float64
-	MaxTime            float64
-	MedianTime         float64
-	PercentileTime90   float64
-	AverageTime        float64
-	TotalDuration      float64
-}
-
-func (r *Results) OutputJSON() error {
-	jsonData, err := json.MarshalIndent(r, "", "  ")
+	StartTime            time.Time // when the measured run started, after warmup
+	Interrupted          bool
+	Aborted              bool // stopped early because a threshold failed
+	StopReason           string
+	PlannedRequests      int
+	PlannedDuration      float64
+	TargetRate           float64
+	DroppedArrivals      int
+	StageBoundaries      []float64
+	VUTimeline           []VUPoint
+	WarmupRequests       int
+	TotalRequests        int
+	SuccessfulRequests   int
+	FailedRequests       int
+	FailuresByClass      map[string]int
+	TotalAttempts        int
+	RetriedAttempts      int
+	RetriedRequests      int
+	AttemptsPerRequest   float64
+	MinTime              float64
+	MaxTime              float64
+	MedianTime           float64
+	PercentileTime90     float64
+	AverageTime          float64
+	StdDevTime           float64
+	Percentiles          []Percentile
+	ExpectedInterval     float64
+	CorrectedPercentiles []Percentile
+	CorrectedRequests    int
+	ErrorLatency         LatencyStats
+	TotalDuration        float64
+	Throughput           float64
+	Phases               []PhaseStats
+	ScenarioSteps        int
+	Endpoints            []EndpointStats
+	Tags                 []EndpointStats
+	Checks               []CheckStats
+	Thresholds           []ThresholdResult
+	BucketSize           float64 // seconds covered by each Timeline bucket
+	Timeline             []TimelineBucket
+	TotalSamples         int
+	Samples              []Sample   `json:",omitempty"`
+	Latency              *Histogram `json:"-"`
+
+	percentiles  []float64
+	maxSamples   int
+	errorLatency *Histogram
+	phaseLatency []*Histogram
+	timeline     *timeline // nil when no timeline is kept
+
+	endpoints     map[string]*endpoint
+	endpointOrder []string
+	tags          map[string]*endpoint
+	tagOrder      []string
+	endpointTags  map[string][]string
+
+	checks     map[checkKey]*CheckStats
+	checkOrder []checkKey
+}
+
+// New returns empty results that report the given percentiles, retain at
+// most maxSamples raw samples (0 keeps all of them) and summarize the run in
+// a timeline of bucketSize buckets (0 keeps no timeline).
+func New(percentiles []float64, maxSamples int, bucketSize time.Duration) Results {
+	r := Results{
+		percentiles: percentiles,
+		maxSamples:  maxSamples,
+	}
+	if bucketSize > 0 {
+		r.BucketSize = bucketSize.Seconds()
+		r.timeline = &timeline{size: bucketSize}
+	}
+	r.histograms()
+	return r
+}
+
+// OutputJSON writes the results to results.json. The retained samples are
+// left out unless withSamples is set, since they make up most of the file.
+func (r *Results) OutputJSON(withSamples bool) error {
+	if err := r.writeJSON("results.json", withSamples); err != nil {
+		return err
+	}
+	fmt.Println("Results saved to results.json")
+	return nil
+}
+
+func (r *Results) writeJSON(path string, withSamples bool) error {
+	out := *r
+	if !withSamples {
+		out.Samples = nil
+	}
+	jsonData, err := json.MarshalIndent(out, "", "  ")
 	if err != nil {
 		return fmt.Errorf("error marshalling JSON: %v", err)
 	}
 
-	err = ioutil.WriteFile("results.json", jsonData, 0644)
+	err = ioutil.WriteFile(path, jsonData, 0644)
 	if err != nil {
 		return fmt.Errorf("error writing JSON file: %v", err)
 	}
-
-	fmt.Println("Results saved to results.json")
 	return nil
 }
+
+// ReadJSON loads results previously written by OutputJSON. Statistics are
+// restored as saved; the latency histogram used for the charts is rebuilt
+// from the retained samples, and stays empty when they were not written.
+func ReadJSON(path string) (Results, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Results{}, fmt.Errorf("error reading JSON file: %v", err)
+	}
+
+	var r Results
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Results{}, fmt.Errorf("error parsing JSON file: %v", err)
+	}
+
+	r.histograms()
+	for _, sample := range r.Samples {
+		if !sample.Retried && sample.Success() {
+			r.Latency.Record(sample.Duration)
+		}
+	}
+	return r, nil
+}
+
+// Label formats the percentile such as 99.9 as "P99.9".
+func (p Percentile) Label() string {
+	return "P" + strconv.FormatFloat(p.Percentile, 'f', -1, 64)
+}
@@ -0,0 +1,134 @@
+package results
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// stalled returns results with 999 requests of 5ms and one that stalled
+// for a second.
+func stalled(expectedInterval, targetRate float64, dropped int) Results {
+	r := New([]float64{50, 99}, 0, 0)
+	r.ExpectedInterval = expectedInterval
+	r.TargetRate = targetRate
+	r.DroppedArrivals = dropped
+	for i := 0; i < 999; i++ {
+		r.Add(succeeded(0.005))
+	}
+	r.Add(succeeded(1))
+	r.Summarize()
+	return r
+}
+
+func percentile(list []Percentile, p float64) float64 {
+	for _, item := range list {
+		if item.Percentile == p {
+			return item.Value
+		}
+	}
+	return -1
+}
+
+func TestCoordinatedOmissionCorrection(t *testing.T) {
+	// A 1s stall at an expected interval of 1/64s hides 63 requests, which
+	// would have waited 63/64s, 62/64s, ... 1/64s.
+	r := stalled(1.0/64, 0, 0)
+
+	if r.CorrectedRequests != 1000+63 {
+		t.Errorf("CorrectedRequests = %d, want %d", r.CorrectedRequests, 1000+63)
+	}
+	if raw := percentile(r.Percentiles, 99); !withinPrecision(raw, 0.005) {
+		t.Errorf("raw P99 = %g, want 0.005", raw)
+	}
+	// P99 of 1063 values is the 1052nd: the 53rd of the 64 stalled ones.
+	if corrected := percentile(r.CorrectedPercentiles, 99); !withinPrecision(corrected, 53.0/64) {
+		t.Errorf("corrected P99 = %g, want %g", corrected, 53.0/64)
+	}
+	if raw, corrected := percentile(r.Percentiles, 50), percentile(r.CorrectedPercentiles, 50); raw != corrected {
+		t.Errorf("corrected P50 = %g, want the raw %g", corrected, raw)
+	}
+}
+
+func TestCorrectionInterval(t *testing.T) {
+	tests := []struct {
+		name             string
+		expectedInterval float64
+		targetRate       float64
+		dropped          int
+		want             float64
+	}{
+		{name: "explicit", expectedInterval: 0.25, want: 0.25},
+		{name: "median of a closed model run", want: 0.005},
+		{name: "open model run", targetRate: 200, want: 0},
+		{name: "open model run that dropped arrivals", targetRate: 200, dropped: 10, want: 0.005},
+		{name: "explicit in an open model run", expectedInterval: 0.25, targetRate: 200, dropped: 10, want: 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := stalled(tt.expectedInterval, tt.targetRate, tt.dropped)
+			if got := r.correctionInterval(); !withinPrecision(got, tt.want) {
+				t.Errorf("correctionInterval() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoCorrectionForOpenModel(t *testing.T) {
+	r := stalled(0, 200, 0)
+	if r.CorrectedRequests != r.SuccessfulRequests {
+		t.Errorf("CorrectedRequests = %d, want the %d measured", r.CorrectedRequests, r.SuccessfulRequests)
+	}
+	for i, p := range r.CorrectedPercentiles {
+		if p.Value != r.Percentiles[i].Value {
+			t.Errorf("corrected %s = %g, want the raw %g", p.Label(), p.Value, r.Percentiles[i].Value)
+		}
+	}
+}
+
+func TestCorrectionForDroppedArrivals(t *testing.T) {
+	// At 64 requests per second the 1s stall dropped the arrivals due in
+	// the meantime, which the correction puts back like a closed-model one.
+	r := stalled(0, 64, 63)
+	if r.ExpectedInterval != 1.0/64 {
+		t.Errorf("ExpectedInterval = %g, want %g", r.ExpectedInterval, 1.0/64)
+	}
+	if r.CorrectedRequests != 1000+63 {
+		t.Errorf("CorrectedRequests = %d, want %d", r.CorrectedRequests, 1000+63)
+	}
+	if corrected := percentile(r.CorrectedPercentiles, 99); !withinPrecision(corrected, 53.0/64) {
+		t.Errorf("corrected P99 = %g, want %g", corrected, 53.0/64)
+	}
+}
+
+func TestJSONOnlyKeepsSamplesWhenAsked(t *testing.T) {
+	r := New([]float64{50, 99}, 0, 0)
+	for i := 0; i < 10; i++ {
+		r.Add(succeeded(0.1))
+	}
+	r.Summarize()
+
+	for _, withSamples := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "results.json")
+		if err := r.writeJSON(path, withSamples); err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadJSON(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read.TotalRequests != 10 || read.TotalSamples != 10 || read.MedianTime != r.MedianTime {
+			t.Errorf("with samples %v: read %d requests, %d samples, median %g, want 10, 10, %g",
+				withSamples, read.TotalRequests, read.TotalSamples, read.MedianTime, r.MedianTime)
+		}
+		want := 0
+		if withSamples {
+			want = 10
+		}
+		if len(read.Samples) != want || read.Latency.Count() != int64(want) {
+			t.Errorf("with samples %v: read %d samples and %d latencies, want %d", withSamples, len(read.Samples), read.Latency.Count(), want)
+		}
+	}
+	if len(r.Samples) != 10 {
+		t.Errorf("writing JSON dropped the samples of the results themselves")
+	}
+}
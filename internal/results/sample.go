@@ -0,0 +1,42 @@
+package results
+
+import (
+	"time"
+)
+
+// Error classes recorded on a Sample.
+const (
+	ErrorNone    = ""
+	ErrorRequest = "request"
+	ErrorNetwork = "network"
+	ErrorTimeout = "timeout"
+	ErrorStatus  = "status"
+	ErrorPattern = "pattern"
+	ErrorExtract = "extract"
+	ErrorSchema  = "schema"
+	ErrorCheck   = "check"
+)
+
+// Sample is a single HTTP attempt as observed by a worker.
+type Sample struct {
+	Name          string // scenario step, empty for a single URL
+	Start         time.Time
+	Duration      float64 // seconds
+	StatusCode    int     // 0 when no response was received
+	Attempt       int     // 1-based
+	Retried       bool    // this attempt failed and another one followed
+	Warmup        bool    // sent during warmup, excluded from the statistics
+	BytesSent     int64   // request body bytes
+	BytesReceived int64   // response body bytes
+	ErrorClass    string
+	Error         string
+	Matched       bool // body matched RESPONSE_PATTERN, or no pattern was set
+	InFlight      int  // requests in flight when the attempt completed, itself included
+	Phases        Phases
+	Checks        []CheckOutcome `json:",omitempty"`
+}
+
+// Success reports whether the attempt produced an acceptable response.
+func (s Sample) Success() bool {
+	return s.ErrorClass == ErrorNone
+}
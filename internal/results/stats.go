@@ -0,0 +1,274 @@
+package results
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// LatencyStats summarizes a set of response times in seconds.
+type LatencyStats struct {
+	Count   int
+	Min     float64
+	Max     float64
+	Average float64
+	Median  float64
+	P90     float64
+	StdDev  float64
+}
+
+func latencyStats(h *Histogram) LatencyStats {
+	return LatencyStats{
+		Count:   int(h.Count()),
+		Min:     h.Min(),
+		Max:     h.Max(),
+		Average: h.Mean(),
+		Median:  h.Percentile(50),
+		P90:     h.Percentile(90),
+		StdDev:  h.StdDev(),
+	}
+}
+
+// Begin fixes the start of the measured run. Timeline buckets are measured
+// from it, so they do not depend on which sample happens to be added first.
+// Without it the run starts with the first measured request.
+func (r *Results) Begin(start time.Time) {
+	r.StartTime = start
+}
+
+// Add folds a sample into the running statistics. Samples fall into four
+// disjoint groups:
+//   - warmup samples, which are only counted in WarmupRequests;
+//   - retried attempts, which are only counted in RetriedAttempts;
+//   - the final attempt of a successful request, which feeds the success
+//     latency statistics;
+//   - the final attempt of a failed request, which feeds ErrorLatency.
+//
+// At most the configured number of raw samples is retained (chosen by
+// reservoir sampling), so memory stays bounded on long runs while the
+// statistics still cover every sample. Final attempts of named samples are
+// also broken down per endpoint, check outcomes are counted per check and,
+// when a bucket size is set, final attempts are bucketed into the Timeline.
+func (r *Results) Add(sample Sample) {
+	r.histograms()
+
+	if sample.Warmup {
+		if !sample.Retried {
+			r.WarmupRequests++
+		}
+		return
+	}
+
+	r.retain(sample)
+	r.TotalAttempts++
+	if sample.Retried {
+		r.RetriedAttempts++
+		return
+	}
+
+	r.TotalRequests++
+	if sample.Attempt > 1 {
+		r.RetriedRequests++
+	}
+	if r.timeline != nil {
+		if r.StartTime.IsZero() {
+			r.StartTime = sample.Start // Begin was not called
+		}
+		end := sampleEnd(sample)
+		r.timeline.add(sample, end.Sub(r.StartTime), end, r.reportedPercentiles())
+	}
+	if sample.Name != "" {
+		r.endpoint(sample.Name).add(sample)
+		for _, tag := range r.endpointTags[sample.Name] {
+			r.tags[tag].add(sample)
+		}
+	}
+	for _, outcome := range sample.Checks {
+		c := r.check(sample.Name, outcome.Name)
+		if outcome.Passed {
+			c.Passed++
+		} else {
+			c.Failed++
+		}
+	}
+
+	if !sample.Success() {
+		r.FailedRequests++
+		if r.FailuresByClass == nil {
+			r.FailuresByClass = make(map[string]int)
+		}
+		r.FailuresByClass[sample.ErrorClass]++
+		r.errorLatency.Record(sample.Duration)
+		return
+	}
+
+	r.SuccessfulRequests++
+	r.Latency.Record(sample.Duration)
+	for i, value := range sample.Phases.values() {
+		if value > 0 {
+			r.phaseLatency[i].Record(value)
+		}
+	}
+}
+
+func (r *Results) retain(sample Sample) {
+	r.TotalSamples++
+	if r.maxSamples < 0 {
+		return // a window summary, which has the samples already
+	}
+	if r.maxSamples == 0 || len(r.Samples) < r.maxSamples {
+		r.Samples = append(r.Samples, sample)
+		return
+	}
+	if j := rand.Intn(r.TotalSamples); j < r.maxSamples {
+		r.Samples[j] = sample
+	}
+}
+
+// histograms allocates the latency histograms on first use so that a zero
+// Results value is ready to use.
+func (r *Results) histograms() {
+	if r.Latency == nil {
+		r.Latency = NewHistogram()
+	}
+	if r.errorLatency == nil {
+		r.errorLatency = NewHistogram()
+	}
+	if r.phaseLatency == nil {
+		r.phaseLatency = make([]*Histogram, len(PhaseNames))
+		for i := range r.phaseLatency {
+			r.phaseLatency[i] = NewHistogram()
+		}
+	}
+}
+
+// Summarize derives the reported statistics from the samples added so far.
+// The top level response time fields describe successful requests only.
+func (r *Results) Summarize() {
+	r.histograms()
+	sort.SliceStable(r.Samples, func(i, j int) bool {
+		return r.Samples[i].Start.Before(r.Samples[j].Start)
+	})
+
+	success := latencyStats(r.Latency)
+	r.MinTime = success.Min
+	r.MaxTime = success.Max
+	r.MedianTime = success.Median
+	r.PercentileTime90 = success.P90
+	r.AverageTime = success.Average
+	r.StdDevTime = success.StdDev
+	r.ErrorLatency = latencyStats(r.errorLatency)
+
+	r.AttemptsPerRequest = 0
+	if r.TotalRequests > 0 {
+		r.AttemptsPerRequest = float64(r.TotalAttempts) / float64(r.TotalRequests)
+	}
+
+	percentiles := r.reportedPercentiles()
+	corrected := r.Latency.CorrectedCopy(r.correctionInterval())
+	r.CorrectedRequests = int(corrected.Count())
+	r.Percentiles = make([]Percentile, len(percentiles))
+	r.CorrectedPercentiles = make([]Percentile, len(percentiles))
+	for i, p := range percentiles {
+		r.Percentiles[i] = Percentile{Percentile: p, Value: r.Latency.Percentile(p)}
+		r.CorrectedPercentiles[i] = Percentile{Percentile: p, Value: corrected.Percentile(p)}
+	}
+
+	if len(r.endpointOrder) > 0 {
+		r.Endpoints = make([]EndpointStats, len(r.endpointOrder))
+		for i, name := range r.endpointOrder {
+			r.Endpoints[i] = r.endpoints[name].summarize(percentiles, r.TotalRequests)
+		}
+	}
+
+	if len(r.tagOrder) > 0 {
+		r.Tags = make([]EndpointStats, len(r.tagOrder))
+		for i, tag := range r.tagOrder {
+			r.Tags[i] = r.tags[tag].summarize(percentiles, r.TotalRequests)
+		}
+	}
+
+	if len(r.checkOrder) > 0 {
+		r.Checks = make([]CheckStats, len(r.checkOrder))
+		for i, key := range r.checkOrder {
+			r.Checks[i] = *r.checks[key]
+		}
+	}
+
+	if r.timeline != nil {
+		r.Timeline = r.timeline.finish(r.StartTime, percentiles)
+	}
+
+	r.Phases = make([]PhaseStats, len(PhaseNames))
+	for i, name := range PhaseNames {
+		ph := r.phaseLatency[i]
+		r.Phases[i] = PhaseStats{
+			Name:   name,
+			Count:  int(ph.Count()),
+			Min:    ph.Min(),
+			Median: ph.Percentile(50),
+			P90:    ph.Percentile(90),
+			P99:    ph.Percentile(99),
+		}
+	}
+}
+
+// reportedPercentiles returns the configured percentiles, or the default
+// ones.
+func (r *Results) reportedPercentiles() []float64 {
+	if len(r.percentiles) == 0 {
+		return DefaultPercentiles
+	}
+	return r.percentiles
+}
+
+// correctionInterval returns the expected interval between two requests of
+// the same worker used for coordinated omission correction. Without an
+// explicit ExpectedInterval, closed-model runs assume a worker normally
+// sends its next request after a median response time. Open-model runs
+// (a target arrival rate) schedule requests independently of responses, so
+// they only need a correction when arrivals were dropped because too many
+// requests were in flight; those arrivals were due every 1/TargetRate.
+func (r *Results) correctionInterval() float64 {
+	if r.ExpectedInterval > 0 {
+		return r.ExpectedInterval
+	}
+	if r.TargetRate > 0 {
+		if r.DroppedArrivals == 0 {
+			return 0
+		}
+		r.ExpectedInterval = 1 / r.TargetRate
+		return r.ExpectedInterval
+	}
+	r.ExpectedInterval = r.Latency.Percentile(50)
+	return r.ExpectedInterval
+}
+
+// SuccessRate returns the percentage of requests that succeeded.
+func (r *Results) SuccessRate() float64 {
+	if r.TotalRequests == 0 {
+		return 0
+	}
+	return float64(r.SuccessfulRequests) / float64(r.TotalRequests) * 100
+}
+
+// LatencyDistribution returns the cumulative distribution of successful
+// response times.
+func (r *Results) LatencyDistribution() []HistogramPoint {
+	if r.Latency == nil {
+		return nil
+	}
+	return r.Latency.Distribution()
+}
+
+// SuccessfulTimes returns the durations of the retained successful requests
+// in the order they started.
+func (r *Results) SuccessfulTimes() []float64 {
+	times := make([]float64, 0, len(r.Samples))
+	for _, sample := range r.Samples {
+		if !sample.Retried && sample.Success() {
+			times = append(times, sample.Duration)
+		}
+	}
+	return times
+}
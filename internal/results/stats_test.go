@@ -0,0 +1,192 @@
+package results
+
+import (
+	"testing"
+)
+
+func succeeded(duration float64) Sample {
+	return Sample{Duration: duration, Attempt: 1, StatusCode: 200, Matched: true}
+}
+
+func failed(duration float64, class string) Sample {
+	return Sample{Duration: duration, Attempt: 1, ErrorClass: class, Error: class}
+}
+
+// retried marks s as an attempt that failed with a network error and was
+// followed by another one.
+func retried(duration float64) Sample {
+	s := failed(duration, ErrorNetwork)
+	s.Retried = true
+	return s
+}
+
+func attempt(s Sample, n int) Sample {
+	s.Attempt = n
+	return s
+}
+
+func warmup(s Sample) Sample {
+	s.Warmup = true
+	return s
+}
+
+func TestAddSeparatesSamples(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []Sample
+
+		successful, failed, warmup int
+		totalAttempts, retried     int
+		attemptsPerRequest         float64
+		failuresByClass            map[string]int
+		success                    LatencyStats // Count, Min, Max, Average and Median
+		errors                     LatencyStats
+	}{
+		{
+			name:               "successes only",
+			samples:            []Sample{succeeded(0.1), succeeded(0.2), succeeded(0.3)},
+			successful:         3,
+			totalAttempts:      3,
+			attemptsPerRequest: 1,
+			success:            LatencyStats{Count: 3, Min: 0.1, Max: 0.3, Average: 0.2, Median: 0.2},
+		},
+		{
+			name:               "failures feed the error latency only",
+			samples:            []Sample{succeeded(0.1), failed(5, ErrorStatus), succeeded(0.3), failed(3, ErrorTimeout)},
+			successful:         2,
+			failed:             2,
+			totalAttempts:      4,
+			attemptsPerRequest: 1,
+			failuresByClass:    map[string]int{ErrorStatus: 1, ErrorTimeout: 1},
+			success:            LatencyStats{Count: 2, Min: 0.1, Max: 0.3, Average: 0.2, Median: 0.1},
+			errors:             LatencyStats{Count: 2, Min: 3, Max: 5, Average: 4, Median: 3},
+		},
+		{
+			name: "retried attempts are only counted as attempts",
+			samples: []Sample{
+				retried(2), retried(2), attempt(succeeded(0.2), 3),
+				retried(1), attempt(failed(0.4, ErrorNetwork), 2),
+				succeeded(0.4),
+			},
+			successful:         2,
+			failed:             1,
+			totalAttempts:      6,
+			retried:            3,
+			attemptsPerRequest: 2,
+			failuresByClass:    map[string]int{ErrorNetwork: 1},
+			success:            LatencyStats{Count: 2, Min: 0.2, Max: 0.4, Average: 0.3, Median: 0.2},
+			errors:             LatencyStats{Count: 1, Min: 0.4, Max: 0.4, Average: 0.4, Median: 0.4},
+		},
+		{
+			name: "warmup samples are only counted as warmup",
+			samples: []Sample{
+				warmup(succeeded(9)), warmup(retried(9)), warmup(attempt(failed(9, ErrorStatus), 2)),
+				succeeded(0.5),
+			},
+			successful:         1,
+			warmup:             2,
+			totalAttempts:      1,
+			attemptsPerRequest: 1,
+			success:            LatencyStats{Count: 1, Min: 0.5, Max: 0.5, Average: 0.5, Median: 0.5},
+		},
+		{
+			name:               "every request fails",
+			samples:            []Sample{failed(0.2, ErrorStatus), retried(1), attempt(failed(0.6, ErrorNetwork), 2)},
+			failed:             2,
+			totalAttempts:      3,
+			retried:            1,
+			attemptsPerRequest: 1.5,
+			failuresByClass:    map[string]int{ErrorStatus: 1, ErrorNetwork: 1},
+			errors:             LatencyStats{Count: 2, Min: 0.2, Max: 0.6, Average: 0.4, Median: 0.2},
+		},
+		{
+			name: "no samples",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(nil, 0, 0)
+			for _, s := range tt.samples {
+				r.Add(s)
+			}
+			r.Summarize()
+
+			counts := []struct {
+				name      string
+				got, want int
+			}{
+				{"SuccessfulRequests", r.SuccessfulRequests, tt.successful},
+				{"FailedRequests", r.FailedRequests, tt.failed},
+				{"TotalRequests", r.TotalRequests, tt.successful + tt.failed},
+				{"WarmupRequests", r.WarmupRequests, tt.warmup},
+				{"TotalAttempts", r.TotalAttempts, tt.totalAttempts},
+				{"RetriedAttempts", r.RetriedAttempts, tt.retried},
+			}
+			for _, c := range counts {
+				if c.got != c.want {
+					t.Errorf("%s = %d, want %d", c.name, c.got, c.want)
+				}
+			}
+			if r.AttemptsPerRequest != tt.attemptsPerRequest {
+				t.Errorf("AttemptsPerRequest = %g, want %g", r.AttemptsPerRequest, tt.attemptsPerRequest)
+			}
+			for class, want := range tt.failuresByClass {
+				if got := r.FailuresByClass[class]; got != want {
+					t.Errorf("FailuresByClass[%s] = %d, want %d", class, got, want)
+				}
+			}
+			if len(r.FailuresByClass) != len(tt.failuresByClass) {
+				t.Errorf("FailuresByClass = %v, want %v", r.FailuresByClass, tt.failuresByClass)
+			}
+
+			success := LatencyStats{Count: r.SuccessfulRequests, Min: r.MinTime, Max: r.MaxTime, Average: r.AverageTime, Median: r.MedianTime}
+			checkLatency(t, "success latency", success, tt.success)
+			checkLatency(t, "ErrorLatency", r.ErrorLatency, tt.errors)
+			if tt.successful == 0 {
+				for _, p := range r.Percentiles {
+					if p.Value != 0 {
+						t.Errorf("%s = %g without successful requests, want 0", p.Label(), p.Value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func checkLatency(t *testing.T, what string, got, want LatencyStats) {
+	t.Helper()
+	if got.Count != want.Count {
+		t.Errorf("%s count = %d, want %d", what, got.Count, want.Count)
+	}
+	for _, v := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"min", got.Min, want.Min},
+		{"max", got.Max, want.Max},
+		{"average", got.Average, want.Average},
+		{"median", got.Median, want.Median},
+	} {
+		if !withinPrecision(v.got, v.want) {
+			t.Errorf("%s %s = %g, want %g", what, v.name, v.got, v.want)
+		}
+	}
+}
+
+func TestAddRetainsAtMostMaxSamples(t *testing.T) {
+	r := New(nil, 10, 0)
+	for i := 0; i < 1000; i++ {
+		r.Add(succeeded(0.1))
+	}
+	r.Add(warmup(succeeded(0.1)))
+	if len(r.Samples) != 10 {
+		t.Errorf("kept %d samples, want 10", len(r.Samples))
+	}
+	if r.TotalSamples != 1000 {
+		t.Errorf("TotalSamples = %d, want 1000", r.TotalSamples)
+	}
+	if r.SuccessfulRequests != 1000 {
+		t.Errorf("SuccessfulRequests = %d, want 1000", r.SuccessfulRequests)
+	}
+}
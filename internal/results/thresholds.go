@@ -0,0 +1,20 @@
+package results
+
+// ThresholdResult is the outcome of a threshold evaluated on the results.
+type ThresholdResult struct {
+	Threshold string
+	Actual    string // measured value, in the unit of the threshold
+	Passed    bool
+	Error     string `json:",omitempty"` // why the value could not be measured
+}
+
+// FailedThresholds returns the number of thresholds that did not pass.
+func (r *Results) FailedThresholds() int {
+	failed := 0
+	for _, t := range r.Thresholds {
+		if !t.Passed {
+			failed++
+		}
+	}
+	return failed
+}
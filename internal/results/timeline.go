@@ -0,0 +1,109 @@
+package results
+
+import (
+	"time"
+)
+
+// timelineSignificantFigures is the precision of the per-bucket latency
+// histograms, which keeps their percentiles within 1%.
+const timelineSignificantFigures = 2
+
+// TimelineBucket summarizes the requests that completed during one bucket
+// of the run. Start is in seconds since Results.StartTime. Latency covers
+// successful requests only.
+type TimelineBucket struct {
+	Start       float64
+	Requests    int
+	Failed      int
+	Throughput  float64 // requests per second
+	ErrorRate   float64 // percent of the bucket's requests that failed
+	InFlight    int     // most requests in flight when one of them completed
+	Average     float64
+	Percentiles []Percentile
+}
+
+// timeline accumulates the buckets. Samples reach the collector about in
+// the order they complete, so only the latest buckets keep a histogram;
+// older ones are summarized as soon as a later bucket opens.
+type timeline struct {
+	size    time.Duration
+	buckets []TimelineBucket
+	latency []*Histogram // nil once the bucket is summarized
+	sum     []float64
+	lastEnd time.Time
+}
+
+// add counts a sample that completed at end, elapsed after the run started.
+func (t *timeline) add(sample Sample, elapsed time.Duration, end time.Time, percentiles []float64) {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	index := int(elapsed / t.size)
+	for len(t.buckets) <= index {
+		t.buckets = append(t.buckets, TimelineBucket{Start: (time.Duration(len(t.buckets)) * t.size).Seconds()})
+		t.latency = append(t.latency, newHistogram(timelineSignificantFigures))
+		t.sum = append(t.sum, 0)
+	}
+	// Keep one bucket of slack for samples that are passed on a little
+	// out of order.
+	for i := index - 2; i >= 0 && t.latency[i] != nil; i-- {
+		t.summarize(i, percentiles)
+	}
+	if end.After(t.lastEnd) {
+		t.lastEnd = end
+	}
+
+	b := &t.buckets[index]
+	b.Requests++
+	b.InFlight = max(b.InFlight, sample.InFlight)
+	if !sample.Success() {
+		b.Failed++
+		return
+	}
+	// A sample for a bucket already summarized is still counted above but
+	// too late for its latency.
+	if h := t.latency[index]; h != nil {
+		h.Record(sample.Duration)
+		t.sum[index] += sample.Duration
+	}
+}
+
+// summarize derives the statistics of bucket i and drops its histogram.
+func (t *timeline) summarize(i int, percentiles []float64) {
+	h := t.latency[i]
+	if h == nil {
+		return
+	}
+	b := &t.buckets[i]
+	if h.Count() > 0 {
+		b.Average = t.sum[i] / float64(h.Count())
+	}
+	b.Percentiles = make([]Percentile, len(percentiles))
+	for j, p := range percentiles {
+		b.Percentiles[j] = Percentile{Percentile: p, Value: h.Percentile(p)}
+	}
+	t.latency[i] = nil
+}
+
+// finish summarizes every bucket. The last bucket only covers the run up
+// to the last completed request, which its throughput accounts for once it
+// covers half a bucket; a shorter tail is usually just the requests still in
+// flight when the run ended, and dividing them by a sliver of time would
+// show a spike.
+func (t *timeline) finish(start time.Time, percentiles []float64) []TimelineBucket {
+	for i := range t.buckets {
+		t.summarize(i, percentiles)
+		b := &t.buckets[i]
+		span := t.size.Seconds()
+		if i == len(t.buckets)-1 {
+			if covered := t.lastEnd.Sub(start).Seconds() - b.Start; covered >= span/2 && covered < span {
+				span = covered
+			}
+		}
+		b.Throughput = float64(b.Requests) / span
+		if b.Requests > 0 {
+			b.ErrorRate = float64(b.Failed) / float64(b.Requests) * 100
+		}
+	}
+	return t.buckets
+}
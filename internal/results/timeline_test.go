@@ -0,0 +1,112 @@
+package results
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// at returns a sample that started offset seconds after base and took
+// duration seconds.
+func at(base time.Time, offset float64, s Sample) Sample {
+	s.Start = base.Add(time.Duration(offset * float64(time.Second)))
+	return s
+}
+
+func TestTimelineBuckets(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	type bucket struct {
+		requests, failed int
+		throughput       float64
+	}
+	tests := []struct {
+		name    string
+		samples []Sample // in the order they are added
+		want    []bucket
+	}{
+		{
+			name: "buckets by completion since the run started",
+			samples: []Sample{
+				// Added first but completes after the next one.
+				at(base, 0.5, succeeded(0.7)),
+				at(base, 0.2, succeeded(0.1)),
+				at(base, 1.0, failed(0.5, ErrorStatus)),
+				at(base, 2.6, succeeded(0.2)),
+			},
+			// The last bucket covers 0.8s up to the last completion.
+			want: []bucket{{1, 0, 1}, {2, 1, 2}, {1, 0, 1 / 0.8}},
+		},
+		{
+			name: "short tail is divided by the whole bucket",
+			samples: []Sample{
+				at(base, 0.2, succeeded(0.1)),
+				at(base, 1.1, succeeded(0.1)),
+				at(base, 1.4, succeeded(0.1)),
+				at(base, 2.1, succeeded(0.2)),
+			},
+			want: []bucket{{1, 0, 1}, {2, 0, 2}, {1, 0, 1}},
+		},
+		{
+			name:    "late first response",
+			samples: []Sample{at(base, 2.4, succeeded(0.2)), at(base, 2.5, retried(0.1))},
+			want:    []bucket{{0, 0, 0}, {0, 0, 0}, {1, 0, 1 / 0.6}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(nil, 0, time.Second)
+			r.Begin(base)
+			// A warmup request before the run started is left out.
+			r.Add(at(base, -1, warmup(succeeded(0.5))))
+			for _, s := range tt.samples {
+				r.Add(s)
+			}
+			r.Summarize()
+
+			if !r.StartTime.Equal(base) {
+				t.Errorf("StartTime = %v, want the run start %v", r.StartTime, base)
+			}
+			if len(r.Timeline) != len(tt.want) {
+				t.Fatalf("%d buckets, want %d: %+v", len(r.Timeline), len(tt.want), r.Timeline)
+			}
+			for i, want := range tt.want {
+				got := r.Timeline[i]
+				if got.Start != float64(i) {
+					t.Errorf("bucket %d starts at %gs", i, got.Start)
+				}
+				if got.Requests != want.requests || got.Failed != want.failed {
+					t.Errorf("bucket %d: %d requests, %d failed, want %d, %d", i, got.Requests, got.Failed, want.requests, want.failed)
+				}
+				if math.Abs(got.Throughput-want.throughput) > 1e-9 {
+					t.Errorf("bucket %d throughput = %g, want %g", i, got.Throughput, want.throughput)
+				}
+			}
+		})
+	}
+}
+
+func TestTimelineErrorRateAndLatency(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	r := New([]float64{50}, 0, time.Second)
+	r.Begin(base)
+	r.Add(at(base, 0.1, succeeded(0.2)))
+	r.Add(at(base, 0.2, succeeded(0.4)))
+	r.Add(at(base, 0.3, failed(0.1, ErrorTimeout)))
+	r.Add(at(base, 0.4, failed(0.1, ErrorStatus)))
+	r.Summarize()
+
+	if len(r.Timeline) != 1 {
+		t.Fatalf("%d buckets, want 1", len(r.Timeline))
+	}
+	b := r.Timeline[0]
+	if b.ErrorRate != 50 {
+		t.Errorf("ErrorRate = %g, want 50", b.ErrorRate)
+	}
+	if !withinPrecision(b.Average, 0.3) {
+		t.Errorf("Average = %g, want 0.3 from the successful requests", b.Average)
+	}
+	if p50 := percentile(b.Percentiles, 50); math.Abs(p50-0.2) > 0.2*0.01 {
+		t.Errorf("P50 = %g, want 0.2", p50)
+	}
+}
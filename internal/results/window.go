@@ -0,0 +1,261 @@
+package results
+
+import (
+	"time"
+)
+
+// windowSignificantFigures is the precision of the window's latency
+// histograms, which keeps the percentiles thresholds are judged on within
+// 1%.
+const windowSignificantFigures = 2
+
+// Window keeps the statistics of the samples that completed within the last
+// Length of a run, so thresholds can be judged over a sliding window while
+// the run is in progress. Samples are counted in one bucket per second of
+// completion, and a summary merges the buckets inside the window, so its
+// cost does not grow with the request rate. It is not safe for concurrent
+// use.
+type Window struct {
+	Length time.Duration
+
+	percentiles   []float64
+	endpointOrder []string
+	declared      map[string]bool
+	tagOrder      []string
+	endpointTags  map[string][]string
+
+	buckets []*windowBucket // oldest first
+	first   time.Time
+}
+
+// windowBucket counts the samples that completed during one second.
+type windowBucket struct {
+	second     time.Time
+	all        *endpoint
+	endpoints  map[string]*endpoint
+	tags       map[string]*endpoint
+	checks     map[checkKey]*CheckStats
+	checkOrder []checkKey
+}
+
+// NewWindow returns an empty window reporting the same percentiles,
+// endpoints and tags as r. Call it after the endpoints and tags are
+// declared.
+func (r *Results) NewWindow(length time.Duration) *Window {
+	w := &Window{
+		Length:        length,
+		percentiles:   r.reportedPercentiles(),
+		endpointOrder: append([]string(nil), r.endpointOrder...),
+		declared:      make(map[string]bool),
+		tagOrder:      r.tagOrder,
+		endpointTags:  r.endpointTags,
+	}
+	for _, name := range w.endpointOrder {
+		w.declared[name] = true
+	}
+	return w
+}
+
+// Add counts the final attempt of a request. Warmup samples and retried
+// attempts are ignored.
+func (w *Window) Add(sample Sample) {
+	if sample.Warmup || sample.Retried {
+		return
+	}
+	end := sampleEnd(sample)
+	if w.first.IsZero() {
+		w.first = end
+	}
+	if sample.Name != "" && !w.declared[sample.Name] {
+		w.declared[sample.Name] = true
+		w.endpointOrder = append(w.endpointOrder, sample.Name)
+	}
+	w.bucket(end.Truncate(time.Second)).add(sample, w.endpointTags)
+}
+
+// Started returns when the first sample was added, or the zero time.
+func (w *Window) Started() time.Time {
+	return w.first
+}
+
+// Summarize drops the buckets of the seconds that ended before now minus
+// Length and returns the statistics of the others. Throughput is measured
+// over the time they cover, or since the first sample while the run is
+// younger than the window.
+func (w *Window) Summarize(now time.Time) Results {
+	cutoff := now.Add(-w.Length)
+	w.drop(cutoff)
+
+	total := newWindowBucket(time.Time{})
+	for _, name := range w.endpointOrder {
+		total.endpoint(total.endpoints, name)
+	}
+	for _, tag := range w.tagOrder {
+		total.endpoint(total.tags, tag)
+	}
+	for _, b := range w.buckets {
+		total.merge(b)
+	}
+
+	all := total.all.summarize(w.percentiles, 0)
+	r := Results{
+		percentiles:        w.percentiles,
+		maxSamples:         -1,
+		TotalRequests:      all.TotalRequests,
+		SuccessfulRequests: all.SuccessfulRequests,
+		FailedRequests:     all.FailedRequests,
+		FailuresByClass:    all.FailuresByClass,
+		MinTime:            all.Latency.Min,
+		MaxTime:            all.Latency.Max,
+		MedianTime:         all.Latency.Median,
+		PercentileTime90:   all.Latency.P90,
+		AverageTime:        all.Latency.Average,
+		StdDevTime:         all.Latency.StdDev,
+		Percentiles:        all.Percentiles,
+		Latency:            total.all.latency,
+	}
+	for _, name := range w.endpointOrder {
+		stats := total.endpoints[name].summarize(w.percentiles, r.TotalRequests)
+		stats.Tags = w.endpointTags[name]
+		r.Endpoints = append(r.Endpoints, stats)
+	}
+	for _, tag := range w.tagOrder {
+		r.Tags = append(r.Tags, total.tags[tag].summarize(w.percentiles, r.TotalRequests))
+	}
+	for _, key := range total.checkOrder {
+		r.Checks = append(r.Checks, *total.checks[key])
+	}
+
+	start := cutoff
+	if len(w.buckets) > 0 && w.buckets[0].second.Before(start) {
+		start = w.buckets[0].second
+	}
+	if w.first.After(start) {
+		start = w.first
+	}
+	if elapsed := now.Sub(start).Seconds(); elapsed > 0 {
+		r.TotalDuration = elapsed
+		r.Throughput = float64(r.TotalRequests) / elapsed
+		for i := range r.Endpoints {
+			r.Endpoints[i].Throughput = float64(r.Endpoints[i].TotalRequests) / elapsed
+		}
+		for i := range r.Tags {
+			r.Tags[i].Throughput = float64(r.Tags[i].TotalRequests) / elapsed
+		}
+	}
+	return r
+}
+
+// bucket returns the bucket of second, adding it in order when it is new.
+// Samples reach the collector about in the order they complete, so the
+// bucket is almost always the last one.
+func (w *Window) bucket(second time.Time) *windowBucket {
+	i := len(w.buckets)
+	for i > 0 && second.Before(w.buckets[i-1].second) {
+		i--
+	}
+	if i > 0 && w.buckets[i-1].second.Equal(second) {
+		return w.buckets[i-1]
+	}
+	b := newWindowBucket(second)
+	w.buckets = append(w.buckets, nil)
+	copy(w.buckets[i+1:], w.buckets[i:])
+	w.buckets[i] = b
+	// A new second is a good time to forget the ones that left the window,
+	// even when nothing summarizes it yet.
+	w.drop(second.Add(-w.Length))
+	return b
+}
+
+// drop forgets the buckets of the seconds that ended before cutoff.
+func (w *Window) drop(cutoff time.Time) {
+	n := 0
+	for n < len(w.buckets) && !w.buckets[n].second.Add(time.Second).After(cutoff) {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	// Copy the kept buckets down so the backing array does not grow for
+	// the whole run.
+	kept := copy(w.buckets, w.buckets[n:])
+	for i := kept; i < len(w.buckets); i++ {
+		w.buckets[i] = nil
+	}
+	w.buckets = w.buckets[:kept]
+}
+
+func newWindowBucket(second time.Time) *windowBucket {
+	return &windowBucket{
+		second:    second,
+		all:       newWindowEndpoint(""),
+		endpoints: make(map[string]*endpoint),
+		tags:      make(map[string]*endpoint),
+		checks:    make(map[checkKey]*CheckStats),
+	}
+}
+
+func newWindowEndpoint(name string) *endpoint {
+	return &endpoint{stats: EndpointStats{Name: name}, latency: newHistogram(windowSignificantFigures)}
+}
+
+// endpoint returns the accumulator for name in list, which is the bucket's
+// endpoints or tags. Accumulators are only allocated for the endpoints and
+// tags that had requests during the second.
+func (b *windowBucket) endpoint(list map[string]*endpoint, name string) *endpoint {
+	e, ok := list[name]
+	if !ok {
+		e = newWindowEndpoint(name)
+		list[name] = e
+	}
+	return e
+}
+
+// check returns the counters of the check identified by key.
+func (b *windowBucket) check(key checkKey) *CheckStats {
+	c, ok := b.checks[key]
+	if !ok {
+		c = &CheckStats{Endpoint: key.endpoint, Name: key.name}
+		b.checks[key] = c
+		b.checkOrder = append(b.checkOrder, key)
+	}
+	return c
+}
+
+func (b *windowBucket) add(sample Sample, endpointTags map[string][]string) {
+	b.all.add(sample)
+	if sample.Name != "" {
+		b.endpoint(b.endpoints, sample.Name).add(sample)
+		for _, tag := range endpointTags[sample.Name] {
+			b.endpoint(b.tags, tag).add(sample)
+		}
+	}
+	for _, outcome := range sample.Checks {
+		c := b.check(checkKey{endpoint: sample.Name, name: outcome.Name})
+		if outcome.Passed {
+			c.Passed++
+		} else {
+			c.Failed++
+		}
+	}
+}
+
+// merge adds the requests counted by other to b.
+func (b *windowBucket) merge(other *windowBucket) {
+	b.all.merge(other.all)
+	for name, e := range other.endpoints {
+		b.endpoint(b.endpoints, name).merge(e)
+	}
+	for tag, e := range other.tags {
+		b.endpoint(b.tags, tag).merge(e)
+	}
+	for _, key := range other.checkOrder {
+		c, from := b.check(key), other.checks[key]
+		c.Passed += from.Passed
+		c.Failed += from.Failed
+	}
+}
+
+func sampleEnd(sample Sample) time.Time {
+	return sample.Start.Add(time.Duration(sample.Duration * float64(time.Second)))
+}
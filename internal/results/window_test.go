@@ -0,0 +1,173 @@
+package results
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// windowSamples returns n samples starting every 10ms from base, alternating
+// between a search and a login endpoint, with every seventh one failing.
+func windowSamples(base time.Time, n int) []Sample {
+	durations := latencies(n, 5)
+	samples := make([]Sample, n)
+	for i := range samples {
+		s := succeeded(math.Min(durations[i], 0.5))
+		if i%7 == 0 {
+			s = failed(durations[i], ErrorStatus)
+		}
+		s.Start = base.Add(time.Duration(i) * 10 * time.Millisecond)
+		s.Name = "search"
+		if i%2 == 1 {
+			s.Name = "login"
+		}
+		s.Checks = []CheckOutcome{{Name: "status", Passed: s.Success()}}
+		samples[i] = s
+	}
+	return samples
+}
+
+func declared(percentiles []float64) Results {
+	r := New(percentiles, 0, 0)
+	r.DeclareEndpoints([]string{"search", "login"})
+	r.DeclareTags("search", []string{"read"})
+	return r
+}
+
+// withinWindowPrecision reports whether got is within the precision of the
+// window's histograms of want.
+func withinWindowPrecision(got, want float64) bool {
+	return math.Abs(got-want) <= want*math.Pow10(-windowSignificantFigures)+histogramUnit
+}
+
+func TestWindowSummarizesOnlyRecentSamples(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	samples := windowSamples(base, 3000) // 30s of requests
+	now := base.Add(30 * time.Second)
+	length := 10 * time.Second
+
+	full := declared([]float64{50, 95, 99})
+	w := full.NewWindow(length)
+	expected := declared([]float64{50, 95, 99})
+	for _, s := range samples {
+		w.Add(s)
+		w.Add(warmup(s))
+		if !sampleEnd(s).Before(now.Add(-length)) {
+			expected.Add(s)
+		}
+	}
+	expected.Summarize()
+	got := w.Summarize(now)
+
+	counts := []struct {
+		name      string
+		got, want int
+	}{
+		{"TotalRequests", got.TotalRequests, expected.TotalRequests},
+		{"SuccessfulRequests", got.SuccessfulRequests, expected.SuccessfulRequests},
+		{"FailedRequests", got.FailedRequests, expected.FailedRequests},
+		{"FailuresByClass[status]", got.FailuresByClass[ErrorStatus], expected.FailuresByClass[ErrorStatus]},
+	}
+	for i := range expected.Endpoints {
+		counts = append(counts, struct {
+			name      string
+			got, want int
+		}{expected.Endpoints[i].Name, got.Endpoints[i].TotalRequests, expected.Endpoints[i].TotalRequests})
+	}
+	for i := range expected.Tags {
+		counts = append(counts, struct {
+			name      string
+			got, want int
+		}{"tag " + expected.Tags[i].Name, got.Tags[i].TotalRequests, expected.Tags[i].TotalRequests})
+	}
+	for i := range expected.Checks {
+		counts = append(counts, struct {
+			name      string
+			got, want int
+		}{"check " + expected.Checks[i].Endpoint, got.Checks[i].Failed, expected.Checks[i].Failed})
+	}
+	if expected.TotalRequests == 0 || expected.TotalRequests == len(samples) {
+		t.Fatalf("%d of %d samples in the window, the test needs some out of it", expected.TotalRequests, len(samples))
+	}
+	for _, c := range counts {
+		if c.got != c.want {
+			t.Errorf("%s = %d, want %d", c.name, c.got, c.want)
+		}
+	}
+	if len(got.Endpoints) != 2 || len(got.Tags) != 1 || len(got.Checks) != 2 {
+		t.Fatalf("%d endpoints, %d tags and %d checks, want 2, 1 and 2", len(got.Endpoints), len(got.Tags), len(got.Checks))
+	}
+	if got.Endpoints[0].Tags[0] != "read" {
+		t.Errorf("search endpoint tags = %v, want [read]", got.Endpoints[0].Tags)
+	}
+
+	for i, p := range expected.Percentiles {
+		if !withinWindowPrecision(got.Percentiles[i].Value, p.Value) {
+			t.Errorf("%s = %g, want %g", p.Label(), got.Percentiles[i].Value, p.Value)
+		}
+		if want := expected.Endpoints[0].Percentiles[i].Value; !withinWindowPrecision(got.Endpoints[0].Percentiles[i].Value, want) {
+			t.Errorf("search %s = %g, want %g", p.Label(), got.Endpoints[0].Percentiles[i].Value, want)
+		}
+	}
+	if got.MinTime != expected.MinTime || got.MaxTime != expected.MaxTime {
+		t.Errorf("min, max = %g, %g, want %g, %g", got.MinTime, got.MaxTime, expected.MinTime, expected.MaxTime)
+	}
+	if math.Abs(got.AverageTime-expected.AverageTime) > 1e-9 {
+		t.Errorf("AverageTime = %g, want %g", got.AverageTime, expected.AverageTime)
+	}
+	if want := float64(expected.TotalRequests) / length.Seconds(); math.Abs(got.Throughput-want) > 1e-9 {
+		t.Errorf("Throughput = %g, want %g", got.Throughput, want)
+	}
+	if len(w.buckets) > 11 {
+		t.Errorf("%d buckets kept for a 10s window", len(w.buckets))
+	}
+}
+
+func TestWindowYoungerThanItsLength(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	r := New(nil, 0, 0)
+	w := r.NewWindow(30 * time.Second)
+	if !w.Started().IsZero() {
+		t.Errorf("an empty window started at %v", w.Started())
+	}
+	for _, s := range windowSamples(base, 400) {
+		w.Add(s)
+	}
+	if !w.Started().Equal(sampleEnd(windowSamples(base, 1)[0])) {
+		t.Errorf("Started() = %v, want the end of the first sample", w.Started())
+	}
+	now := w.Started().Add(5 * time.Second)
+	got := w.Summarize(now)
+	if want := float64(400) / 5; got.TotalRequests != 400 || math.Abs(got.Throughput-want) > 1e-9 {
+		t.Errorf("%d requests at %g/s, want 400 at %g/s since the first sample", got.TotalRequests, got.Throughput, want)
+	}
+}
+
+func TestWindowOutOfOrderSamples(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	r := New(nil, 0, 0)
+	w := r.NewWindow(10 * time.Second)
+	late := succeeded(0.1)
+	late.Start = base.Add(3 * time.Second)
+	for _, offset := range []time.Duration{2, 5, 4, 1, 5} {
+		s := succeeded(0.1)
+		s.Start = base.Add(offset * time.Second)
+		w.Add(s)
+	}
+	w.Add(late)
+	for i := 1; i < len(w.buckets); i++ {
+		if !w.buckets[i-1].second.Before(w.buckets[i].second) {
+			t.Fatalf("buckets out of order at %d: %v after %v", i, w.buckets[i].second, w.buckets[i-1].second)
+		}
+	}
+	if len(w.buckets) != 5 {
+		t.Errorf("%d buckets, want one for each of 5 distinct seconds", len(w.buckets))
+	}
+	if got := w.Summarize(base.Add(6 * time.Second)); got.TotalRequests != 6 {
+		t.Errorf("TotalRequests = %d, want 6", got.TotalRequests)
+	}
+	// At 14.5s, the seconds that ended before 4.5s have left the window.
+	if got := w.Summarize(base.Add(14500 * time.Millisecond)); got.TotalRequests != 3 {
+		t.Errorf("TotalRequests = %d after the window moved, want 3", got.TotalRequests)
+	}
+}
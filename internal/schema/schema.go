@@ -0,0 +1,369 @@
+// Package schema validates decoded JSON values against the subset of JSON
+// Schema used by OpenAPI 3 response definitions.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Schema is a compiled JSON Schema. Supported keywords are type (a string or
+// a list), nullable, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf,
+// anyOf and oneOf. References must be resolved before compiling; other
+// keywords, such as format, are ignored.
+type Schema struct {
+	never            bool // the schema false
+	types            []string
+	nullable         bool
+	enum             []interface{}
+	properties       map[string]*Schema
+	required         []string
+	additional       *Schema
+	noAdditional     bool
+	items            *Schema
+	minItems         *float64
+	maxItems         *float64
+	minLength        *float64
+	maxLength        *float64
+	pattern          *regexp.Regexp
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	allOf            []*Schema
+	anyOf            []*Schema
+	oneOf            []*Schema
+}
+
+// Compile builds a Schema from its decoded JSON or YAML form.
+func Compile(raw interface{}) (*Schema, error) {
+	return compile(raw, "$")
+}
+
+func compile(raw interface{}, path string) (*Schema, error) {
+	if b, ok := raw.(bool); ok {
+		return &Schema{never: !b}, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object", path)
+	}
+	if _, ok := m["$ref"]; ok {
+		return nil, fmt.Errorf("%s: $ref is not supported, inline the referenced schema", path)
+	}
+
+	s := &Schema{}
+	var err error
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s.type: expected strings", path)
+			}
+			if name == "null" {
+				s.nullable = true
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s.type: expected a string or a list", path)
+	}
+	if nullable, ok := m["nullable"].(bool); ok && nullable {
+		s.nullable = true
+	}
+
+	if enum, ok := m["enum"].([]interface{}); ok {
+		s.enum = enum
+	}
+	if value, ok := m["const"]; ok {
+		s.enum = []interface{}{value}
+	}
+
+	if props, ok := m["properties"].(map[string]interface{}); ok {
+		s.properties = make(map[string]*Schema, len(props))
+		for name, prop := range props {
+			if s.properties[name], err = compile(prop, path+".properties."+name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if required, ok := m["required"].([]interface{}); ok {
+		for _, item := range required {
+			if name, ok := item.(string); ok {
+				s.required = append(s.required, name)
+			}
+		}
+	}
+	switch additional := m["additionalProperties"].(type) {
+	case bool:
+		s.noAdditional = !additional
+	case map[string]interface{}:
+		if s.additional, err = compile(additional, path+".additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+	if items, ok := m["items"]; ok {
+		if s.items, err = compile(items, path+".items"); err != nil {
+			return nil, err
+		}
+	}
+
+	for keyword, target := range map[string]**float64{
+		"minItems": &s.minItems, "maxItems": &s.maxItems,
+		"minLength": &s.minLength, "maxLength": &s.maxLength,
+		"minimum": &s.minimum, "maximum": &s.maximum,
+		"exclusiveMinimum": &s.exclusiveMinimum, "exclusiveMaximum": &s.exclusiveMaximum,
+	} {
+		value, ok := m[keyword]
+		if !ok {
+			continue
+		}
+		if _, isBool := value.(bool); isBool {
+			continue
+		}
+		n, ok := number(value)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s: expected a number", path, keyword)
+		}
+		*target = &n
+	}
+
+	// OpenAPI 3.0 spells exclusive bounds as booleans that turn minimum and
+	// maximum exclusive.
+	if exclusive, _ := m["exclusiveMinimum"].(bool); exclusive {
+		s.exclusiveMinimum, s.minimum = s.minimum, nil
+	}
+	if exclusive, _ := m["exclusiveMaximum"].(bool); exclusive {
+		s.exclusiveMaximum, s.maximum = s.maximum, nil
+	}
+
+	if pattern, ok := m["pattern"].(string); ok {
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("%s.pattern: %v", path, err)
+		}
+	}
+
+	for keyword, target := range map[string]*[]*Schema{"allOf": &s.allOf, "anyOf": &s.anyOf, "oneOf": &s.oneOf} {
+		list, ok := m[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		for i, item := range list {
+			sub, err := compile(item, fmt.Sprintf("%s.%s[%d]", path, keyword, i))
+			if err != nil {
+				return nil, err
+			}
+			*target = append(*target, sub)
+		}
+	}
+	return s, nil
+}
+
+// ValidateJSON decodes data and validates it.
+func (s *Schema) ValidateJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("response is not JSON: %v", err)
+	}
+	return s.Validate(value)
+}
+
+// Validate reports the first place where value, as decoded by encoding/json,
+// does not match the schema.
+func (s *Schema) Validate(value interface{}) error {
+	return s.validate(value, "$")
+}
+
+func (s *Schema) validate(value interface{}, path string) error {
+	if s.never {
+		return fmt.Errorf("%s: no value is allowed here", path)
+	}
+	if value == nil && s.nullable {
+		return nil
+	}
+	if len(s.types) > 0 && !s.matchesType(value) {
+		return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(s.types, " or "), typeName(value))
+	}
+	if len(s.enum) > 0 && !contains(s.enum, value) {
+		return fmt.Errorf("%s: value is not one of the allowed values", path)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		for _, name := range sortedKeys(v) {
+			prop, ok := s.properties[name]
+			switch {
+			case ok:
+			case s.additional != nil:
+				prop = s.additional
+			case s.noAdditional:
+				return fmt.Errorf("%s: unexpected property %q", path, name)
+			default:
+				continue
+			}
+			if err := prop.validate(v[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		n := float64(len(v))
+		if s.minItems != nil && n < *s.minItems {
+			return fmt.Errorf("%s: expected at least %g items, got %d", path, *s.minItems, len(v))
+		}
+		if s.maxItems != nil && n > *s.maxItems {
+			return fmt.Errorf("%s: expected at most %g items, got %d", path, *s.maxItems, len(v))
+		}
+		if s.items != nil {
+			for i, item := range v {
+				if err := s.items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		n := float64(len([]rune(v)))
+		if s.minLength != nil && n < *s.minLength {
+			return fmt.Errorf("%s: expected at least %g characters", path, *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			return fmt.Errorf("%s: expected at most %g characters", path, *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return fmt.Errorf("%s: does not match pattern %s", path, s.pattern)
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			return fmt.Errorf("%s: %g is less than the minimum %g", path, v, *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			return fmt.Errorf("%s: %g is greater than the maximum %g", path, v, *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum {
+			return fmt.Errorf("%s: %g must be greater than %g", path, v, *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum {
+			return fmt.Errorf("%s: %g must be less than %g", path, v, *s.exclusiveMaximum)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		if err := sub.validate(value, path); err != nil {
+			return err
+		}
+	}
+	if len(s.anyOf) > 0 {
+		var first error
+		for _, sub := range s.anyOf {
+			err := sub.validate(value, path)
+			if err == nil {
+				first = nil
+				break
+			}
+			if first == nil {
+				first = err
+			}
+		}
+		if first != nil {
+			return fmt.Errorf("%s: matches none of anyOf (first: %v)", path, first)
+		}
+	}
+	if len(s.oneOf) > 0 {
+		matches := 0
+		for _, sub := range s.oneOf {
+			if sub.validate(value, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d of oneOf, expected exactly 1", path, matches)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	actual := typeName(value)
+	for _, t := range s.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// contains compares JSON values; enum entries decoded from YAML may hold
+// ints where encoding/json produces float64.
+func contains(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if n, ok := number(item); ok {
+			item = n
+		}
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,147 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func mustDecode(t *testing.T, text string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatalf("decoding %s: %v", text, err)
+	}
+	return value
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		keyword string
+		schema  string
+		value   string
+		err     string // "" when value is valid
+	}{
+		{"type", `{"type": "string"}`, `"a"`, ""},
+		{"type", `{"type": "string"}`, `1`, "$: expected string, got integer"},
+		{"type", `{"type": "integer"}`, `1.5`, "$: expected integer, got number"},
+		{"type", `{"type": "number"}`, `1`, ""},
+		{"type", `{"type": "object"}`, `[]`, "$: expected object, got array"},
+		{"type", `{"type": ["string", "null"]}`, `null`, ""},
+		{"type", `{"type": ["string", "null"]}`, `true`, "$: expected string or null, got boolean"},
+		{"nullable", `{"type": "string", "nullable": true}`, `null`, ""},
+		{"nullable", `{"type": "string"}`, `null`, "$: expected string, got null"},
+		{"enum", `{"enum": ["a", "b"]}`, `"b"`, ""},
+		{"enum", `{"enum": ["a", "b"]}`, `"c"`, "$: value is not one of the allowed values"},
+		{"const", `{"const": 3}`, `3`, ""},
+		{"const", `{"const": 3}`, `4`, "$: value is not one of the allowed values"},
+		{"properties", `{"properties": {"id": {"type": "integer"}}}`, `{"id": 1, "other": "x"}`, ""},
+		{"properties", `{"properties": {"id": {"type": "integer"}}}`, `{"id": "x"}`, "$.id: expected integer, got string"},
+		{"required", `{"required": ["id", "name"]}`, `{"id": 1, "name": "a"}`, ""},
+		{"required", `{"required": ["id", "name"]}`, `{"id": 1}`, `$: missing required property "name"`},
+		{"additionalProperties", `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1}`, ""},
+		{"additionalProperties", `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b": 2}`, `$: unexpected property "b"`},
+		{"additionalProperties", `{"additionalProperties": {"type": "string"}}`, `{"x": 1}`, "$.x: expected string, got integer"},
+		{"items", `{"items": {"type": "string"}}`, `["a", "b"]`, ""},
+		{"items", `{"items": {"type": "string"}}`, `["a", 1]`, "$[1]: expected string, got integer"},
+		{"minItems", `{"minItems": 2}`, `[1]`, "$: expected at least 2 items, got 1"},
+		{"maxItems", `{"maxItems": 1}`, `[1, 2]`, "$: expected at most 1 items, got 2"},
+		{"minLength", `{"minLength": 3}`, `"ab"`, "$: expected at least 3 characters"},
+		{"maxLength", `{"maxLength": 2}`, `"éé"`, ""},
+		{"maxLength", `{"maxLength": 2}`, `"abc"`, "$: expected at most 2 characters"},
+		{"pattern", `{"pattern": "^a+$"}`, `"aaa"`, ""},
+		{"pattern", `{"pattern": "^a+$"}`, `"b"`, "$: does not match pattern ^a+$"},
+		{"minimum", `{"minimum": 1}`, `1`, ""},
+		{"minimum", `{"minimum": 1}`, `0.5`, "$: 0.5 is less than the minimum 1"},
+		{"maximum", `{"maximum": 1}`, `2`, "$: 2 is greater than the maximum 1"},
+		{"exclusiveMinimum", `{"exclusiveMinimum": 1}`, `1`, "$: 1 must be greater than 1"},
+		{"exclusiveMaximum", `{"exclusiveMaximum": 1}`, `1`, "$: 1 must be less than 1"},
+		{"exclusiveMinimum", `{"minimum": 1, "exclusiveMinimum": true}`, `1`, "$: 1 must be greater than 1"},
+		{"exclusiveMaximum", `{"maximum": 5, "exclusiveMaximum": true}`, `4`, ""},
+		{"exclusiveMaximum", `{"maximum": 5, "exclusiveMaximum": false}`, `5`, ""},
+		{"allOf", `{"allOf": [{"required": ["a"]}, {"required": ["b"]}]}`, `{"a": 1, "b": 2}`, ""},
+		{"allOf", `{"allOf": [{"required": ["a"]}, {"required": ["b"]}]}`, `{"a": 1}`, `$: missing required property "b"`},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `1`, ""},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `true`, "$: matches none of anyOf (first: $: expected string, got boolean)"},
+		{"oneOf", `{"oneOf": [{"type": "integer"}, {"type": "string"}]}`, `"x"`, ""},
+		{"oneOf", `{"oneOf": [{"type": "integer"}, {"type": "number"}]}`, `1`, "$: matches 2 of oneOf, expected exactly 1"},
+		{"oneOf", `{"oneOf": [{"type": "integer"}, {"type": "number"}]}`, `"x"`, "$: matches 0 of oneOf, expected exactly 1"},
+		{"false", `{"properties": {"a": false}}`, `{"b": 1}`, ""},
+		{"false", `{"properties": {"a": false}}`, `{"a": 1}`, "$.a: no value is allowed here"},
+		{"true", `true`, `{"a": [1]}`, ""},
+		{"format", `{"type": "string", "format": "uuid"}`, `"not a uuid"`, ""},
+		{
+			"nested",
+			`{"properties": {"items": {"type": "array", "items": {"required": ["name"], "properties": {"name": {"type": "string"}}}}}}`,
+			`{"items": [{"name": "a"}, {"name": 2}]}`,
+			"$.items[1].name: expected string, got integer",
+		},
+	}
+	for _, tt := range tests {
+		s, err := Compile(mustDecode(t, tt.schema))
+		if err != nil {
+			t.Errorf("%s: Compile(%s): %v", tt.keyword, tt.schema, err)
+			continue
+		}
+		err = s.Validate(mustDecode(t, tt.value))
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %s rejected %s: %v", tt.keyword, tt.schema, tt.value, err)
+		case tt.err != "" && err == nil:
+			t.Errorf("%s: %s accepted %s, want %q", tt.keyword, tt.schema, tt.value, tt.err)
+		case tt.err != "" && err.Error() != tt.err:
+			t.Errorf("%s: %s on %s: %q, want %q", tt.keyword, tt.schema, tt.value, err, tt.err)
+		}
+	}
+}
+
+func TestValidateYAMLNumbers(t *testing.T) {
+	// Schemas decoded from YAML hold ints where JSON values hold float64.
+	s, err := Compile(map[string]interface{}{"enum": []interface{}{1, 2}, "minimum": 1, "maxLength": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(float64(2)); err != nil {
+		t.Errorf("2: %v", err)
+	}
+	if err := s.Validate(float64(3)); err == nil {
+		t.Errorf("3 is not in the enum but was accepted")
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	s, err := Compile(map[string]interface{}{"type": "object"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateJSON([]byte(`{"a": 1}`)); err != nil {
+		t.Errorf("ValidateJSON: %v", err)
+	}
+	if err := s.ValidateJSON([]byte(`<html>`)); err == nil || !strings.HasPrefix(err.Error(), "response is not JSON: ") {
+		t.Errorf("ValidateJSON(<html>) = %v, want a decoding error", err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		schema string
+		err    string
+	}{
+		{`"string"`, "$: schema must be an object"},
+		{`{"$ref": "#/components/schemas/Pet"}`, "$: $ref is not supported, inline the referenced schema"},
+		{`{"type": 1}`, "$.type: expected a string or a list"},
+		{`{"type": ["string", 1]}`, "$.type: expected strings"},
+		{`{"minimum": "1"}`, "$.minimum: expected a number"},
+		{`{"pattern": "("}`, "$.pattern: error parsing regexp: missing closing ): `(`"},
+		{`{"properties": {"a": {"maxItems": "x"}}}`, "$.properties.a.maxItems: expected a number"},
+		{`{"items": 1}`, "$.items: schema must be an object"},
+		{`{"additionalProperties": {"type": 2}}`, "$.additionalProperties.type: expected a string or a list"},
+		{`{"anyOf": [{}, {"type": 2}]}`, "$.anyOf[1].type: expected a string or a list"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(mustDecode(t, tt.schema))
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Compile(%s) = %v, want %q", tt.schema, err, tt.err)
+		}
+	}
+}
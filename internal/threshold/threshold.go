@@ -0,0 +1,370 @@
+// Package threshold parses and evaluates pass/fail rules on the results of
+// a run, such as "p95 < 300ms", "error_rate < 1%" or
+// "rps{endpoint=search} > 500".
+package threshold
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"stormforce/internal/results"
+)
+
+// Metric kinds, which decide the unit of a threshold's value.
+const (
+	kindLatency = iota // a duration such as 300ms, stored in seconds
+	kindRate           // a percentage such as 1%
+	kindRPS            // requests per second, optionally written 500/s
+	kindCount          // a number of requests
+)
+
+// metrics maps the metric names to their kind. Percentiles (p95, p99.9)
+// are latencies too.
+var metrics = map[string]int{
+	"avg":             kindLatency,
+	"min":             kindLatency,
+	"med":             kindLatency,
+	"max":             kindLatency,
+	"error_rate":      kindRate,
+	"success_rate":    kindRate,
+	"check_rate":      kindRate,
+	"rps":             kindRPS,
+	"requests":        kindCount,
+	"failed_requests": kindCount,
+}
+
+// Threshold is a rule on a metric of the whole run, of one endpoint or of
+// the endpoints with a tag. Latencies cover successful requests only.
+type Threshold struct {
+	Expr       string
+	Metric     string
+	Percentile float64 // set for pNN metrics
+	Endpoint   string
+	Tag        string
+	Op         string
+	Value      float64 // seconds for latencies, percent for rates
+	kind       int
+}
+
+var pattern = regexp.MustCompile(`^([a-z_]+|p\d+(?:\.\d+)?)\s*(?:\{\s*(endpoint|tag)\s*=\s*("[^"]*"|[^}]*?)\s*\})?\s*(<=|>=|==|!=|<|>)\s*(\S.*)$`)
+
+// Parse parses an expression such as "p95 < 300ms" or
+// `error_rate{endpoint="create order"} < 1%`.
+func Parse(expr string) (Threshold, error) {
+	expr = strings.TrimSpace(expr)
+	m := pattern.FindStringSubmatch(expr)
+	if m == nil {
+		return Threshold{}, fmt.Errorf("invalid threshold %q: expected metric[{endpoint=name}] op value, e.g. p95 < 300ms", expr)
+	}
+	t := Threshold{Expr: expr, Metric: m[1], Op: m[4]}
+
+	if kind, ok := metrics[t.Metric]; ok {
+		t.kind = kind
+	} else if strings.HasPrefix(t.Metric, "p") && t.Metric != "p" {
+		p, err := strconv.ParseFloat(t.Metric[1:], 64)
+		if err != nil || p <= 0 || p > 100 {
+			return Threshold{}, fmt.Errorf("invalid threshold %q: percentile must be in (0, 100]", expr)
+		}
+		t.kind, t.Percentile = kindLatency, p
+	} else {
+		return Threshold{}, fmt.Errorf("invalid threshold %q: unknown metric %s (%s or pNN)", expr, t.Metric, strings.Join(metricNames(), ", "))
+	}
+
+	if m[2] != "" {
+		name := strings.Trim(m[3], `"`)
+		if name == "" {
+			return Threshold{}, fmt.Errorf("invalid threshold %q: empty %s name", expr, m[2])
+		}
+		if m[2] == "endpoint" {
+			t.Endpoint = name
+		} else {
+			t.Tag = name
+		}
+	}
+
+	value := strings.TrimSpace(m[5])
+	var err error
+	switch t.kind {
+	case kindLatency:
+		var d time.Duration
+		if d, err = time.ParseDuration(value); err == nil {
+			t.Value = d.Seconds()
+		} else {
+			err = fmt.Errorf("expected a duration such as 300ms")
+		}
+	case kindRate:
+		if !strings.HasSuffix(value, "%") {
+			err = fmt.Errorf("expected a percentage such as 1%%")
+		} else {
+			t.Value, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, "%")), 64)
+		}
+	case kindRPS:
+		t.Value, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, "/s")), 64)
+	case kindCount:
+		var n int
+		n, err = strconv.Atoi(value)
+		t.Value = float64(n)
+	}
+	if err != nil {
+		return Threshold{}, fmt.Errorf("invalid threshold %q: bad value %q: %v", expr, value, err)
+	}
+	return t, nil
+}
+
+// ParseList parses a comma separated list of expressions. Commas inside
+// an endpoint or tag scope, such as {endpoint="list, sorted"}, do not
+// separate expressions.
+func ParseList(input string) ([]Threshold, error) {
+	var thresholds []Threshold
+	for _, part := range splitList(input) {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		t, err := Parse(part)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+// splitList splits input on the commas outside braces and quotes.
+func splitList(input string) []string {
+	var parts []string
+	start, depth, quoted := 0, 0, false
+	for i, c := range input {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, input[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, input[start:])
+}
+
+// ForEndpoint scopes t to endpoint. It fails when t has a scope already.
+func (t Threshold) ForEndpoint(endpoint string) (Threshold, error) {
+	if t.Endpoint != "" || t.Tag != "" {
+		return t, fmt.Errorf("threshold %q of a request cannot name another endpoint or tag", t.Expr)
+	}
+	t.Endpoint = endpoint
+	t.Expr = fmt.Sprintf("%s{endpoint=%q}%s", t.Metric, endpoint, strings.TrimPrefix(t.Expr, t.Metric))
+	return t, nil
+}
+
+// Windowed reports whether t can be judged over a window of the run while
+// it is in progress. Request counts only make sense for a whole run.
+func (t Threshold) Windowed() bool {
+	return t.kind != kindCount
+}
+
+// Percentiles returns configured, or the default percentiles when it is
+// empty, plus those the thresholds refer to, so that every one of them is
+// reported.
+func Percentiles(configured []float64, thresholds []Threshold) []float64 {
+	if len(configured) == 0 {
+		configured = results.DefaultPercentiles
+	}
+	percentiles := append([]float64{}, configured...)
+	for _, t := range thresholds {
+		if t.Percentile > 0 && !containsFloat(percentiles, t.Percentile) {
+			percentiles = append(percentiles, t.Percentile)
+		}
+	}
+	sort.Float64s(percentiles)
+	return percentiles
+}
+
+// Evaluate checks every threshold against r and stores the outcomes in
+// r.Thresholds. A threshold whose metric cannot be measured, for example
+// a latency without successful requests, fails.
+func Evaluate(thresholds []Threshold, r *results.Results) {
+	r.Thresholds = make([]results.ThresholdResult, len(thresholds))
+	for i, t := range thresholds {
+		outcome := results.ThresholdResult{Threshold: t.Expr}
+		actual, err := t.measure(r)
+		if err != nil {
+			outcome.Error = err.Error()
+		} else {
+			outcome.Actual = t.format(actual)
+			outcome.Passed = t.compare(actual)
+		}
+		r.Thresholds[i] = outcome
+	}
+}
+
+// measure returns the value of the threshold's metric in r.
+func (t Threshold) measure(r *results.Results) (float64, error) {
+	stats, err := t.stats(r)
+	if err != nil {
+		return 0, err
+	}
+
+	switch t.Metric {
+	case "error_rate", "success_rate":
+		if stats.TotalRequests == 0 {
+			return 0, fmt.Errorf("no requests")
+		}
+		if t.Metric == "error_rate" {
+			return 100 - stats.SuccessRate(), nil
+		}
+		return stats.SuccessRate(), nil
+	case "check_rate":
+		passed, failed := 0, 0
+		for _, c := range r.Checks {
+			if t.covers(r, c.Endpoint) {
+				passed += c.Passed
+				failed += c.Failed
+			}
+		}
+		if passed+failed == 0 {
+			return 0, fmt.Errorf("no checks ran")
+		}
+		return float64(passed) / float64(passed+failed) * 100, nil
+	case "rps":
+		return stats.Throughput, nil
+	case "requests":
+		return float64(stats.TotalRequests), nil
+	case "failed_requests":
+		return float64(stats.FailedRequests), nil
+	}
+
+	if stats.Latency.Count == 0 {
+		return 0, fmt.Errorf("no successful requests")
+	}
+	switch t.Metric {
+	case "avg":
+		return stats.Latency.Average, nil
+	case "min":
+		return stats.Latency.Min, nil
+	case "med":
+		return stats.Latency.Median, nil
+	case "max":
+		return stats.Latency.Max, nil
+	}
+	for _, p := range stats.Percentiles {
+		if p.Percentile == t.Percentile {
+			return p.Value, nil
+		}
+	}
+	return 0, fmt.Errorf("P%g was not reported", t.Percentile)
+}
+
+// stats returns the statistics the threshold applies to.
+func (t Threshold) stats(r *results.Results) (results.EndpointStats, error) {
+	find := func(list []results.EndpointStats, name, kind string) (results.EndpointStats, error) {
+		for _, stats := range list {
+			if stats.Name == name {
+				return stats, nil
+			}
+		}
+		return results.EndpointStats{}, fmt.Errorf("no %s named %q", kind, name)
+	}
+	switch {
+	case t.Endpoint != "":
+		return find(r.Endpoints, t.Endpoint, "endpoint")
+	case t.Tag != "":
+		return find(r.Tags, t.Tag, "tag")
+	}
+	return results.EndpointStats{
+		TotalRequests:      r.TotalRequests,
+		SuccessfulRequests: r.SuccessfulRequests,
+		FailedRequests:     r.FailedRequests,
+		Latency: results.LatencyStats{
+			Count:   r.SuccessfulRequests,
+			Min:     r.MinTime,
+			Max:     r.MaxTime,
+			Average: r.AverageTime,
+			Median:  r.MedianTime,
+		},
+		Percentiles: r.Percentiles,
+		Throughput:  r.Throughput,
+	}, nil
+}
+
+// covers reports whether the threshold's scope includes endpoint.
+func (t Threshold) covers(r *results.Results, endpoint string) bool {
+	switch {
+	case t.Endpoint != "":
+		return endpoint == t.Endpoint
+	case t.Tag != "":
+		for _, stats := range r.Endpoints {
+			if stats.Name == endpoint {
+				return containsString(stats.Tags, t.Tag)
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (t Threshold) compare(actual float64) bool {
+	switch t.Op {
+	case "<":
+		return actual < t.Value
+	case "<=":
+		return actual <= t.Value
+	case ">":
+		return actual > t.Value
+	case ">=":
+		return actual >= t.Value
+	case "==":
+		return actual == t.Value
+	}
+	return actual != t.Value
+}
+
+// format writes a measured value in the unit of the threshold.
+func (t Threshold) format(value float64) string {
+	switch t.kind {
+	case kindLatency:
+		if value < 1 {
+			return fmt.Sprintf("%.1fms", value*1000)
+		}
+		return fmt.Sprintf("%.3fs", value)
+	case kindRate:
+		return fmt.Sprintf("%.2f%%", value)
+	case kindRPS:
+		return fmt.Sprintf("%.2f/s", value)
+	}
+	return strconv.Itoa(int(value))
+}
+
+func metricNames() []string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func containsFloat(list []float64, value float64) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
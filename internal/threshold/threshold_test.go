@@ -0,0 +1,228 @@
+package threshold
+
+import (
+	"reflect"
+	"testing"
+
+	"stormforce/internal/results"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want Threshold
+	}{
+		{"p95 < 300ms", Threshold{Metric: "p95", Percentile: 95, Op: "<", Value: 0.3, kind: kindLatency}},
+		{"p99.9<=1s", Threshold{Metric: "p99.9", Percentile: 99.9, Op: "<=", Value: 1, kind: kindLatency}},
+		{"avg > 1.5s", Threshold{Metric: "avg", Op: ">", Value: 1.5, kind: kindLatency}},
+		{"min >= 10ms", Threshold{Metric: "min", Op: ">=", Value: 0.01, kind: kindLatency}},
+		{"med == 100ms", Threshold{Metric: "med", Op: "==", Value: 0.1, kind: kindLatency}},
+		{"max != 2s", Threshold{Metric: "max", Op: "!=", Value: 2, kind: kindLatency}},
+		{"error_rate < 1%", Threshold{Metric: "error_rate", Op: "<", Value: 1, kind: kindRate}},
+		{"success_rate >= 99.5 %", Threshold{Metric: "success_rate", Op: ">=", Value: 99.5, kind: kindRate}},
+		{"check_rate > 95%", Threshold{Metric: "check_rate", Op: ">", Value: 95, kind: kindRate}},
+		{"rps > 500/s", Threshold{Metric: "rps", Op: ">", Value: 500, kind: kindRPS}},
+		{"rps>=500", Threshold{Metric: "rps", Op: ">=", Value: 500, kind: kindRPS}},
+		{"requests == 1000", Threshold{Metric: "requests", Op: "==", Value: 1000, kind: kindCount}},
+		{"failed_requests != 0", Threshold{Metric: "failed_requests", Op: "!=", Value: 0, kind: kindCount}},
+		{`avg{endpoint="create order"} < 200ms`, Threshold{Metric: "avg", Endpoint: "create order", Op: "<", Value: 0.2, kind: kindLatency}},
+		{"med{endpoint=list} < 50ms", Threshold{Metric: "med", Endpoint: "list", Op: "<", Value: 0.05, kind: kindLatency}},
+		{"error_rate{ tag = write } <= 5%", Threshold{Metric: "error_rate", Tag: "write", Op: "<=", Value: 5, kind: kindRate}},
+	}
+	for _, tt := range tests {
+		got, err := Parse("  " + tt.expr + " ")
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		tt.want.Expr = tt.expr
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"p95", `invalid threshold "p95": expected metric[{endpoint=name}] op value, e.g. p95 < 300ms`},
+		{"p95 =< 300ms", `invalid threshold "p95 =< 300ms": expected metric[{endpoint=name}] op value, e.g. p95 < 300ms`},
+		{"avg{host=a} < 1s", `invalid threshold "avg{host=a} < 1s": expected metric[{endpoint=name}] op value, e.g. p95 < 300ms`},
+		{"p0 < 1s", `invalid threshold "p0 < 1s": percentile must be in (0, 100]`},
+		{"p101 < 1s", `invalid threshold "p101 < 1s": percentile must be in (0, 100]`},
+		{"latency < 1s", `invalid threshold "latency < 1s": unknown metric latency (avg, check_rate, error_rate, failed_requests, max, med, min, requests, rps, success_rate or pNN)`},
+		{`avg{endpoint=""} < 1s`, `invalid threshold "avg{endpoint=\"\"} < 1s": empty endpoint name`},
+		{"p95 < 300", `invalid threshold "p95 < 300": bad value "300": expected a duration such as 300ms`},
+		{"error_rate < 1", `invalid threshold "error_rate < 1": bad value "1": expected a percentage such as 1%`},
+		{"error_rate < x%", `invalid threshold "error_rate < x%": bad value "x%": strconv.ParseFloat: parsing "x": invalid syntax`},
+		{"rps > fast", `invalid threshold "rps > fast": bad value "fast": strconv.ParseFloat: parsing "fast": invalid syntax`},
+		{"requests > 1.5", `invalid threshold "requests > 1.5": bad value "1.5": strconv.Atoi: parsing "1.5": invalid syntax`},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.expr); err == nil || err.Error() != tt.err {
+			t.Errorf("Parse(%q) = %v, want %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+func TestParseList(t *testing.T) {
+	thresholds, err := ParseList(`p95 < 300ms, error_rate{endpoint="list, sorted"} < 1%,, rps{endpoint=a,b} > 5 ,`)
+	if err != nil {
+		t.Fatalf("ParseList: %v", err)
+	}
+	var exprs, endpoints []string
+	for _, t := range thresholds {
+		exprs = append(exprs, t.Expr)
+		endpoints = append(endpoints, t.Endpoint)
+	}
+	if want := []string{"p95 < 300ms", `error_rate{endpoint="list, sorted"} < 1%`, "rps{endpoint=a,b} > 5"}; !reflect.DeepEqual(exprs, want) {
+		t.Errorf("expressions = %q, want %q", exprs, want)
+	}
+	if want := []string{"", "list, sorted", "a,b"}; !reflect.DeepEqual(endpoints, want) {
+		t.Errorf("endpoints = %q, want %q", endpoints, want)
+	}
+
+	if _, err := ParseList("p95 < 300ms, bogus"); err == nil {
+		t.Errorf("ParseList accepted an invalid expression")
+	}
+	if thresholds, err := ParseList(" "); err != nil || len(thresholds) != 0 {
+		t.Errorf("ParseList(blank) = %v, %v, want nothing", thresholds, err)
+	}
+}
+
+func TestForEndpoint(t *testing.T) {
+	p95, _ := Parse("p95 < 300ms")
+	scoped, err := p95.ForEndpoint("create order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scoped.Endpoint != "create order" || scoped.Expr != `p95{endpoint="create order"} < 300ms` {
+		t.Errorf("ForEndpoint = %+v", scoped)
+	}
+	if _, err := scoped.ForEndpoint("other"); err == nil {
+		t.Errorf("a scoped threshold was scoped again")
+	}
+}
+
+func testResults() *results.Results {
+	write := results.EndpointStats{
+		Name: "create order", Tags: []string{"write"},
+		TotalRequests: 40, SuccessfulRequests: 38, FailedRequests: 2,
+		Latency:     results.LatencyStats{Count: 38, Min: 0.05, Max: 1.5, Average: 0.2, Median: 0.18},
+		Percentiles: []results.Percentile{{Percentile: 95, Value: 0.45}},
+		Throughput:  20,
+	}
+	read := results.EndpointStats{
+		Name: "list, sorted", Tags: []string{"read"},
+		TotalRequests: 60, SuccessfulRequests: 60,
+		Latency:     results.LatencyStats{Count: 60, Min: 0.01, Max: 0.3, Average: 0.07, Median: 0.06},
+		Percentiles: []results.Percentile{{Percentile: 95, Value: 0.15}},
+		Throughput:  30,
+	}
+	broken := results.EndpointStats{Name: "broken", TotalRequests: 5, FailedRequests: 5}
+	idle := results.EndpointStats{Name: "idle"}
+	writeTag, readTag := write, read
+	writeTag.Name, readTag.Name = "write", "read"
+
+	return &results.Results{
+		TotalRequests:      105,
+		SuccessfulRequests: 98,
+		FailedRequests:     7,
+		MinTime:            0.01,
+		MaxTime:            1.5,
+		AverageTime:        0.12,
+		MedianTime:         0.1,
+		Percentiles:        []results.Percentile{{Percentile: 95, Value: 0.3}, {Percentile: 99, Value: 0.5}},
+		Throughput:         50,
+		Endpoints:          []results.EndpointStats{write, read, broken, idle},
+		Tags:               []results.EndpointStats{writeTag, readTag},
+		Checks: []results.CheckStats{
+			{Endpoint: "create order", Name: "status", Passed: 36, Failed: 4},
+			{Endpoint: "list, sorted", Name: "status", Passed: 60},
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expr   string
+		actual string
+		passed bool
+		err    string
+	}{
+		{expr: "avg < 200ms", actual: "120.0ms", passed: true},
+		{expr: "min >= 10ms", actual: "10.0ms", passed: true},
+		{expr: "med == 100ms", actual: "100.0ms", passed: true},
+		{expr: "max < 2s", actual: "1.500s", passed: true},
+		{expr: "max <= 1s", actual: "1.500s"},
+		{expr: "p95 < 300ms", actual: "300.0ms"},
+		{expr: "p95 <= 300ms", actual: "300.0ms", passed: true},
+		{expr: "p99 != 500ms", actual: "500.0ms"},
+		{expr: "p90 < 1s", err: "P90 was not reported"},
+		{expr: "error_rate < 5%", actual: "6.67%"},
+		{expr: "success_rate > 90%", actual: "93.33%", passed: true},
+		{expr: "check_rate >= 96%", actual: "96.00%", passed: true},
+		{expr: `check_rate{endpoint="create order"} >= 95%`, actual: "90.00%"},
+		{expr: "check_rate{tag=read} == 100%", actual: "100.00%", passed: true},
+		{expr: "rps > 40/s", actual: "50.00/s", passed: true},
+		{expr: "rps{tag=write} > 25", actual: "20.00/s"},
+		{expr: "requests == 105", actual: "105", passed: true},
+		{expr: `failed_requests{endpoint="create order"} <= 1`, actual: "2"},
+		{expr: `p95{endpoint="list, sorted"} < 200ms`, actual: "150.0ms", passed: true},
+		{expr: "avg{tag=write} > 150ms", actual: "200.0ms", passed: true},
+		{expr: "error_rate{endpoint=broken} < 50%", actual: "100.00%"},
+		{expr: "avg{endpoint=broken} < 1s", err: "no successful requests"},
+		{expr: "error_rate{endpoint=idle} < 1%", err: "no requests"},
+		{expr: "check_rate{endpoint=idle} > 0%", err: "no checks ran"},
+		{expr: "avg{endpoint=missing} < 1s", err: `no endpoint named "missing"`},
+		{expr: "p95{tag=none} < 1s", err: `no tag named "none"`},
+	}
+	var thresholds []Threshold
+	for _, tt := range tests {
+		th, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		thresholds = append(thresholds, th)
+	}
+
+	r := testResults()
+	Evaluate(thresholds, r)
+	if len(r.Thresholds) != len(tests) {
+		t.Fatalf("%d outcomes for %d thresholds", len(r.Thresholds), len(tests))
+	}
+	for i, tt := range tests {
+		want := results.ThresholdResult{Threshold: tt.expr, Actual: tt.actual, Passed: tt.passed, Error: tt.err}
+		if got := r.Thresholds[i]; got != want {
+			t.Errorf("%s: %+v, want %+v", tt.expr, got, want)
+		}
+	}
+}
+
+func TestWindowed(t *testing.T) {
+	for expr, want := range map[string]bool{
+		"p95 < 1s":            true,
+		"error_rate < 1%":     true,
+		"rps > 10":            true,
+		"requests > 10":       false,
+		"failed_requests < 1": false,
+	} {
+		th, err := Parse(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if th.Windowed() != want {
+			t.Errorf("%s: Windowed() = %v, want %v", expr, !want, want)
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	p999, _ := Parse("p99.9 < 1s")
+	p95, _ := Parse("p95 < 1s")
+	if got, want := Percentiles([]float64{99, 50}, []Threshold{p999, p95}), []float64{50, 95, 99, 99.9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Percentiles = %v, want %v", got, want)
+	}
+}
@@ -2,9 +2,10 @@ package ui
 
 import (
 	"fmt"
-	"math"
+	"io"
 	"os"
 	"sort"
+	"time"
 
 	"stormforce/internal/config"
 	"stormforce/internal/results"
@@ -14,28 +15,56 @@ import (
 	"github.com/go-echarts/go-echarts/v2/opts"
 )
 
+// GenerateCharts writes the HTML report to load_test_results.html.
 func GenerateCharts(results results.Results, cfg config.Config) error {
+	f, err := os.Create("load_test_results.html")
+	if err != nil {
+		return fmt.Errorf("error creating output file: %v", err)
+	}
+	defer f.Close()
+
+	return RenderCharts(f, results, cfg)
+}
+
+// RenderCharts writes the HTML report to w.
+func RenderCharts(w io.Writer, results results.Results, cfg config.Config) error {
 	page := components.NewPage()
 	page.PageTitle = "Load Test Results"
+	if results.Interrupted {
+		page.PageTitle = "Load Test Results (interrupted, partial)"
+	}
 
 	page.AddCharts(
 		generateHistogram(results),
 		generateCDF(results),
-		generateResponseTimeSeries(results),
-		generateRequestRateVsResponseTime(results),
+	)
+	if len(results.Timeline) > 0 {
+		page.AddCharts(
+			generateResponseTimeSeries(results),
+			generateThroughputSeries(results),
+			generateInFlightSeries(results),
+		)
+	}
+	page.AddCharts(
 		generateErrorRateChart(results),
 		generatePercentileDistribution(results),
 		generateStatusCodeDistribution(results),
-		generateConcurrentUsersVsResponseTime(results, cfg),
+		generatePhaseBreakdown(results),
 	)
-
-	f, err := os.Create("load_test_results.html")
-	if err != nil {
-		return fmt.Errorf("error creating output file: %v", err)
+	if len(results.VUTimeline) > 0 {
+		page.AddCharts(generateVirtualUsersChart(results))
+	}
+	if len(results.Endpoints) > 0 {
+		page.AddCharts(
+			generateEndpointRequests(results),
+			generateEndpointLatency(results),
+		)
+	}
+	if len(results.Checks) > 0 {
+		page.AddCharts(generateChecks(results))
 	}
-	defer f.Close()
 
-	return page.Render(f)
+	return page.Render(w)
 }
 
 func generateHistogram(results results.Results) *charts.Bar {
@@ -49,19 +78,21 @@ func generateHistogram(results results.Results) *charts.Bar {
 	buckets := 20
 	histData := make([]opts.BarData, buckets)
 	bucketSize := (results.MaxTime - results.MinTime) / float64(buckets)
+	if bucketSize <= 0 {
+		// All responses took the same time (or none succeeded); avoid dividing by zero.
+		bucketSize = 1
+	}
 
 	for i := range histData {
 		histData[i] = opts.BarData{Value: 0}
 	}
 
-	for _, time := range results.ResponseTimes {
-		if time >= 0 {
-			bucket := int((time - results.MinTime) / bucketSize)
-			if bucket == buckets {
-				bucket--
-			}
-			histData[bucket].Value = histData[bucket].Value.(int) + 1
+	for _, time := range results.SuccessfulTimes() {
+		bucket := int((time - results.MinTime) / bucketSize)
+		if bucket >= buckets {
+			bucket = buckets - 1
 		}
+		histData[bucket].Value = histData[bucket].Value.(int) + 1
 	}
 
 	xAxisData := make([]string, buckets)
@@ -81,17 +112,10 @@ func generateCDF(results results.Results) *charts.Line {
 		charts.WithYAxisOpts(opts.YAxis{Name: "Cumulative Probability"}),
 	)
 
-	validTimes := []float64{}
-	for _, time := range results.ResponseTimes {
-		if time >= 0 {
-			validTimes = append(validTimes, time)
-		}
-	}
-	sort.Float64s(validTimes)
-
-	cdfData := make([]opts.LineData, len(validTimes))
-	for i, time := range validTimes {
-		cdfData[i] = opts.LineData{Value: []float64{time, float64(i+1) / float64(len(validTimes))}}
+	distribution := results.LatencyDistribution()
+	cdfData := make([]opts.LineData, len(distribution))
+	for i, point := range distribution {
+		cdfData[i] = opts.LineData{Value: []float64{point.Value, point.Cumulative}}
 	}
 
 	cdfChart.AddSeries("CDF", cdfData)
@@ -99,44 +123,95 @@ func generateCDF(results results.Results) *charts.Line {
 }
 
 func generateResponseTimeSeries(results results.Results) *charts.Line {
-	timeSeriesChart := charts.NewLine()
-	timeSeriesChart.SetGlobalOptions(
-		charts.WithTitleOpts(opts.Title{Title: "Response Time Series"}),
-		charts.WithXAxisOpts(opts.XAxis{Name: "Request Number"}),
+	lineChart := charts.NewLine()
+	lineChart.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Response Time Over Time"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Time", Type: "time"}),
 		charts.WithYAxisOpts(opts.YAxis{Name: "Response Time (s)"}),
 	)
 
-	data := make([]opts.LineData, len(results.ResponseTimes))
-	for i, time := range results.ResponseTimes {
-		if time >= 0 {
-			data[i] = opts.LineData{Value: time}
-		} else {
-			data[i] = opts.LineData{Value: nil}
+	average := make([]opts.LineData, len(results.Timeline))
+	for i, b := range results.Timeline {
+		average[i] = timelinePoint(results, b, bucketLatency(b, b.Average))
+	}
+	lineChart.AddSeries("Average", average)
+	for j, p := range results.Percentiles {
+		data := make([]opts.LineData, len(results.Timeline))
+		for i, b := range results.Timeline {
+			value := interface{}("-")
+			if j < len(b.Percentiles) {
+				value = bucketLatency(b, b.Percentiles[j].Value)
+			}
+			data[i] = timelinePoint(results, b, value)
 		}
+		lineChart.AddSeries(p.Label(), data)
 	}
+	return lineChart
+}
 
-	timeSeriesChart.AddSeries("Response Time", data)
-	return timeSeriesChart
+func generateThroughputSeries(results results.Results) *charts.Line {
+	lineChart := charts.NewLine()
+	lineChart.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Throughput Over Time"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Time", Type: "time"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "Requests/s"}),
+	)
+	lineChart.ExtendYAxis(opts.YAxis{Name: "Error Rate (%)", Min: 0, Max: 100})
+
+	requests := make([]opts.LineData, len(results.Timeline))
+	failed := make([]opts.LineData, len(results.Timeline))
+	errorRate := make([]opts.LineData, len(results.Timeline))
+	for i, b := range results.Timeline {
+		requests[i] = timelinePoint(results, b, b.Throughput)
+		failed[i] = timelinePoint(results, b, b.Throughput*b.ErrorRate/100)
+		errorRate[i] = timelinePoint(results, b, b.ErrorRate)
+	}
+	lineChart.AddSeries("Requests/s", requests).
+		AddSeries("Failed/s", failed).
+		AddSeries("Error Rate (%)", errorRate, charts.WithLineChartOpts(opts.LineChart{YAxisIndex: 1}))
+	return lineChart
 }
 
-func generateRequestRateVsResponseTime(results results.Results) *charts.Scatter {
-	scatterChart := charts.NewScatter()
-	scatterChart.SetGlobalOptions(
-		charts.WithTitleOpts(opts.Title{Title: "Request Rate vs. Response Time"}),
-		charts.WithXAxisOpts(opts.XAxis{Name: "Request Rate (requests/second)"}),
-		charts.WithYAxisOpts(opts.YAxis{Name: "Response Time (s)"}),
+func generateInFlightSeries(results results.Results) *charts.Line {
+	lineChart := charts.NewLine()
+	lineChart.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Requests In Flight Over Time"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Time", Type: "time"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "Requests"}),
 	)
 
-	data := make([]opts.ScatterData, 0, len(results.ResponseTimes))
-	for i, time := range results.ResponseTimes {
-		if time >= 0 {
-			requestRate := float64(i+1) / results.TotalDuration
-			data = append(data, opts.ScatterData{Value: []float64{requestRate, time}})
-		}
+	data := make([]opts.LineData, len(results.Timeline))
+	for i, b := range results.Timeline {
+		data[i] = timelinePoint(results, b, b.InFlight)
+	}
+	lineChart.AddSeries("Most in flight", data)
+	return lineChart
+}
+
+// bucketLatency returns value, or a gap in the line when bucket b has no
+// successful request.
+func bucketLatency(b results.TimelineBucket, value float64) interface{} {
+	if b.Requests == b.Failed {
+		return "-"
 	}
+	return value
+}
 
-	scatterChart.AddSeries("Request Rate vs. Response Time", data)
-	return scatterChart
+// timelinePoint plots value at the middle of bucket b, in milliseconds
+// since the epoch as time axes expect.
+func timelinePoint(results results.Results, b results.TimelineBucket, value interface{}) opts.LineData {
+	return opts.LineData{Value: []interface{}{wallClock(results, b.Start+results.BucketSize/2), value}}
+}
+
+// wallClock converts seconds since the run started to milliseconds since the
+// epoch.
+func wallClock(results results.Results, seconds float64) int64 {
+	return results.StartTime.Add(time.Duration(seconds * float64(time.Second))).UnixMilli()
 }
 
 func generateErrorRateChart(results results.Results) *charts.Pie {
@@ -168,32 +243,59 @@ func generatePercentileDistribution(results results.Results) *charts.Line {
 	lineChart := charts.NewLine()
 	lineChart.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{Title: "Percentile Distribution"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
 		charts.WithXAxisOpts(opts.XAxis{Name: "Percentile"}),
 		charts.WithYAxisOpts(opts.YAxis{Name: "Response Time (s)"}),
 	)
 
-	validTimes := []float64{}
-	for _, time := range results.ResponseTimes {
-		if time >= 0 {
-			validTimes = append(validTimes, time)
+	data := make([]opts.LineData, len(results.Percentiles))
+	corrected := make([]opts.LineData, len(results.Percentiles))
+	xAxis := make([]string, len(results.Percentiles))
+	for i, p := range results.Percentiles {
+		xAxis[i] = p.Label()
+		data[i] = opts.LineData{Value: p.Value}
+		corrected[i] = opts.LineData{Value: nil}
+		if i < len(results.CorrectedPercentiles) {
+			corrected[i] = opts.LineData{Value: results.CorrectedPercentiles[i].Value}
 		}
 	}
-	sort.Float64s(validTimes)
-
-	percentiles := []float64{50, 75, 90, 95, 99}
-	data := make([]opts.LineData, len(percentiles))
-	xAxis := make([]string, len(percentiles))
 
-	for i, p := range percentiles {
-		index := int(float64(len(validTimes)-1) * p / 100)
-		data[i] = opts.LineData{Value: validTimes[index]}
-		xAxis[i] = fmt.Sprintf("P%.0f", p)
+	lineChart.SetXAxis(xAxis).
+		AddSeries("Raw", data).
+		AddSeries("Corrected for coordinated omission", corrected)
+	for _, endpoint := range results.Endpoints {
+		endpointData := make([]opts.LineData, len(endpoint.Percentiles))
+		for i, p := range endpoint.Percentiles {
+			endpointData[i] = opts.LineData{Value: p.Value}
+		}
+		lineChart.AddSeries(endpoint.Name, endpointData)
 	}
-
-	lineChart.SetXAxis(xAxis).AddSeries("Percentile", data)
 	return lineChart
 }
 
+func generatePhaseBreakdown(results results.Results) *charts.Bar {
+	barChart := charts.NewBar()
+	barChart.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Request Phase Breakdown"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Statistic"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "Time (s)"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+	)
+
+	barChart.SetXAxis([]string{"Min", "Median", "P90", "P99"})
+	for _, phase := range results.Phases {
+		data := []opts.BarData{
+			{Value: phase.Min},
+			{Value: phase.Median},
+			{Value: phase.P90},
+			{Value: phase.P99},
+		}
+		barChart.AddSeries(phase.Name, data, charts.WithBarChartOpts(opts.BarChart{Stack: "phases"}))
+	}
+	return barChart
+}
+
 func generateStatusCodeDistribution(results results.Results) *charts.Bar {
 	barChart := charts.NewBar()
 	barChart.SetGlobalOptions(
@@ -202,11 +304,16 @@ func generateStatusCodeDistribution(results results.Results) *charts.Bar {
 		charts.WithYAxisOpts(opts.YAxis{Name: "Count"}),
 	)
 
-	statusCodes := make(map[int]int)
-	for _, code := range results.FailedStatusCodes {
-		statusCodes[code]++
+	// Counted per endpoint name; the single target URL has an empty name.
+	statusCodes := make(map[int]map[string]int)
+	for _, sample := range results.Samples {
+		if !sample.Retried {
+			if statusCodes[sample.StatusCode] == nil {
+				statusCodes[sample.StatusCode] = make(map[string]int)
+			}
+			statusCodes[sample.StatusCode][sample.Name]++
+		}
 	}
-	statusCodes[200] = results.SuccessfulRequests
 
 	var keys []int
 	for k := range statusCodes {
@@ -215,32 +322,136 @@ func generateStatusCodeDistribution(results results.Results) *charts.Bar {
 	sort.Ints(keys)
 
 	xAxis := make([]string, len(keys))
-	data := make([]opts.BarData, len(keys))
 	for i, code := range keys {
 		xAxis[i] = fmt.Sprintf("%d", code)
-		data[i] = opts.BarData{Value: statusCodes[code]}
+		if code == 0 {
+			xAxis[i] = "No response"
+		}
+	}
+	barChart.SetXAxis(xAxis)
+
+	series := []string{""}
+	if len(results.Endpoints) > 0 {
+		series = series[:0]
+		barChart.SetGlobalOptions(charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}))
+		for _, endpoint := range results.Endpoints {
+			series = append(series, endpoint.Name)
+		}
 	}
+	for _, name := range series {
+		data := make([]opts.BarData, len(keys))
+		for i, code := range keys {
+			data[i] = opts.BarData{Value: statusCodes[code][name]}
+		}
+		label := name
+		if label == "" {
+			label = "Status Codes"
+		}
+		barChart.AddSeries(label, data, charts.WithBarChartOpts(opts.BarChart{Stack: "status"}))
+	}
+	return barChart
+}
+
+func generateEndpointRequests(results results.Results) *charts.Bar {
+	barChart := charts.NewBar()
+	barChart.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Requests per Endpoint"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "Requests"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+	)
 
-	barChart.SetXAxis(xAxis).AddSeries("Status Codes", data)
+	names := make([]string, len(results.Endpoints))
+	successful := make([]opts.BarData, len(results.Endpoints))
+	failed := make([]opts.BarData, len(results.Endpoints))
+	for i, endpoint := range results.Endpoints {
+		names[i] = endpoint.Name
+		successful[i] = opts.BarData{Value: endpoint.SuccessfulRequests}
+		failed[i] = opts.BarData{Value: endpoint.FailedRequests}
+	}
+
+	barChart.SetXAxis(names).
+		AddSeries("Successful", successful, charts.WithBarChartOpts(opts.BarChart{Stack: "requests"})).
+		AddSeries("Failed", failed, charts.WithBarChartOpts(opts.BarChart{Stack: "requests"}))
 	return barChart
 }
 
-func generateConcurrentUsersVsResponseTime(results results.Results, cfg config.Config) *charts.Scatter {
-	scatterChart := charts.NewScatter()
-	scatterChart.SetGlobalOptions(
-		charts.WithTitleOpts(opts.Title{Title: "Concurrent Users vs. Response Time"}),
-		charts.WithXAxisOpts(opts.XAxis{Name: "Concurrent Users"}),
+func generateEndpointLatency(results results.Results) *charts.Bar {
+	barChart := charts.NewBar()
+	barChart.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Response Time per Endpoint"}),
 		charts.WithYAxisOpts(opts.YAxis{Name: "Response Time (s)"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
 	)
 
-	data := make([]opts.ScatterData, 0, len(results.ResponseTimes))
-	for i, time := range results.ResponseTimes {
-		if time >= 0 {
-			concurrentUsers := int(math.Min(float64(i+1), float64(cfg.Threads)))
-			data = append(data, opts.ScatterData{Value: []float64{float64(concurrentUsers), time}})
+	names := make([]string, len(results.Endpoints))
+	average := make([]opts.BarData, len(results.Endpoints))
+	for i, endpoint := range results.Endpoints {
+		names[i] = endpoint.Name
+		average[i] = opts.BarData{Value: endpoint.Latency.Average}
+	}
+	barChart.SetXAxis(names).AddSeries("Average", average)
+
+	for i, p := range results.Endpoints[0].Percentiles {
+		data := make([]opts.BarData, len(results.Endpoints))
+		for j, endpoint := range results.Endpoints {
+			data[j] = opts.BarData{Value: endpoint.Percentiles[i].Value}
 		}
+		barChart.AddSeries(p.Label(), data)
 	}
+	return barChart
+}
 
-	scatterChart.AddSeries("Concurrent Users vs. Response Time", data)
-	return scatterChart
+func generateChecks(results results.Results) *charts.Bar {
+	barChart := charts.NewBar()
+	barChart.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Checks"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "Responses"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Top: "bottom"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+	)
+
+	labels := make([]string, len(results.Checks))
+	passed := make([]opts.BarData, len(results.Checks))
+	failed := make([]opts.BarData, len(results.Checks))
+	for i, check := range results.Checks {
+		labels[i] = checkLabel(check)
+		passed[i] = opts.BarData{Value: check.Passed}
+		failed[i] = opts.BarData{Value: check.Failed}
+	}
+
+	barChart.SetXAxis(labels).
+		AddSeries("Passed", passed, charts.WithBarChartOpts(opts.BarChart{Stack: "checks"})).
+		AddSeries("Failed", failed, charts.WithBarChartOpts(opts.BarChart{Stack: "checks"}))
+	return barChart
+}
+
+func generateVirtualUsersChart(results results.Results) *charts.Line {
+	lineChart := charts.NewLine()
+	lineChart.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Threads Over Time"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "Time", Type: "time"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "Threads"}),
+	)
+
+	data := make([]opts.LineData, len(results.VUTimeline))
+	for i, point := range results.VUTimeline {
+		data[i] = opts.LineData{Value: []interface{}{wallClock(results, point.Elapsed), point.VUs}}
+	}
+
+	lineChart.AddSeries("Threads", data,
+		charts.WithLineChartOpts(opts.LineChart{Step: "end"}),
+		charts.WithMarkLineNameXAxisItemOpts(stageMarkLines(results)...),
+	)
+	return lineChart
+}
+
+func stageMarkLines(results results.Results) []opts.MarkLineNameXAxisItem {
+	markLines := make([]opts.MarkLineNameXAxisItem, len(results.StageBoundaries))
+	for i, boundary := range results.StageBoundaries {
+		markLines[i] = opts.MarkLineNameXAxisItem{Name: fmt.Sprintf("End of stage %d", i+1), XAxis: wallClock(results, boundary)}
+	}
+	return markLines
 }
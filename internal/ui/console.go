@@ -3,7 +3,7 @@ package ui
 import (
 	"fmt"
 	"log"
-	"time"
+	"sort"
 
 	"stormforce/internal/config"
 	"stormforce/internal/results"
@@ -24,56 +24,257 @@ func PrintLogo() {
 func DisplayResults(results results.Results, config config.Config) {
 	fmt.Println("\n========================================")
 	fmt.Println("RESULTS:")
+	if results.Interrupted {
+		fmt.Println("- Run was interrupted; these are partial results. ⚠️")
+	}
+	if results.Aborted {
+		fmt.Printf("- Run aborted: %s. 🚩\n", results.StopReason)
+	} else if results.StopReason != "" {
+		fmt.Printf("- Run stopped early: %s. ⚠️\n", results.StopReason)
+	}
+	if results.PlannedDuration > 0 {
+		fmt.Printf("- Test mode: duration (%.0f seconds)\n", results.PlannedDuration)
+	} else if results.ScenarioSteps > 0 {
+		fmt.Printf("- Test mode: %d iterations\n", results.PlannedRequests)
+	} else {
+		fmt.Printf("- Test mode: %d requests\n", results.PlannedRequests)
+	}
+	if results.ScenarioSteps > 0 {
+		fmt.Printf("- Scenario: %d steps per iteration (requests below count every step)\n", results.ScenarioSteps)
+	}
+	if results.TargetRate > 0 {
+		fmt.Printf("- Target arrival rate: %.2f requests/second\n", results.TargetRate)
+		fmt.Printf("- Dropped arrivals: %d\n", results.DroppedArrivals)
+	}
 	fmt.Printf("- Total requests: %d\n", results.TotalRequests)
 	fmt.Printf("- Successful requests: %d\n", results.SuccessfulRequests)
 	fmt.Printf("- Failed requests: %d\n", results.FailedRequests)
+	for _, class := range sortedKeys(results.FailuresByClass) {
+		fmt.Printf("    %s: %d\n", class, results.FailuresByClass[class])
+	}
+	fmt.Printf("- Attempts: %d (%d retried, %.2f per request)\n", results.TotalAttempts, results.RetriedAttempts, results.AttemptsPerRequest)
+	fmt.Printf("- Requests that needed a retry: %d\n", results.RetriedRequests)
+	if results.WarmupRequests > 0 {
+		fmt.Printf("- Warmup requests (not included above): %d\n", results.WarmupRequests)
+	}
+	fmt.Println("- Response times below cover successful requests only:")
 	fmt.Printf("- Average response time: %.2f seconds\n", results.AverageTime)
 	fmt.Printf("- Median response time: %.2f seconds\n", results.MedianTime)
 	fmt.Printf("- 90th percentile response time: %.2f seconds\n", results.PercentileTime90)
 	fmt.Printf("- Min response time: %.2f seconds\n", results.MinTime)
 	fmt.Printf("- Max response time: %.2f seconds\n", results.MaxTime)
+	fmt.Printf("- Standard deviation: %.2f seconds\n", results.StdDevTime)
+	if len(results.Percentiles) > 0 {
+		switch {
+		case results.TargetRate > 0 && results.ExpectedInterval > 0:
+			fmt.Printf("- Response time percentiles (seconds, corrected for %d dropped arrivals with a %.3fs interval):\n", results.DroppedArrivals, results.ExpectedInterval)
+		case results.ExpectedInterval > 0:
+			fmt.Printf("- Response time percentiles (seconds, corrected for coordinated omission with a %.3fs interval):\n", results.ExpectedInterval)
+		case results.TargetRate > 0:
+			fmt.Println("- Response time percentiles (seconds, open model without dropped arrivals so no coordinated omission correction):")
+		default:
+			fmt.Println("- Response time percentiles (seconds, not corrected for coordinated omission):")
+		}
+		fmt.Printf("    %-9s %10s %10s\n", "", "Raw", "Corrected")
+		for i, p := range results.Percentiles {
+			fmt.Printf("    %-9s %10.3f %10.3f\n", p.Label(), p.Value, correctedValue(results, i))
+		}
+	}
+	if results.ErrorLatency.Count > 0 {
+		fmt.Printf("- Failed request response time: average %.2f, median %.2f, max %.2f seconds\n",
+			results.ErrorLatency.Average, results.ErrorLatency.Median, results.ErrorLatency.Max)
+	}
+	fmt.Printf("- Throughput: %.2f requests/second\n", results.Throughput)
+	if len(results.Phases) > 0 {
+		fmt.Println("- Phase timings (seconds):")
+		fmt.Printf("    %-9s %8s %8s %8s %8s\n", "Phase", "Min", "Median", "P90", "P99")
+		for _, phase := range results.Phases {
+			fmt.Printf("    %-9s %8.3f %8.3f %8.3f %8.3f\n", phase.Name, phase.Min, phase.Median, phase.P90, phase.P99)
+		}
+	}
+	if len(results.Endpoints) > 0 {
+		displayEndpoints("Per endpoint", "Endpoint", results.Endpoints)
+	}
+	if len(results.Tags) > 0 {
+		displayEndpoints("Per tag", "Tag", results.Tags)
+	}
+	if len(results.Checks) > 0 {
+		displayChecks(results.Checks)
+	}
 
-	successRate := float64(results.SuccessfulRequests) / float64(results.TotalRequests) * 100
+	successRate := results.SuccessRate()
 	fmt.Printf("- Success rate: %.2f%%\n", successRate)
 
-	if results.AverageTime > config.ThresholdTime {
-		fmt.Println("- Average response time is higher than the threshold. 🚩")
-	} else {
-		fmt.Println("- Average response time is within the acceptable range. 👍")
+	// The default response time and success rate thresholds are only
+	// reported; configured ones are judged in the thresholds table.
+	if !config.ThresholdTimeSet && config.ThresholdTime > 0 {
+		if results.AverageTime > config.ThresholdTime {
+			fmt.Println("- Average response time is higher than the threshold. 🚩")
+		} else {
+			fmt.Println("- Average response time is within the acceptable range. 👍")
+		}
+	}
+	if !config.ThresholdSuccessSet && config.ThresholdSuccess > 0 {
+		if successRate < config.ThresholdSuccess {
+			fmt.Println("- Success rate is lower than the threshold. 🚩")
+		} else {
+			fmt.Println("- Success rate meets the threshold. ✔️")
+		}
 	}
 
-	if successRate < config.ThresholdSuccess {
-		fmt.Println("- Success rate is lower than the threshold. 🚩")
-	} else {
-		fmt.Println("- Success rate meets the threshold. ✔️")
+	if len(results.Thresholds) > 0 {
+		displayThresholds(results)
 	}
 	fmt.Println("========================================")
 
 	log.Println("Test Results:")
+	if results.Interrupted {
+		log.Println("Run was interrupted; these are partial results.")
+	}
+	if results.Aborted {
+		log.Printf("Run aborted: %s\n", results.StopReason)
+	} else if results.StopReason != "" {
+		log.Printf("Run stopped early: %s\n", results.StopReason)
+	}
 	log.Printf("Total requests: %d\n", results.TotalRequests)
 	log.Printf("Successful requests: %d\n", results.SuccessfulRequests)
 	log.Printf("Failed requests: %d\n", results.FailedRequests)
+	for _, class := range sortedKeys(results.FailuresByClass) {
+		log.Printf("Failed requests (%s): %d\n", class, results.FailuresByClass[class])
+	}
+	log.Printf("Attempts: %d (%d retried, %.2f per request)\n", results.TotalAttempts, results.RetriedAttempts, results.AttemptsPerRequest)
+	log.Printf("Requests that needed a retry: %d\n", results.RetriedRequests)
+	log.Printf("Warmup requests: %d\n", results.WarmupRequests)
 	log.Printf("Average response time: %.2f seconds\n", results.AverageTime)
 	log.Printf("Median response time: %.2f seconds\n", results.MedianTime)
 	log.Printf("90th percentile response time: %.2f seconds\n", results.PercentileTime90)
 	log.Printf("Min response time: %.2f seconds\n", results.MinTime)
 	log.Printf("Max response time: %.2f seconds\n", results.MaxTime)
+	log.Printf("Standard deviation: %.2f seconds\n", results.StdDevTime)
+	for i, p := range results.Percentiles {
+		log.Printf("%s response time: %.3f seconds (corrected: %.3f seconds)\n", p.Label(), p.Value, correctedValue(results, i))
+	}
+	log.Printf("Failed request response time: average %.2f, median %.2f, max %.2f seconds\n",
+		results.ErrorLatency.Average, results.ErrorLatency.Median, results.ErrorLatency.Max)
+	log.Printf("Throughput: %.2f requests/second\n", results.Throughput)
+	for _, phase := range results.Phases {
+		log.Printf("%s phase: min %.3fs, median %.3fs, p90 %.3fs, p99 %.3fs\n", phase.Name, phase.Min, phase.Median, phase.P90, phase.P99)
+	}
+	for _, endpoint := range results.Endpoints {
+		log.Printf("Endpoint %s: %d requests (%.1f%%), %d failed, average %.3fs, median %.3fs, p90 %.3fs, %.2f requests/second\n", endpoint.Name,
+			endpoint.TotalRequests, endpoint.Share, endpoint.FailedRequests, endpoint.Latency.Average, endpoint.Latency.Median, endpoint.Latency.P90, endpoint.Throughput)
+	}
+	if results.TargetRate > 0 {
+		log.Printf("Dropped arrivals: %d\n", results.DroppedArrivals)
+	}
 	log.Printf("Success rate: %.2f%%\n", successRate)
+	for _, t := range results.Thresholds {
+		outcome := "passed"
+		if !t.Passed {
+			outcome = "failed"
+		}
+		log.Printf("Threshold %s: %s (%s%s)\n", t.Threshold, outcome, t.Actual, t.Error)
+	}
 }
 
-func Spinner(done chan bool) {
-	spinChars := []string{"⚡", "🌩️", "⚡", "🌪️"}
-	delay := 100 * time.Millisecond
-	i := 0
-	for {
-		select {
-		case <-done:
-			fmt.Print("\r") // Clear the spinner line
-			return
-		default:
-			fmt.Printf("\r[%s] StormForce is unleashing chaos... ", spinChars[i])
-			i = (i + 1) % len(spinChars)
-			time.Sleep(delay)
+// displayEndpoints prints a table with a row per scenario step or traffic
+// mix endpoint, or per tag. Response times are for successful requests, in
+// seconds.
+func displayEndpoints(title, column string, endpoints []results.EndpointStats) {
+	width := len(column)
+	for _, endpoint := range endpoints {
+		width = max(width, len(endpoint.Name))
+	}
+
+	fmt.Printf("- %s (response times in seconds):\n", title)
+	fmt.Printf("    %-*s %8s %7s %8s %8s %8s", width, column, "Requests", "Share", "Failed", "Req/s", "Average")
+	for _, p := range endpoints[0].Percentiles {
+		fmt.Printf(" %8s", p.Label())
+	}
+	fmt.Println()
+	for _, endpoint := range endpoints {
+		fmt.Printf("    %-*s %8d %6.1f%% %8d %8.2f %8.3f", width, endpoint.Name, endpoint.TotalRequests, endpoint.Share,
+			endpoint.FailedRequests, endpoint.Throughput, endpoint.Latency.Average)
+		for _, p := range endpoint.Percentiles {
+			fmt.Printf(" %8.3f", p.Value)
+		}
+		fmt.Println()
+	}
+}
+
+// displayChecks prints the pass and fail counts of every check, flagging
+// the checks that failed at least once.
+func displayChecks(checks []results.CheckStats) {
+	width := len("Check")
+	for _, check := range checks {
+		width = max(width, len(checkLabel(check)))
+	}
+
+	fmt.Println("- Checks:")
+	fmt.Printf("    %-*s %8s %8s %9s\n", width, "Check", "Passed", "Failed", "Pass rate")
+	for _, check := range checks {
+		mark := "✔️"
+		if check.Failed > 0 {
+			mark = "🚩"
+		}
+		fmt.Printf("    %-*s %8d %8d %8.2f%% %s\n", width, checkLabel(check), check.Passed, check.Failed, check.PassRate(), mark)
+	}
+}
+
+// displayThresholds prints every threshold with the measured value and
+// whether it passed. Thresholds that could not be measured fail.
+func displayThresholds(r results.Results) {
+	width := len("Threshold")
+	for _, t := range r.Thresholds {
+		width = max(width, len(t.Threshold))
+	}
+
+	fmt.Println("- Thresholds:")
+	fmt.Printf("    %-*s %10s\n", width, "Threshold", "Actual")
+	for _, t := range r.Thresholds {
+		mark := "✔️"
+		if !t.Passed {
+			mark = "🚩"
+		}
+		actual := t.Actual
+		if t.Error != "" {
+			actual = "-"
+		}
+		fmt.Printf("    %-*s %10s %s", width, t.Threshold, actual, mark)
+		if t.Error != "" {
+			fmt.Printf(" (%s)", t.Error)
 		}
+		fmt.Println()
+	}
+	if failed := r.FailedThresholds(); failed > 0 {
+		fmt.Printf("- %d of %d thresholds failed. 🚩\n", failed, len(r.Thresholds))
+	} else {
+		fmt.Println("- All thresholds passed. ✔️")
+	}
+}
+
+// checkLabel prefixes the check name with its endpoint, if any.
+func checkLabel(check results.CheckStats) string {
+	if check.Endpoint == "" {
+		return check.Name
+	}
+	return check.Endpoint + ": " + check.Name
+}
+
+// correctedValue returns the coordinated omission corrected counterpart of
+// results.Percentiles[i].
+func correctedValue(results results.Results, i int) float64 {
+	if i < len(results.CorrectedPercentiles) {
+		return results.CorrectedPercentiles[i].Value
+	}
+	return results.Percentiles[i].Value
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,211 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"stormforce/internal/results"
+)
+
+const (
+	// liveRefresh is how often the terminal dashboard is redrawn.
+	liveRefresh = 250 * time.Millisecond
+	// liveSummaryInterval is how often a summary line is printed when
+	// stdout is not a terminal.
+	liveSummaryInterval = 10 * time.Second
+	// sparklineWidth is how many seconds of request rate the dashboard
+	// plots.
+	sparklineWidth = 40
+	progressWidth  = 30
+)
+
+var (
+	spinChars  = []string{"⚡", "🌩️", "⚡", "🌪️"}
+	sparkChars = []rune("▁▂▃▄▅▆▇█")
+)
+
+// LiveDisplay shows the progress of a run until Stop is called. On a
+// terminal it redraws a dashboard a few times per second; otherwise it
+// prints a plain summary line every few seconds, which suits CI logs.
+type LiveDisplay struct {
+	live     *results.Live
+	out      io.Writer
+	terminal bool
+	stop     chan struct{}
+	stopped  chan struct{}
+	lines    int // lines of the last dashboard drawn
+	frame    int
+}
+
+// StartLive starts displaying live on stdout.
+func StartLive(live *results.Live) *LiveDisplay {
+	d := &LiveDisplay{
+		live:     live,
+		out:      os.Stdout,
+		terminal: stdoutIsTerminal(),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Stop draws the final state and waits for the display to finish.
+func (d *LiveDisplay) Stop() {
+	close(d.stop)
+	<-d.stopped
+}
+
+func (d *LiveDisplay) run() {
+	defer close(d.stopped)
+	interval := liveRefresh
+	if !d.terminal {
+		interval = liveSummaryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			if p := d.live.Progress(time.Now()); d.terminal {
+				d.draw(p)
+			} else if p.Started {
+				fmt.Fprintln(d.out, summaryLine(p))
+			}
+			return
+		case now := <-ticker.C:
+			p := d.live.Progress(now)
+			if !p.Started {
+				continue // warmup prints its own messages
+			}
+			if d.terminal {
+				d.draw(p)
+			} else {
+				fmt.Fprintln(d.out, summaryLine(p))
+			}
+		}
+	}
+}
+
+// draw replaces the previous dashboard with one for p. Dashboards never
+// get shorter since error counts only grow.
+func (d *LiveDisplay) draw(p results.Progress) {
+	if !p.Started {
+		return
+	}
+	lines := dashboard(p, spinChars[d.frame%len(spinChars)])
+	d.frame++
+
+	var b strings.Builder
+	if d.lines > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", d.lines)
+	}
+	for _, line := range lines {
+		b.WriteString("\r\x1b[2K")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	fmt.Fprint(d.out, b.String())
+	d.lines = len(lines)
+}
+
+func dashboard(p results.Progress, spinner string) []string {
+	elapsed := formatElapsed(p.Elapsed)
+	if p.PlannedDuration > 0 {
+		elapsed += " / " + formatElapsed(p.PlannedDuration)
+	}
+	filled := int(p.Done * float64(progressWidth))
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressWidth-filled)
+
+	lines := []string{
+		fmt.Sprintf("%s StormForce  %s  [%s] %3.0f%%", spinner, elapsed, bar, p.Done*100),
+		fmt.Sprintf("  Requests  %d   failed %d (%.2f%%)   in flight %d", p.Requests, p.Failed, failedShare(p), p.InFlight),
+		fmt.Sprintf("  Rate      %8.1f/s  %s", p.RPS, sparkline(p.History, sparklineWidth)),
+		fmt.Sprintf("  Latency   p50 %s   p95 %s   p99 %s   (last %ds)",
+			formatLatency(p.P50), formatLatency(p.P95), formatLatency(p.P99), results.LiveLatencyWindow),
+	}
+	if len(p.Errors) > 0 {
+		lines = append(lines, "  Errors    "+formatErrors(p.Errors))
+	}
+	return lines
+}
+
+// summaryLine describes p on a single line, for output that is not a
+// terminal.
+func summaryLine(p results.Progress) string {
+	line := fmt.Sprintf("[%s] %3.0f%% %d requests, %d failed, %.1f/s, %d in flight, p50 %s p95 %s p99 %s",
+		formatElapsed(p.Elapsed), p.Done*100, p.Requests, p.Failed, p.RPS, p.InFlight,
+		formatLatency(p.P50), formatLatency(p.P95), formatLatency(p.P99))
+	if len(p.Errors) > 0 {
+		line += ", errors " + formatErrors(p.Errors)
+	}
+	return line
+}
+
+func failedShare(p results.Progress) float64 {
+	if p.Requests == 0 {
+		return 0
+	}
+	return float64(p.Failed) / float64(p.Requests) * 100
+}
+
+// sparkline plots the last width values, scaled to the largest of them.
+func sparkline(values []int, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	highest := 0
+	for _, v := range values {
+		highest = max(highest, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if highest > 0 {
+			i = v * (len(sparkChars) - 1) / highest
+		}
+		b.WriteRune(sparkChars[i])
+	}
+	return b.String()
+}
+
+// formatErrors lists error counts, most frequent first.
+func formatErrors(errors map[string]int) string {
+	keys := sortedKeys(errors)
+	sort.SliceStable(keys, func(i, j int) bool { return errors[keys[i]] > errors[keys[j]] })
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s: %d", key, errors[key])
+	}
+	return strings.Join(parts, "   ")
+}
+
+func formatElapsed(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func formatLatency(seconds float64) string {
+	if seconds == 0 {
+		return "-"
+	}
+	if seconds < 1 {
+		return fmt.Sprintf("%.1fms", seconds*1000)
+	}
+	return fmt.Sprintf("%.2fs", seconds)
+}
+
+// stdoutIsTerminal reports whether stdout is a terminal that can redraw the
+// dashboard. Character devices such as /dev/null are not.
+func stdoutIsTerminal() bool {
+	return os.Getenv("TERM") != "dumb" && term.IsTerminal(int(os.Stdout.Fd()))
+}
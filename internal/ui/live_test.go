@@ -0,0 +1,135 @@
+package ui
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"stormforce/internal/results"
+)
+
+func TestPlainSummaryWithoutTerminal(t *testing.T) {
+	base := time.Now().Add(-65 * time.Second)
+	live := results.NewLive()
+	live.Begin(base, 130, 0)
+	unavailable := results.Sample{Start: base, Duration: 0.5, StatusCode: 503, Attempt: 1, ErrorClass: results.ErrorStatus}
+	live.Add(unavailable)
+	live.Add(results.Sample{Start: base.Add(time.Second), Duration: 0.25, StatusCode: 200, Attempt: 1})
+
+	var out bytes.Buffer
+	d := &LiveDisplay{live: live, out: &out, stop: make(chan struct{}), stopped: make(chan struct{})}
+	go d.run()
+	d.Stop()
+
+	got := out.String()
+	if strings.Contains(got, "\x1b[") {
+		t.Errorf("plain output contains terminal escapes: %q", got)
+	}
+	if strings.Count(got, "\n") != 1 {
+		t.Fatalf("output = %q, want a single summary line on Stop", got)
+	}
+	for _, want := range []string{"[01:05]  50%", "2 requests, 1 failed", "errors 503: 1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary %q does not contain %q", got, want)
+		}
+	}
+}
+
+func TestPlainSummaryBeforeStart(t *testing.T) {
+	var out bytes.Buffer
+	d := &LiveDisplay{live: results.NewLive(), out: &out, stop: make(chan struct{}), stopped: make(chan struct{})}
+	go d.run()
+	d.Stop()
+	if out.Len() != 0 {
+		t.Errorf("output = %q before the run started, want none", out.String())
+	}
+}
+
+func TestSummaryLine(t *testing.T) {
+	p := results.Progress{
+		Started:  true,
+		Elapsed:  65,
+		Done:     0.5,
+		Requests: 100,
+		Failed:   3,
+		RPS:      12.5,
+		InFlight: 4,
+		P50:      0.0123,
+		P95:      0.25,
+		P99:      1.5,
+		Errors:   map[string]int{"timeout": 1, "503": 2},
+	}
+	want := "[01:05]  50% 100 requests, 3 failed, 12.5/s, 4 in flight, p50 12.3ms p95 250.0ms p99 1.50s, errors 503: 2   timeout: 1"
+	if got := summaryLine(p); got != want {
+		t.Errorf("summaryLine = %q, want %q", got, want)
+	}
+}
+
+func TestDrawReplacesPreviousDashboard(t *testing.T) {
+	var out bytes.Buffer
+	d := &LiveDisplay{out: &out, terminal: true}
+	p := results.Progress{Started: true, Elapsed: 3, Requests: 10}
+
+	d.draw(p)
+	first := out.String()
+	if strings.HasPrefix(first, "\x1b[") || strings.Count(first, "\r\x1b[2K") != 4 {
+		t.Errorf("first dashboard = %q, want 4 cleared lines without moving up", first)
+	}
+
+	out.Reset()
+	p.Errors = map[string]int{"timeout": 1}
+	d.draw(p)
+	second := out.String()
+	if !strings.HasPrefix(second, "\x1b[4A") || strings.Count(second, "\r\x1b[2K") != 5 {
+		t.Errorf("second dashboard = %q, want to move up 4 lines and draw 5", second)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []int
+		width  int
+		want   string
+	}{
+		{nil, 3, ""},
+		{[]int{0, 0}, 3, "▁▁"},
+		{[]int{0, 1, 2, 4, 8}, 3, "▂▄█"},
+		{[]int{8, 4, 0}, 5, "█▄▁"},
+	}
+	for _, tt := range tests {
+		if got := sparkline(tt.values, tt.width); got != tt.want {
+			t.Errorf("sparkline(%v, %d) = %q, want %q", tt.values, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestFormatElapsedAndLatency(t *testing.T) {
+	for seconds, want := range map[float64]string{0: "00:00", 59.9: "00:59", 65: "01:05", 3725: "1:02:05"} {
+		if got := formatElapsed(seconds); got != want {
+			t.Errorf("formatElapsed(%g) = %q, want %q", seconds, got, want)
+		}
+	}
+	for seconds, want := range map[float64]string{0: "-", 0.0125: "12.5ms", 2.5: "2.50s"} {
+		if got := formatLatency(seconds); got != want {
+			t.Errorf("formatLatency(%g) = %q, want %q", seconds, got, want)
+		}
+	}
+}
+
+func TestStdoutRedirectedToDevNull(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+	if stdoutIsTerminal() {
+		t.Error("stdoutIsTerminal() = true for /dev/null")
+	}
+}
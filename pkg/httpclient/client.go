@@ -1,18 +1,21 @@
 package httpclient
 
 import (
+	"crypto/tls"
 	"net/http"
 	"time"
 )
 
-// NewClient creates a new HTTP client with a specified timeout
-func NewClient(timeoutSeconds int) *http.Client {
+// NewClient creates a new HTTP client with a specified timeout. When
+// insecure is set, TLS certificates are not verified.
+func NewClient(timeoutSeconds int, insecure bool) *http.Client {
 	return &http.Client{
 		Timeout: time.Duration(timeoutSeconds) * time.Second,
 		Transport: &http.Transport{
 			MaxIdleConns:        100,
 			MaxIdleConnsPerHost: 100,
 			IdleConnTimeout:     90 * time.Second,
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: insecure},
 		},
 	}
 }